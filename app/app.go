@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/g3n/engine/app"
+	"github.com/g3n/engine/camera"
 	"github.com/g3n/engine/core"
 	"github.com/g3n/engine/gls"
 	"github.com/g3n/engine/gui"
@@ -18,6 +19,8 @@ import (
 	"github.com/g3n/engine/window"
 	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/weiWang95/mcworld/app/blockv2"
+	"github.com/weiWang95/mcworld/app/saves"
+	"github.com/weiWang95/mcworld/app/world"
 )
 
 var instance *App
@@ -29,26 +32,31 @@ type App struct {
 	dirData  string // Full path of the data directory
 	scene    *core.Node
 	curWorld *World
-	sm       ISaveManager
+	sm       world.ISaveManager
 	bm       *blockv2.BlockManager
+	lib      *saves.Library
 
-	seed int64
+	level *world.LevelData
 
 	grid       *helper.Grid
 	frameRater *util.FrameRater // Render loop frame rater
 
 	// GUI
-	mainPanel  *gui.Panel
-	labelFPS   *gui.Label // header FPS label
-	debugPanel *DebugPanel
-	cursor     *gui.Panel
-	playerGui  *PlayerGui
+	selectScreen *WorldSelectScreen
+	menuCamera   *camera.Camera
+	mainPanel    *gui.Panel
+	labelFPS     *gui.Label // header FPS label
+	debugPanel   *DebugPanel
+	cursor       *gui.Panel
+	playerGui    *PlayerGui
 
 	// OldPlayer
 	// player *OldPlayer
 	player *Player
 
 	debugMode bool
+	// smoothLighting 平滑光照与环境光遮蔽, F6 切换
+	smoothLighting bool
 }
 
 func Instance() *App {
@@ -65,6 +73,7 @@ func Create() *App {
 
 	a.Application = app.App(800, 600, "Mc World")
 	a.debugMode = true
+	a.smoothLighting = true
 
 	a.log = logger.New("main", nil)
 	a.log.AddWriter(logger.NewConsole(false))
@@ -103,19 +112,26 @@ func Create() *App {
 
 	a.setupScene()
 
-	a.sm = newFileSaveManager(a)
-	a.initSeed()
-
 	a.bm = blockv2.NewBlockManager(a.log, a.dirData)
+	if a.debugMode {
+		if err := a.bm.DumpAtlas("userdata/atlas"); err != nil {
+			a.log.Warn("dump atlas fail, %v", err)
+		}
+	}
 
-	a.curWorld = NewWorld()
-	a.curWorld.Start(a)
-
-	a.player = NewPlayer()
-	a.player.Start(a)
-	a.player.SetPositionVec(*math32.NewVector3(0, 50, 0))
+	lib, err := saves.NewLibrary("userdata/saves")
+	if err != nil {
+		panic(err)
+	}
+	a.lib = lib
+	// 旧版只有一个世界, 保存在 userdata/save
+	if id, err := a.lib.Import("userdata/save", world.DEFAULT_WORLD_NAME); err != nil {
+		a.log.Error("import old save fail: %v", err)
+	} else if id != "" {
+		a.log.Info("imported old save as world %s", id)
+	}
 
-	a.buildGui()
+	a.showWorldSelect()
 
 	// Register Listen
 	gui.Manager().SubscribeID(window.OnKeyDown, &a, a.OnKeyDown)
@@ -125,6 +141,61 @@ func Create() *App {
 	return instance
 }
 
+// showWorldSelect 显示世界选择界面, 选择世界后进入游戏
+func (a *App) showWorldSelect() {
+	window.Get().(*window.GlfwWindow).SetInputMode(glfw.CursorMode, glfw.CursorNormal)
+
+	a.menuCamera = camera.New(1)
+	presets, err := world.LoadGeneratorPresets(fmt.Sprintf("%s/config/%s", a.dirData, world.PRESET_DIR))
+	if err != nil {
+		a.log.Warn("load generator presets fail: %v", err)
+	}
+	a.selectScreen = NewWorldSelectScreen(a.lib, presets, a.openWorld)
+	a.scene.Add(a.selectScreen)
+	gui.Manager().Set(a.selectScreen)
+}
+
+// openWorld 打开存档目录中 id 对应的世界并开始游戏
+func (a *App) openWorld(id string) error {
+	dir, err := a.lib.Dir(id)
+	if err != nil {
+		return err
+	}
+
+	sm, err := world.NewFileSaveManager(a.log, dir)
+	if err != nil {
+		return err
+	}
+	a.sm = sm
+	if err := a.initLevel(); err != nil {
+		a.closeSave()
+		return err
+	}
+	// 生成器在移除选择界面之前创建, 名称或参数无效时留在选择界面显示错误
+	wg, err := newWorldGenerator(a, a.level)
+	if err != nil {
+		a.closeSave()
+		return err
+	}
+
+	if a.selectScreen != nil {
+		a.scene.Remove(a.selectScreen)
+		a.selectScreen = nil
+	}
+	window.Get().(*window.GlfwWindow).SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
+
+	a.curWorld = NewWorld(wg)
+	a.curWorld.Start(a)
+
+	a.player = NewPlayer()
+	a.player.Start(a)
+
+	a.buildGui()
+	a.OnWindowSize("", nil)
+
+	return nil
+}
+
 func (a *App) setupScene() {
 	if a.curWorld != nil {
 		a.curWorld.Cleanup(a)
@@ -134,28 +205,65 @@ func (a *App) setupScene() {
 
 	a.DisposeAllCustomCursors()
 	a.SetCursor(window.ArrowCursor)
-	window.Get().(*window.GlfwWindow).SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
 
 	// Set default background color
 	a.Gls().ClearColor(0.6, 0.6, 0.6, 1.0)
 
 	// Reset renderer z-sorting flag
 	a.Renderer().SetObjectSorting(true)
+	RegisterChunkShader(a.Renderer())
 
 	// Create and add an axis helper to the scene
 	a.scene.Add(helper.NewAxes(1))
 }
 
-func (a *App) initSeed() {
-	a.seed = a.sm.LoadSeed()
-	if a.seed != 0 {
-		a.Log().Debug("load seed:%d", a.seed)
+// initLevel 读取世界元数据, 新存档使用当前时间作为种子创建新世界
+func (a *App) initLevel() error {
+	a.level = a.sm.LoadLevel()
+	if a.level != nil {
+		a.Log().Debug("load level:%s seed:%d", a.level.Name, a.level.Seed)
+		return nil
+	}
+
+	a.level = world.NewLevelData(world.DEFAULT_WORLD_NAME, time.Now().Unix())
+	a.Log().Debug("new level seed:%d", a.level.Seed)
+	return a.sm.SaveLevel(a.level)
+}
+
+// closeSave 打开世界失败时关闭存档, 回到选择界面
+func (a *App) closeSave() {
+	if err := a.sm.Close(); err != nil {
+		a.log.Error("close save fail: %v", err)
+	}
+	a.sm = nil
+	a.level = nil
+}
+
+// Save 保存所有区块与世界元数据
+func (a *App) Save() {
+	a.World().cm.SaveAll()
+	if err := a.sm.SavePendingWrites(a.World().Data().PendingWrites()); err != nil {
+		a.log.Error("save pending writes fail: %v", err)
+	}
+
+	a.level.Time = a.World().Data().CurTime()
+	a.level.Player = a.player.Data()
+	if err := a.sm.SaveLevel(a.level); err != nil {
+		a.log.Error("save level fail: %v", err)
+	}
+}
+
+// logRecoveryReport 输出本次运行中因损坏而被隔离并重新生成的存档数据
+func (a *App) logRecoveryReport() {
+	records := a.sm.RecoveryReport()
+	if len(records) == 0 {
 		return
 	}
 
-	a.seed = time.Now().Unix()
-	a.Log().Debug("new seed:%d", a.seed)
-	a.sm.SaveSeed(a.seed)
+	a.log.Warn("%d save data lost and regenerated, quarantined files are kept in save directory:", len(records))
+	for _, rec := range records {
+		a.log.Warn("  %s", rec)
+	}
 }
 
 func (a *App) buildGui() {
@@ -239,8 +347,12 @@ func (a *App) Update(rend *renderer.Renderer, deltaTime time.Duration) {
 		a.player.Update(a, deltaTime)
 	}
 
-	// Render scene
-	err := rend.Render(a.scene, a.player.Camera)
+	// Render scene, 选择世界时还没有玩家
+	cam := a.menuCamera
+	if a.player != nil {
+		cam = a.player.Camera
+	}
+	err := rend.Render(a.scene, cam)
 	if err != nil {
 		panic(err)
 	}
@@ -253,6 +365,11 @@ func (a *App) Update(rend *renderer.Renderer, deltaTime time.Duration) {
 
 // UpdateFPS updates the fps value in the window title or header label
 func (a *App) updateFPS() {
+	// 选择世界的界面还没有创建 HUD
+	if a.labelFPS == nil {
+		return
+	}
+
 	// Get the FPS and potential FPS from the frameRater
 	fps, pfps, ok := a.frameRater.FPS(time.Duration(1000) * time.Millisecond)
 	if !ok {
@@ -297,10 +414,14 @@ func (a *App) World() *World {
 	return a.curWorld
 }
 
-func (a *App) SaveManager() ISaveManager {
+func (a *App) SaveManager() world.ISaveManager {
 	return a.sm
 }
 
+func (a *App) Level() *world.LevelData {
+	return a.level
+}
+
 func (a *App) IsDebugMode() bool {
 	return a.debugMode
 }
@@ -318,9 +439,15 @@ func (a *App) OnWindowSize(evname string, ev interface{}) {
 	a.log.Debug("OnWindowSize: w: %d, h: %d, camera aspect: %.6f", w, h, aspect)
 
 	a.Gls().Viewport(0, 0, int32(w), int32(h))
-	a.player.Camera.SetAspect(aspect)
 
-	a.mainPanel.SetSize(float32(w), float32(h))
+	if a.selectScreen != nil {
+		a.menuCamera.SetAspect(aspect)
+		a.selectScreen.SetSize(float32(w), float32(h))
+	}
+	if a.player != nil {
+		a.player.Camera.SetAspect(aspect)
+		a.mainPanel.SetSize(float32(w), float32(h))
+	}
 }
 
 func (a *App) OnKeyDown(evname string, ev interface{}) {
@@ -328,7 +455,19 @@ func (a *App) OnKeyDown(evname string, ev interface{}) {
 
 	switch kev.Key {
 	case window.KeyEscape:
-		a.World().cm.SaveAll()
+		if a.curWorld != nil {
+			a.curWorld.Cleanup(a)
+			a.Save()
+			if err := a.sm.Close(); err != nil {
+				a.log.Error("close save fail: %v", err)
+			}
+			a.logRecoveryReport()
+		}
 		a.Exit()
+	case window.KeyF6:
+		a.smoothLighting = !a.smoothLighting
+		if a.curWorld != nil {
+			a.World().bu.SetSmoothLighting(a.smoothLighting)
+		}
 	}
 }
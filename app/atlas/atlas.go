@@ -0,0 +1,263 @@
+// Package atlas 将方块纹理打包为一张或多张纹理图集.
+//
+// 每张纹理缩放到统一的边长后放入边长为 2 的幂的格子中, 纹理四周用边缘像素向外填满整个格子.
+// 格子按自身边长对齐, 生成 mipmap 时每一级的纹素都不会跨越格子, 相邻纹理的颜色不会互相渗透.
+package atlas
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// Options 图集参数
+type Options struct {
+	TileSize int // 每张纹理缩放后的边长, 像素
+	Padding  int // 纹理四周至少保留的边框宽度, 像素
+	PageSize int // 单张图集的最大边长, 像素, 必须是 2 的幂
+}
+
+var DefaultOptions = Options{TileSize: 64, Padding: 16, PageSize: 1024}
+
+// Region 纹理在图集中的位置
+type Region struct {
+	Page int
+	// 纹理的像素范围, 不含边框
+	X, Y, W, H int
+	// 归一化的纹理坐标, 原点为图片左上角, V0 为上边缘
+	U0, V0, U1, V1 float32
+}
+
+// Atlas 打包后的图集
+type Atlas struct {
+	opts     Options
+	cellSize int
+	pages    []*image.RGBA
+	regions  map[string]Region
+}
+
+// Builder 收集纹理并打包为图集
+type Builder struct {
+	opts   Options
+	names  []string
+	images map[string]image.Image
+}
+
+func NewBuilder(opts Options) *Builder {
+	return &Builder{opts: opts, images: make(map[string]image.Image)}
+}
+
+// Add 添加一张纹理, 同名纹理只保留第一次添加的
+func (b *Builder) Add(name string, img image.Image) {
+	if _, ok := b.images[name]; ok {
+		return
+	}
+
+	b.names = append(b.names, name)
+	b.images[name] = img
+}
+
+// Build 按添加顺序将纹理放入格子, 一张图集放满后使用下一张
+func (b *Builder) Build() (*Atlas, error) {
+	if b.opts.TileSize <= 0 || b.opts.Padding < 0 {
+		return nil, fmt.Errorf("invalid atlas options: %+v", b.opts)
+	}
+	// 图集按格子边长翻倍, 边长不是 2 的幂时最后一次翻倍会超过上限
+	if b.opts.PageSize != nextPow2(b.opts.PageSize) {
+		return nil, fmt.Errorf("atlas page size %d is not a power of two", b.opts.PageSize)
+	}
+
+	cell := nextPow2(b.opts.TileSize + 2*b.opts.Padding)
+	if cell > b.opts.PageSize {
+		return nil, fmt.Errorf("atlas page size %d is smaller than cell size %d", b.opts.PageSize, cell)
+	}
+	if len(b.names) == 0 {
+		return nil, errors.New("atlas has no texture")
+	}
+
+	perRow := b.opts.PageSize / cell
+	perPage := perRow * perRow
+
+	a := &Atlas{
+		opts:     b.opts,
+		cellSize: cell,
+		regions:  make(map[string]Region, len(b.names)),
+	}
+
+	for i, name := range b.names {
+		page, slot := i/perPage, i%perPage
+		if slot == 0 {
+			a.pages = append(a.pages, newPage(len(b.names)-i, perPage, cell))
+		}
+
+		side := a.pages[page].Bounds().Dx()
+		cols := side / cell
+		x, y := slot%cols*cell, slot/cols*cell
+		a.regions[name] = a.place(page, x, y, scale(b.images[name], b.opts.TileSize))
+	}
+
+	return a, nil
+}
+
+// newPage 创建能放下 n 个格子的最小图集, 最多放 perPage 个
+func newPage(n, perPage, cell int) *image.RGBA {
+	if n > perPage {
+		n = perPage
+	}
+
+	side := cell
+	for (side/cell)*(side/cell) < n {
+		side *= 2
+	}
+
+	return image.NewRGBA(image.Rect(0, 0, side, side))
+}
+
+// place 把纹理居中放入 (x, y) 处的格子, 格子的其余部分复制纹理边缘的像素
+func (a *Atlas) place(page, x, y int, tile *image.RGBA) Region {
+	dst := a.pages[page]
+	size := tile.Bounds().Dx()
+	off := (a.cellSize - size) / 2
+
+	for cy := 0; cy < a.cellSize; cy++ {
+		for cx := 0; cx < a.cellSize; cx++ {
+			tx := clamp(cx-off, 0, size-1)
+			ty := clamp(cy-off, 0, size-1)
+			dst.SetRGBA(x+cx, y+cy, tile.RGBAAt(tx, ty))
+		}
+	}
+
+	side := float32(dst.Bounds().Dx())
+	return Region{
+		Page: page,
+		X:    x + off,
+		Y:    y + off,
+		W:    size,
+		H:    size,
+		U0:   float32(x+off) / side,
+		V0:   float32(y+off) / side,
+		U1:   float32(x+off+size) / side,
+		V1:   float32(y+off+size) / side,
+	}
+}
+
+// Region 返回纹理在图集中的位置
+func (a *Atlas) Region(name string) (Region, bool) {
+	r, ok := a.regions[name]
+	return r, ok
+}
+
+func (a *Atlas) PageCount() int {
+	return len(a.pages)
+}
+
+func (a *Atlas) Page(i int) *image.RGBA {
+	return a.pages[i]
+}
+
+// CellSize 格子边长, 生成 mipmap 时 log2(CellSize) 级以内不会发生纹理渗透
+func (a *Atlas) CellSize() int {
+	return a.cellSize
+}
+
+// WritePNG 将每张图集保存为 dir/atlas_<序号>.png, 用于检查打包结果
+func (a *Atlas) WritePNG(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	for i, page := range a.pages {
+		if err := writePNG(filepath.Join(dir, fmt.Sprintf("atlas_%d.png", i)), page); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadImage 读取 jpg 或 png 图片
+func LoadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// scale 将图片缩放为 size×size, 缩小时取区域平均值, 放大时取最近的像素
+func scale(src image.Image, size int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	for y := 0; y < size; y++ {
+		y0, y1 := b.Min.Y+y*sh/size, b.Min.Y+(y+1)*sh/size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < size; x++ {
+			x0, x1 := b.Min.X+x*sw/size, b.Min.X+(x+1)*sw/size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, al, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, al = r+uint64(cr), g+uint64(cg), bl+uint64(cb), al+uint64(ca)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(al / n),
+			})
+		}
+	}
+
+	return dst
+}
+
+func nextPow2(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
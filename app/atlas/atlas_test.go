@@ -0,0 +1,158 @@
+package atlas
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// quadrants 四个象限颜色不同的纹理, 用于检查边框复制的是哪个边缘
+func quadrants(size int, tl, tr, bl, br color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := tl
+			switch {
+			case x >= size/2 && y < size/2:
+				c = tr
+			case x < size/2 && y >= size/2:
+				c = bl
+			case x >= size/2 && y >= size/2:
+				c = br
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func solid(c color.RGBA) *image.RGBA {
+	return quadrants(4, c, c, c, c)
+}
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+	white = color.RGBA{255, 255, 255, 255}
+)
+
+// testOptions 格子边长 32, 每张图集最多 2×2 个格子
+var testOptions = Options{TileSize: 16, Padding: 8, PageSize: 64}
+
+func build(t *testing.T, opts Options, n int) *Atlas {
+	t.Helper()
+	b := NewBuilder(opts)
+	for i := 0; i < n; i++ {
+		b.Add(fmt.Sprintf("t%d", i), solid(color.RGBA{uint8(i * 40), 0, 0, 255}))
+	}
+	a, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestRegion(t *testing.T) {
+	a := build(t, testOptions, 3)
+	if a.CellSize() != 32 || a.PageCount() != 1 || a.Page(0).Bounds().Dx() != 64 {
+		t.Fatalf("cell %d, %d pages, page side %d", a.CellSize(), a.PageCount(), a.Page(0).Bounds().Dx())
+	}
+
+	// 第 3 张纹理在第二行第一个格子, 距格子边缘 8 像素
+	r, ok := a.Region("t2")
+	if !ok {
+		t.Fatal("region t2 not found")
+	}
+	want := Region{Page: 0, X: 8, Y: 40, W: 16, H: 16, U0: 0.125, V0: 0.625, U1: 0.375, V1: 0.875}
+	if r != want {
+		t.Fatalf("region %+v, want %+v", r, want)
+	}
+	if got := a.Page(0).RGBAAt(r.X, r.Y); got != (color.RGBA{80, 0, 0, 255}) {
+		t.Fatalf("texture pixel %v", got)
+	}
+
+	if _, ok := a.Region("missing"); ok {
+		t.Fatal("region of a texture never added")
+	}
+}
+
+func TestPadding(t *testing.T) {
+	b := NewBuilder(testOptions)
+	b.Add("q", quadrants(16, red, green, blue, white))
+	a, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 单个格子的图集, 边框复制最近的边缘像素, 四个角为纹理四个角的颜色
+	page := a.Page(0)
+	if page.Bounds().Dx() != 32 {
+		t.Fatalf("page side %d", page.Bounds().Dx())
+	}
+	cases := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, red}, {31, 0, green}, {0, 31, blue}, {31, 31, white},
+		{0, 12, red}, {12, 0, red}, {31, 12, green}, {20, 31, white},
+		{8, 8, red}, {23, 23, white},
+	}
+	for _, c := range cases {
+		if got := page.RGBAAt(c.x, c.y); got != c.want {
+			t.Errorf("pixel %d,%d is %v, want %v", c.x, c.y, got, c.want)
+		}
+	}
+}
+
+func TestPages(t *testing.T) {
+	// 每张 4 个格子, 9 张纹理需要 3 张图集, 最后一张只放一个格子
+	a := build(t, testOptions, 9)
+	if a.PageCount() != 3 {
+		t.Fatalf("%d pages", a.PageCount())
+	}
+	for i, side := range []int{64, 64, 32} {
+		if got := a.Page(i).Bounds().Dx(); got != side {
+			t.Errorf("page %d side %d, want %d", i, got, side)
+		}
+	}
+
+	for i := 0; i < 9; i++ {
+		r, _ := a.Region(fmt.Sprintf("t%d", i))
+		if r.Page != i/4 {
+			t.Errorf("t%d on page %d", i, r.Page)
+		}
+		if r.U1 > 1 || r.V1 > 1 {
+			t.Errorf("t%d region %+v out of page", i, r)
+		}
+	}
+
+	// 第二张图集放 2 张纹理时也需要 2×2 的格子
+	if a := build(t, testOptions, 6); a.Page(1).Bounds().Dx() != 64 {
+		t.Fatalf("second page side %d for 2 textures", a.Page(1).Bounds().Dx())
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	cases := []struct {
+		opts Options
+		err  string
+	}{
+		{Options{TileSize: 64, Padding: 16, PageSize: 1000}, "not a power of two"},
+		{Options{TileSize: 64, Padding: 16, PageSize: 64}, "smaller than cell size"},
+		{Options{TileSize: 0, Padding: 16, PageSize: 1024}, "invalid atlas options"},
+	}
+	for _, c := range cases {
+		b := NewBuilder(c.opts)
+		b.Add("t", solid(red))
+		if _, err := b.Build(); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("options %+v: error %v, want %q", c.opts, err, c.err)
+		}
+	}
+
+	if _, err := NewBuilder(DefaultOptions).Build(); err == nil {
+		t.Error("built an atlas without textures")
+	}
+}
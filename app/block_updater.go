@@ -1,17 +1,34 @@
 package app
 
 import (
+	"runtime"
 	"time"
 
 	"github.com/g3n/engine/util/logger"
-	"github.com/weiWang95/mcworld/app/blockv2"
-	"github.com/weiWang95/mcworld/lib/util"
+	"github.com/weiWang95/mcworld/app/mesher"
+	"github.com/weiWang95/mcworld/app/world"
 )
 
+// sectionKey 区段在世界中的位置
+type sectionKey struct {
+	cpos ChunkPos
+	idx  int
+}
+
+// MAX_MESH_UPLOADS_PER_TICK 每帧最多上传的区段网格数
+const MAX_MESH_UPLOADS_PER_TICK = 8
+
+// BlockUpdater 记录需要重建网格的区段, 网格在后台协程中生成, 每帧上传有限个数
 type BlockUpdater struct {
 	app   *App
 	world *World
 	log   *logger.Logger
+
+	pool     *taskPool
+	meshers  chan *mesher.Mesher // 每个工作协程同时只使用一个 Mesher
+	smooth   bool
+	dirty    map[sectionKey]struct{}
+	building map[sectionKey]struct{} // 正在后台生成的区段, 完成前再次变化的区段留在 dirty 中
 }
 
 func NewBlockUpdater(app *App) *BlockUpdater {
@@ -19,89 +36,129 @@ func NewBlockUpdater(app *App) *BlockUpdater {
 	u.app = app
 	u.world = app.World()
 	u.log = app.Log()
+
+	// 留一个核心给渲染线程
+	workers := runtime.NumCPU() - 1
+	if workers < 1 {
+		workers = 1
+	}
+	u.pool = newTaskPool(u.log, workers)
+	u.meshers = make(chan *mesher.Mesher, workers)
+	for i := 0; i < workers; i++ {
+		u.meshers <- mesher.NewMesher(u.world.data, app.bm)
+	}
+
+	u.dirty = make(map[sectionKey]struct{})
+	u.building = make(map[sectionKey]struct{})
 	return u
 }
 
 func (u *BlockUpdater) Update(a *App, t time.Duration) {
+	u.pool.Finish(MAX_MESH_UPLOADS_PER_TICK)
 
+	for key := range u.dirty {
+		if _, ok := u.building[key]; ok {
+			continue
+		}
+		u.buildSection(key)
+		delete(u.dirty, key)
+	}
+}
+
+// Cleanup 停止后台生成网格
+func (u *BlockUpdater) Cleanup() {
+	u.pool.Close()
 }
 
+// RefreshChunkBlocks 区块加载后重建它的所有区段, 相邻区块朝向它的面也可能变化, 一并重建
 func (u *BlockUpdater) RefreshChunkBlocks(chunk *Chunk) {
-	for y := int64(0); y < CHUNK_HEIGHT; y++ {
-		for x := int64(0); x < CHUNK_WIDTH; x++ {
-			for z := int64(0); z < CHUNK_WIDTH; z++ {
-				pos := chunk.GetWorldPos(x, y, z)
-				u.updateBlock(pos)
-
-				if x == 0 {
-					u.updateBlock(pos.SubX(1))
-				} else if x == CHUNK_WIDTH-1 {
-					u.updateBlock(pos.AddX(1))
-				}
-
-				if z == 0 {
-					u.updateBlock(pos.SubZ(1))
-				} else if z == CHUNK_WIDTH-1 {
-					u.updateBlock(pos.AddZ(1))
-				}
-			}
-		}
-	}
+	cpos := *chunk.pos
+	u.markChunk(cpos)
+	u.markChunk(ChunkPos{X: cpos.X - 1, Z: cpos.Z})
+	u.markChunk(ChunkPos{X: cpos.X + 1, Z: cpos.Z})
+	u.markChunk(ChunkPos{X: cpos.X, Z: cpos.Z - 1})
+	u.markChunk(ChunkPos{X: cpos.X, Z: cpos.Z + 1})
+}
 
-	// chunk.Rendered(u.app)
+// TiggerUpdate 方块变化后重建所在区段, 位于区段边界时相邻区段也需要重建
+func (u *BlockUpdater) TiggerUpdate(tiggerPos world.Pos) {
+	u.MarkDirty(tiggerPos)
+	tiggerPos.RangeAdjoin(func(pos world.Pos, face world.BlockFace) {
+		u.MarkDirty(pos)
+	})
 }
 
-func (u *BlockUpdater) TiggerUpdate(tiggerPos util.Pos) {
-	updatePos := []util.Pos{
-		tiggerPos,
-		tiggerPos.SubX(1), tiggerPos.AddX(1),
-		tiggerPos.SubY(1), tiggerPos.AddY(1),
-		tiggerPos.SubZ(1), tiggerPos.AddZ(1),
+// SetSmoothLighting 开关平滑光照, 设置变化时重建所有已加载区块
+func (u *BlockUpdater) SetSmoothLighting(on bool) {
+	if u.smooth == on {
+		return
 	}
 
-	for _, pos := range updatePos {
-		u.updateBlock(pos)
+	u.smooth = on
+	for _, chunk := range u.world.cm.loadedChunkMap {
+		u.markChunk(*chunk.pos)
 	}
 }
 
-func (u *BlockUpdater) updateBlock(pos util.Pos) bool {
-	b, loaded := u.world.GetBlockByVec(pos.ToVec3())
-	if !loaded || b == nil {
-		return false
+// MarkDirty 标记坐标所在区段需要重建
+func (u *BlockUpdater) MarkDirty(pos world.Pos) {
+	chunk := u.world.cm.GetChunkByPos(pos)
+	if chunk == nil || !chunk.data.Dimension().InRange(pos.Y) {
+		return
 	}
 
-	leftVisible := !u.BlockExist(pos.SubX(1))
-	b.SetFaceVisible(blockv2.BlockFaceLeft, leftVisible)
-
-	rightVisible := !u.BlockExist(pos.AddX(1))
-	b.SetFaceVisible(blockv2.BlockFaceRight, rightVisible)
-
-	frontVisible := !u.BlockExist(pos.SubZ(1))
-	b.SetFaceVisible(blockv2.BlockFaceFront, frontVisible)
-
-	backVisible := !u.BlockExist(pos.AddZ(1))
-	b.SetFaceVisible(blockv2.BlockFaceBack, backVisible)
-
-	bottomVisible := !u.BlockExist(pos.SubY(1))
-	b.SetFaceVisible(blockv2.BlockFaceBottom, bottomVisible)
+	idx := int((pos.Y - chunk.data.MinY()) / world.SECTION_SIZE)
+	u.dirty[sectionKey{cpos: *chunk.pos, idx: idx}] = struct{}{}
+}
 
-	topVisible := !u.BlockExist(pos.AddY(1))
-	b.SetFaceVisible(blockv2.BlockFaceTop, topVisible)
+// MarkSection 标记区块的第 idx 个区段需要重建, 区块未加载或区段为空时忽略
+func (u *BlockUpdater) MarkSection(cpos ChunkPos, idx int) {
+	chunk := u.world.cm.Chunk(cpos)
+	if chunk == nil || !chunk.data.HasSection(idx) {
+		return
+	}
 
-	// if !u.BlockExist(pos.SubX(1)) || !u.BlockExist(pos.AddX(1)) ||
-	// 	!u.BlockExist(pos.SubY(1)) || !u.BlockExist(pos.AddY(1)) ||
-	// 	!u.BlockExist(pos.SubZ(1)) || !u.BlockExist(pos.AddZ(1)) {
-	// 	b.SetVisible(true)
-	// 	return true
-	// }
+	u.dirty[sectionKey{cpos: cpos, idx: idx}] = struct{}{}
+}
 
-	visible := leftVisible || rightVisible || frontVisible || backVisible || bottomVisible || topVisible
+func (u *BlockUpdater) markChunk(cpos ChunkPos) {
+	chunk := u.world.cm.Chunk(cpos)
+	if chunk == nil {
+		return
+	}
 
-	b.SetVisible(visible)
-	return visible
+	for i := 0; i < chunk.data.SectionCount(); i++ {
+		if chunk.data.HasSection(i) {
+			u.dirty[sectionKey{cpos: cpos, idx: i}] = struct{}{}
+		}
+	}
 }
 
-func (u *BlockUpdater) BlockExist(pos util.Pos) bool {
-	b, loaded := u.world.GetBlockByVec(pos.ToVec3())
-	return !loaded || b != nil || pos.Y < 0
+// buildSection 在后台生成区段网格, 完成后在渲染线程中替换区块的网格
+func (u *BlockUpdater) buildSection(key sectionKey) {
+	chunk := u.world.cm.Chunk(key.cpos)
+	if chunk == nil {
+		return
+	}
+
+	data, smooth := chunk.data, u.smooth
+	var m *mesher.Mesh
+	u.building[key] = struct{}{}
+	u.pool.Submit(&task{
+		name: "build section " + key.cpos.Id(),
+		run: func() {
+			mr := <-u.meshers
+			defer func() { u.meshers <- mr }()
+
+			mr.SetSmoothLighting(smooth)
+			m = mr.BuildSection(data, key.idx)
+		},
+		done: func() {
+			delete(u.building, key)
+			// 生成期间区块被卸载时丢弃
+			if chunk := u.world.cm.Chunk(key.cpos); m != nil && chunk != nil && chunk.data == data {
+				chunk.setSectionMesh(key.idx, m, u.world.mats)
+			}
+		},
+	})
 }
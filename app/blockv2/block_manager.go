@@ -3,19 +3,30 @@ package blockv2
 import (
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
 	"io/ioutil"
 
 	"github.com/g3n/engine/texture"
 	"github.com/g3n/engine/util/logger"
+	"github.com/weiWang95/mcworld/app/atlas"
+	"github.com/weiWang95/mcworld/app/world"
 )
 
+const DEFAULT_TEXTURE = "default.png"
+
 type BlockManager struct {
 	log     *logger.Logger
 	baseDir string
 	texDir  string
 
-	texMap   map[string]*texture.Texture2D
+	texNames []string       // 纹理序号 -> 文件名
+	texIndex map[string]int // 文件名 -> 纹理序号
 	blockMap map[BlockId]BlockAttr
+
+	atlas   *atlas.Atlas
+	regions []atlas.Region       // 纹理序号 -> 图集中的位置
+	pages   []*texture.Texture2D // 图集纹理, 首次使用时创建
 }
 
 func NewBlockManager(log *logger.Logger, baseDir string) *BlockManager {
@@ -24,7 +35,7 @@ func NewBlockManager(log *logger.Logger, baseDir string) *BlockManager {
 	m.baseDir = baseDir
 	m.texDir = fmt.Sprintf("%s/images/blocks", m.baseDir)
 
-	m.texMap = make(map[string]*texture.Texture2D)
+	m.texIndex = make(map[string]int)
 	m.blockMap = make(map[BlockId]BlockAttr)
 
 	m.init()
@@ -33,32 +44,59 @@ func NewBlockManager(log *logger.Logger, baseDir string) *BlockManager {
 }
 
 func (m *BlockManager) init() {
+	m.textureIndex(DEFAULT_TEXTURE)
 	m.initBlocks()
+	m.initAtlas()
 }
 
-func (m *BlockManager) NewBlock(id BlockId) *Block {
+func (m *BlockManager) GetBlockAttr(id BlockId) *BlockAttr {
 	attr, ok := m.blockMap[id]
-	if !ok {
-		attr = m.blockMap[0]
+	if ok {
+		return &attr
 	}
 
-	b := new(Block)
-	b.BlockAttr = &attr
+	return nil
+}
 
-	mesh := NewCube()
-	mesh.SetTextures(m.loadTextures(&attr))
-	b.IDrawable = mesh
+func (m *BlockManager) GetBlockLum(id BlockId) uint8 {
+	attr := m.GetBlockAttr(id)
+	if attr == nil {
+		return 0
+	}
 
-	return b
+	return attr.GetBlockLum()
 }
 
-func (m *BlockManager) GetBlockAttr(id BlockId) *BlockAttr {
+// GetBlockLight 实现 world.IBlockRegistry
+func (m *BlockManager) GetBlockLight(id BlockId) world.LightColor {
+	attr := m.GetBlockAttr(id)
+	if attr == nil {
+		return world.LightColor{}
+	}
+
+	return attr.GetBlockLight()
+}
+
+// IsTransparent 实现 world.IBlockRegistry, 未知的方块不透明
+func (m *BlockManager) IsTransparent(id BlockId) bool {
 	attr, ok := m.blockMap[id]
-	if ok {
-		return &attr
+	return ok && attr.IsTransparent()
+}
+
+// IsSolid 实现 world.IBlockRegistry, 未知的方块阻挡移动
+func (m *BlockManager) IsSolid(id BlockId) bool {
+	attr, ok := m.blockMap[id]
+	return !ok || attr.IsSolid()
+}
+
+// GetRenderType 实现 world.IBlockRegistry, 未知的方块绘制为立方体
+func (m *BlockManager) GetRenderType(id BlockId) world.RenderType {
+	attr, ok := m.blockMap[id]
+	if !ok {
+		return world.RenderCube
 	}
 
-	return nil
+	return attr.GetRenderType()
 }
 
 func (m *BlockManager) GetMaxStack(id BlockId) uint8 {
@@ -72,10 +110,64 @@ func (m *BlockManager) GetMaxStack(id BlockId) uint8 {
 
 func (m *BlockManager) initBlocks() {
 	for _, item := range m.loadBlockAttrs() {
+		if err := item.initLight(); err != nil {
+			m.log.Warn("block %s: %v", item.Name, err)
+		}
 		m.blockMap[item.Id] = item
+		for _, name := range item.Textures {
+			m.textureIndex(name)
+		}
 	}
 }
 
+// FaceTexture 实现 mesher.ITextureSource, 返回方块某个面的纹理序号.
+// 配置了 6 张纹理时按面取, 否则所有面使用第一张
+func (m *BlockManager) FaceTexture(id BlockId, face world.BlockFace) int {
+	attr, ok := m.blockMap[id]
+	if !ok || len(attr.Textures) == 0 {
+		return m.texIndex[DEFAULT_TEXTURE]
+	}
+
+	if len(attr.Textures) == 6 && face >= 0 {
+		return m.texIndex[attr.Textures[face]]
+	}
+
+	return m.texIndex[attr.Textures[0]]
+}
+
+// TextureRegion 实现 mesher.ITextureSource, 返回纹理在图集中的位置
+func (m *BlockManager) TextureRegion(idx int) atlas.Region {
+	if idx < 0 || idx >= len(m.regions) {
+		return m.regions[m.texIndex[DEFAULT_TEXTURE]]
+	}
+
+	return m.regions[idx]
+}
+
+// AtlasPage 返回第 page 张图集的纹理
+func (m *BlockManager) AtlasPage(page int) *texture.Texture2D {
+	if m.pages[page] == nil {
+		m.pages[page] = texture.NewTexture2DFromRGBA(m.atlas.Page(page))
+	}
+
+	return m.pages[page]
+}
+
+// DumpAtlas 将图集保存为 png 文件
+func (m *BlockManager) DumpAtlas(dir string) error {
+	return m.atlas.WritePNG(dir)
+}
+
+func (m *BlockManager) textureIndex(name string) int {
+	if idx, ok := m.texIndex[name]; ok {
+		return idx
+	}
+
+	m.texNames = append(m.texNames, name)
+	m.texIndex[name] = len(m.texNames) - 1
+	return len(m.texNames) - 1
+}
+
 func (m *BlockManager) loadBlockAttrs() []BlockAttr {
 	bytes, err := ioutil.ReadFile(fmt.Sprintf("%s/config/block.json", m.baseDir))
 	if err != nil {
@@ -94,36 +186,48 @@ func (m *BlockManager) loadBlockAttrs() []BlockAttr {
 	return data
 }
 
-func (m *BlockManager) loadTextures(attr *BlockAttr) []texture.Texture2D {
-	texs := make([]texture.Texture2D, 0, len(attr.Textures))
-	for _, item := range attr.Textures {
-		tex := m.loadTexture(item)
-		if tex == nil {
-			tex = m.defaultTexture()
+// initAtlas 将所有方块纹理打包为图集, 缺失的纹理使用默认纹理
+func (m *BlockManager) initAtlas() {
+	def := m.loadImage(DEFAULT_TEXTURE)
+	if def == nil {
+		// 默认纹理也不存在时使用纯色
+		img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		img.Set(0, 0, color.RGBA{R: 255, B: 255, A: 255})
+		def = img
+	}
+
+	b := atlas.NewBuilder(atlas.DefaultOptions)
+	for _, name := range m.texNames {
+		img := def
+		if name != DEFAULT_TEXTURE {
+			if loaded := m.loadImage(name); loaded != nil {
+				img = loaded
+			}
 		}
-		texs = append(texs, *tex)
+		b.Add(name, img)
 	}
-	return texs
-}
 
-func (m *BlockManager) defaultTexture() *texture.Texture2D {
-	return m.loadTexture("default.jpg")
-}
+	a, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	m.atlas = a
 
-func (m *BlockManager) loadTexture(name string) *texture.Texture2D {
-	tex, ok := m.texMap[name]
-	if ok {
-		return tex
+	m.regions = make([]atlas.Region, len(m.texNames))
+	for i, name := range m.texNames {
+		m.regions[i], _ = a.Region(name)
 	}
+	m.pages = make([]*texture.Texture2D, a.PageCount())
 
-	tex, err := texture.NewTexture2DFromImage(fmt.Sprintf("%s/%s", m.texDir, name))
+	m.log.Info("success, %d textures packed into %d atlas", len(m.texNames), a.PageCount())
+}
+
+func (m *BlockManager) loadImage(name string) image.Image {
+	img, err := atlas.LoadImage(fmt.Sprintf("%s/%s", m.texDir, name))
 	if err != nil {
-		m.log.Warn("missing texture:%s", name)
-		m.texMap[name] = nil
+		m.log.Warn("missing texture:%s, %v", name, err)
 		return nil
 	}
 
-	m.texMap[name] = tex
-
-	return tex
+	return img
 }
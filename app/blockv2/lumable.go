@@ -1,7 +1,18 @@
 package blockv2
 
+import (
+	"fmt"
+	"math"
+
+	"github.com/weiWang95/mcworld/app/world"
+)
+
 type Lumable struct {
 	Lum uint8 `json:"lum"`
+	// LumColor 光的颜色, 形如 "#ffb060", 为空时为白光
+	LumColor string `json:"lum_color"`
+
+	light world.LightColor
 }
 
 func (b *Lumable) GetLumable() bool {
@@ -11,3 +22,27 @@ func (b *Lumable) GetLumable() bool {
 func (b *Lumable) GetBlockLum() uint8 {
 	return b.Lum
 }
+
+// GetBlockLight 红绿蓝三个通道的亮度
+func (b *Lumable) GetBlockLight() world.LightColor {
+	return b.light
+}
+
+// initLight 由亮度和颜色计算各通道的亮度, 颜色无法解析时使用白光
+func (b *Lumable) initLight() error {
+	b.light = world.WhiteLight(b.Lum)
+	if b.LumColor == "" {
+		return nil
+	}
+
+	var r, g, bl uint8
+	if _, err := fmt.Sscanf(b.LumColor, "#%02x%02x%02x", &r, &g, &bl); err != nil {
+		return fmt.Errorf("invalid lum_color %q, %v", b.LumColor, err)
+	}
+
+	channel := func(c uint8) uint8 {
+		return uint8(math.Round(float64(b.Lum) * float64(c) / 255))
+	}
+	b.light = world.LightColor{R: channel(r), G: channel(g), B: channel(bl)}
+	return nil
+}
@@ -0,0 +1,24 @@
+package blockv2
+
+import "github.com/weiWang95/mcworld/app/world"
+
+type Renderable struct {
+	// RenderType 绘制方式, 0 为立方体, 1 为交叉的平面
+	RenderType world.RenderType `json:"render_type"`
+	// Transparent 透明的方块不遮挡相邻方块的面
+	Transparent bool `json:"transparent"`
+	// Passable 可以穿过的方块, 例如草和花
+	Passable bool `json:"passable"`
+}
+
+func (b *Renderable) GetRenderType() world.RenderType {
+	return b.RenderType
+}
+
+func (b *Renderable) IsTransparent() bool {
+	return b.Transparent
+}
+
+func (b *Renderable) IsSolid() bool {
+	return !b.Passable
+}
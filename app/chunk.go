@@ -9,7 +9,8 @@ import (
 	"github.com/g3n/engine/graphic"
 	"github.com/g3n/engine/material"
 	"github.com/g3n/engine/math32"
-	"github.com/weiWang95/mcworld/app/blockv2"
+	"github.com/weiWang95/mcworld/app/mesher"
+	"github.com/weiWang95/mcworld/app/world"
 	"github.com/weiWang95/mcworld/lib/util"
 )
 
@@ -23,8 +24,7 @@ const (
 )
 
 const (
-	CHUNK_WIDTH        int64 = 16
-	CHUNK_HEIGHT       int64 = 40
+	CHUNK_WIDTH              = world.CHUNK_WIDTH
 	CHUNK_UPDATE_RANGE int64 = 1
 )
 
@@ -37,9 +37,10 @@ type Chunk struct {
 	pos    *ChunkPos
 	actPos *math32.Vector3
 
-	blocks [CHUNK_HEIGHT][CHUNK_WIDTH][CHUNK_WIDTH]*blockv2.Block
-	lums   [CHUNK_HEIGHT][CHUNK_WIDTH][CHUNK_WIDTH]Luminance
-	axis   core.INode
+	data *world.Chunk
+	// 每个区段一个网格, 区段没有可见面时为 nil
+	sections []*graphic.Mesh
+	axis     core.INode
 }
 
 func NewChunk(x, z int64) *Chunk {
@@ -54,6 +55,14 @@ func NewChunk(x, z int64) *Chunk {
 	return c
 }
 
+// NewLoadedChunk 由已经读取或生成的区块数据创建区块
+func NewLoadedChunk(data *world.Chunk) *Chunk {
+	pos := data.Pos()
+	c := NewChunk(pos.X, pos.Z)
+	c.data = data
+	return c
+}
+
 func (c *Chunk) Start(a *App) {
 	c.setup(a)
 	c.SetVisible(false)
@@ -68,14 +77,8 @@ func (c *Chunk) Cleanup() {
 		c.axis.Dispose()
 	}
 
-	for y := int64(0); y < CHUNK_HEIGHT; y++ {
-		for x := int64(0); x < CHUNK_WIDTH; x++ {
-			for z := int64(0); z < CHUNK_WIDTH; z++ {
-				if c.blocks[y][x][z] != nil {
-					c.blocks[y][x][z].RemoveFrom(c)
-				}
-			}
-		}
+	for i := range c.sections {
+		c.removeSectionMesh(i)
 	}
 }
 
@@ -84,51 +87,18 @@ func (c *Chunk) setup(a *App) {
 	c.addAxis()
 }
 
+// Load 加载区块数据, 已有数据时直接使用, 区段网格由 BlockUpdater 生成
 func (c *Chunk) Load(a *App) {
-	data := a.SaveManager().LoadChunk(*c.pos)
-	if data != nil {
-		c.LoadFromSave(a, *data)
-		return
-	}
-
-	wg := a.World().WorldGenerator()
-
-	for y := int64(0); y < CHUNK_HEIGHT; y++ {
-		for x := int64(0); x < CHUNK_WIDTH; x++ {
-			for z := int64(0); z < CHUNK_WIDTH; z++ {
-				pos := math32.NewVector3(c.actPos.X+float32(x), float32(y), c.actPos.Z+float32(z))
-				id := wg.GetBlock(float64(pos.X), float64(pos.Y), float64(pos.Z))
-				if id != 0 {
-					b := a.bm.NewBlock(blockv2.BlockId(id))
-					b.SetPositionVec(pos)
-					// b := block.NewBlock(id, *pos)
-					c.blocks[y][x][z] = b
-					c.blocks[y][x][z].AddTo(c)
-				}
-			}
-		}
+	if c.data == nil {
+		c.data, _ = a.World().data.LoadChunk(*c.pos, a.SaveManager())
 	}
-
+	c.sections = make([]*graphic.Mesh, c.data.SectionCount())
 	c.State = Loaded
 }
 
-func (c *Chunk) LoadFromSave(a *App, data ChunkData) {
-	for y := int64(0); y < CHUNK_HEIGHT; y++ {
-		for x := int64(0); x < CHUNK_WIDTH; x++ {
-			for z := int64(0); z < CHUNK_WIDTH; z++ {
-				pos := math32.NewVector3(c.actPos.X+float32(x), float32(y), c.actPos.Z+float32(z))
-				d := data.GetBlock(int(x), int(y), int(z))
-				if d != nil {
-					// b := block.NewBlock(d.Id, *pos)
-					b := a.bm.NewBlock(blockv2.BlockId(d.Id))
-					b.SetPositionVec(pos)
-					c.blocks[y][x][z] = b
-					c.blocks[y][x][z].AddTo(c)
-				}
-			}
-		}
-	}
-	c.State = Loaded
+// Data 返回区块数据
+func (c *Chunk) Data() *world.Chunk {
+	return c.data
 }
 
 func (c *Chunk) Rendered(a *App) {
@@ -156,8 +126,8 @@ func (c *Chunk) addAxis() {
 	vertices.Append(
 		c.actPos.X, 0, c.actPos.Z,
 		c.actPos.X+float32(CHUNK_WIDTH), 0, c.actPos.Z,
-		c.actPos.X, 0, float32(c.actPos.Z),
-		c.actPos.X, float32(CHUNK_HEIGHT), c.actPos.Z,
+		c.actPos.X, float32(c.data.MinY()), float32(c.actPos.Z),
+		c.actPos.X, float32(c.data.MaxY()), c.actPos.Z,
 		c.actPos.X, 0, c.actPos.Z,
 		c.actPos.X, 0, c.actPos.Z+float32(CHUNK_WIDTH),
 	)
@@ -181,99 +151,67 @@ func (c *Chunk) addAxis() {
 	c.Add(c.axis)
 }
 
-func (c *Chunk) IsTransparent(x, y, z int64) bool {
-	return c.blocks[y][x][z] == nil || c.blocks[y][x][z].Transparent()
-}
-
-func (c *Chunk) convertWorldPos(x, y, z float32) util.Pos {
+func (c *Chunk) convertWorldPos(x, y, z float32) world.Pos {
 	bx := util.FloorFloat(x) - int64(c.actPos.X)
 	bz := util.FloorFloat(z) - int64(c.actPos.Z)
-	return util.NewPos(bx, int64(y), bz)
-}
-
-func (c *Chunk) GetWorldPos(x, y, z int64) util.Pos {
-	bx := c.pos.X*CHUNK_WIDTH + x
-	bz := c.pos.Z*CHUNK_WIDTH + z
-	return util.NewPos(bx, int64(y), bz)
-}
-
-func (c *Chunk) GetBlock(x, y, z float32) *blockv2.Block {
-	pos := c.convertWorldPos(x, y, z)
-	return c.getBlock(pos.X, pos.Y, pos.Z)
+	return world.NewPos(bx, util.FloorFloat(y), bz)
 }
 
-func (c *Chunk) posOverRange(x, y, z int64) bool {
-	return y < 0 || y >= CHUNK_HEIGHT ||
-		x < 0 || x >= CHUNK_WIDTH ||
-		z < 0 || z >= CHUNK_WIDTH
+func (c *Chunk) GetWorldPos(x, y, z int64) world.Pos {
+	return c.data.GetWorldPos(x, y, z)
 }
 
-func (c *Chunk) getBlock(x, y, z int64) *blockv2.Block {
-	if c.posOverRange(x, y, z) {
-		return nil
+// setSectionMesh 用新生成的网格替换第 idx 个区段的网格
+func (c *Chunk) setSectionMesh(idx int, m *mesher.Mesh, mats *SectionMaterials) {
+	c.removeSectionMesh(idx)
+	if m.IsEmpty() {
+		return
 	}
 
-	return c.blocks[y][x][z]
-}
+	geom := geometry.NewGeometry()
+	geom.AddVBO(gls.NewVBO(math32.ArrayF32(m.Positions)).AddAttrib(gls.VertexPosition))
+	geom.AddVBO(gls.NewVBO(math32.ArrayF32(m.Normals)).AddAttrib(gls.VertexNormal))
+	geom.AddVBO(gls.NewVBO(math32.ArrayF32(m.Uvs)).AddAttrib(gls.VertexTexcoord))
+	geom.AddVBO(gls.NewVBO(math32.ArrayF32(m.Tiles)).AddCustomAttrib("VertexTile", 4))
+	geom.AddVBO(gls.NewVBO(math32.ArrayF32(m.Lights)).AddCustomAttrib("VertexLight", 4))
+	geom.AddVBO(gls.NewVBO(math32.ArrayF32(m.Occlusion)).AddCustomAttrib("VertexOcclusion", 1))
+	geom.SetIndices(math32.ArrayU32(m.Indices))
 
-func (c *Chunk) getBlockByPos(pos util.Pos) *blockv2.Block {
-	return c.getBlock(pos.X, pos.Y, pos.Z)
-}
+	mesh := graphic.NewMesh(geom, nil)
+	for i, g := range m.Groups {
+		geom.AddGroup(g.Start, g.Count, i)
+		mesh.AddGroupMaterial(mats.Get(g.Page), i)
+	}
+	mesh.SetPosition(0, float32(c.data.SectionMinY(idx)), 0)
 
-func (c *Chunk) BlockPos(x, y, z int64) math32.Vector3 {
-	return *c.actPos.Clone().Add(math32.NewVector3(float32(x), float32(y), float32(z)))
+	c.Add(mesh)
+	c.sections[idx] = mesh
 }
 
-func (c *Chunk) ReplaceBlock(pos math32.Vector3, block *blockv2.Block) bool {
-	Instance().Log().Debug("replace block: pos -> %v, to -> %v", pos, block)
-
-	if pos.Y < 0 || pos.Y >= float32(CHUNK_HEIGHT) {
-		return false
+func (c *Chunk) removeSectionMesh(idx int) {
+	mesh := c.sections[idx]
+	if mesh == nil {
+		return
 	}
 
-	bx := util.FloorFloat(pos.X) - int64(c.actPos.X)
-	bz := util.FloorFloat(pos.Z) - int64(c.actPos.Z)
+	// 材质由 SectionMaterials 共享, 只释放几何体
+	mesh.ClearMaterials()
+	c.Remove(mesh)
+	mesh.Dispose()
+	c.sections[idx] = nil
+}
 
-	if bx < 0 || bx >= CHUNK_WIDTH || bz < 0 || bz >= CHUNK_WIDTH {
+// ReplaceBlock 修改方块数据, 网格由调用方标记区段后重建
+func (c *Chunk) ReplaceBlock(pos math32.Vector3, id world.BlockId) bool {
+	local := c.convertWorldPos(pos.X, pos.Y, pos.Z)
+	if c.data.PosOverRange(local.X, local.Y, local.Z) {
 		return false
 	}
 
-	b := c.blocks[int64(pos.Y)][bx][bz]
-	if b != nil {
-		b.RemoveFrom(c)
-	}
-
-	c.blocks[int64(pos.Y)][bx][bz] = block
-	if block != nil {
-		block.SetPositionVec(&pos)
-		block.AddTo(c)
-		block.SetVisible(true)
-	}
-
+	c.data.SetBlock(local.X, local.Y, local.Z, id)
 	return true
 }
 
-func (c *Chunk) GetLumByWorldPos(x, y, z float32) Luminance {
-	pos := c.convertWorldPos(x, y, z)
-	return c.GetLum(pos)
-}
-
-func (c *Chunk) GetLum(pos util.Pos) Luminance {
-	if c.posOverRange(pos.X, pos.Y, pos.Z) {
-		return 0
-	}
-
-	return c.lums[pos.Y][pos.X][pos.Z]
-}
-
-func (c *Chunk) SetLum(pos util.Pos, lum Luminance) {
-	c.lums[pos.Y][pos.X][pos.Z] = lum
-}
-
-func (c *Chunk) ConvertChunkPos(pos util.Pos) util.Pos {
-	return util.NewPos(pos.X-c.pos.X*CHUNK_WIDTH, pos.Y, pos.Z-c.pos.Z*CHUNK_WIDTH)
-}
-
 func (c *Chunk) RangePos(fn func(pos math32.Vector3) bool) {
 	if fn(*c.actPos) {
 		return
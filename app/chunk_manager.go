@@ -1,16 +1,20 @@
 package app
 
 import (
+	"runtime"
 	"time"
 
 	"github.com/g3n/engine/core"
 	"github.com/g3n/engine/math32"
 	"github.com/g3n/engine/util/logger"
-	"github.com/weiWang95/mcworld/lib/util"
+	"github.com/weiWang95/mcworld/app/world"
 )
 
 const MAX_ALIVE_TICK = 100
 
+// MAX_CHUNKS_PER_TICK 每次最多接收的已加载区块数, 避免一帧中加入过多区块
+const MAX_CHUNKS_PER_TICK = 4
+
 type UnloadingChunk struct {
 	*Chunk
 
@@ -27,7 +31,8 @@ type ChunkManager struct {
 	renderDistance int64
 
 	centerChunk       *Chunk
-	loadingChunkMap   map[string]ChunkPos
+	loader            *world.ChunkLoader
+	loadingChunkMap   map[string]ChunkPos // 已交给 loader 加载的区块
 	loadedChunkMap    map[string]*Chunk
 	UnloadingChunkMap map[string]*UnloadingChunk
 	renderedCount     int64
@@ -77,12 +82,23 @@ func (cm *ChunkManager) Update(a *App, t time.Duration) {
 	}
 }
 
+// Cleanup 停止后台加载, 需要在关闭存档之前调用
 func (cm *ChunkManager) Cleanup() {
+	if cm.loader != nil {
+		cm.loader.Close()
+	}
 }
 
 func (cm *ChunkManager) setup(a *App) {
 	cm.Logger = a.Log()
 
+	// 留一个核心给渲染线程
+	workers := runtime.NumCPU() - 1
+	if workers < 1 {
+		workers = 1
+	}
+	cm.loader = world.NewChunkLoader(a.World().data, a.SaveManager(), workers)
+
 	curPos := &math32.Vector3{}
 
 	player := a.Player()
@@ -131,6 +147,15 @@ func (cm *ChunkManager) checkAndLoadChunks(a *App, curPos *math32.Vector3) {
 		return
 	}
 
+	cm.loader.SetCenter(centerPos)
+	// 取消已经离开加载范围的区块
+	for posId, pos := range cm.loadingChunkMap {
+		if !cm.inLoadRange(centerPos, pos) {
+			cm.loader.Cancel(pos)
+			delete(cm.loadingChunkMap, posId)
+		}
+	}
+
 	// 将所有加载的区块标记为待卸载
 	willUnload := make(map[string]bool, len(cm.loadedChunkMap))
 	for _, c := range cm.loadedChunkMap {
@@ -147,10 +172,12 @@ func (cm *ChunkManager) checkAndLoadChunks(a *App, curPos *math32.Vector3) {
 			} else if chunk, ok := cm.UnloadingChunkMap[posId]; ok {
 				// 从正在卸载的区块中恢复
 				cm.loadedChunkMap[posId] = chunk.Chunk
+				cm.addToWorld(a, chunk.Chunk)
 				delete(cm.UnloadingChunkMap, posId)
 			} else {
 				// 加载新区块
 				cm.loadingChunkMap[posId] = pos
+				cm.loader.Request(pos)
 			}
 
 			chunk, ok := cm.loadedChunkMap[posId]
@@ -179,6 +206,7 @@ func (cm *ChunkManager) checkAndLoadChunks(a *App, curPos *math32.Vector3) {
 		// a.Log().Debug("will unload chunk: %v", posId)
 
 		cm.UnloadingChunkMap[posId] = &UnloadingChunk{Chunk: cm.loadedChunkMap[posId]}
+		a.World().data.RemoveChunk(*cm.loadedChunkMap[posId].pos)
 		delete(cm.loadedChunkMap, posId)
 	}
 
@@ -191,7 +219,7 @@ func (cm *ChunkManager) StepUnloadChunk(a *App) {
 	for key, uc := range cm.UnloadingChunkMap {
 		if uc.AliveTick >= MAX_ALIVE_TICK && (!unloaded || uc.AliveTick >= 2*MAX_ALIVE_TICK) {
 			// a.Log().Debug("unload chunk from mem: %v", uc.pos)
-			a.SaveManager().SaveChunk(uc.Chunk)
+			a.SaveManager().SaveChunk(uc.Data())
 			// 卸载区块
 			cm.Remove(uc)
 			uc.Cleanup()
@@ -204,30 +232,49 @@ func (cm *ChunkManager) StepUnloadChunk(a *App) {
 	}
 }
 
+func (cm *ChunkManager) inLoadRange(center, pos ChunkPos) bool {
+	dx, dz := pos.X-center.X, pos.Z-center.Z
+	return dx >= -cm.loadDistance && dx <= cm.loadDistance && dz >= -cm.loadDistance && dz <= cm.loadDistance
+}
+
+// StepLoadChunk 接收后台加载完成的区块并加入世界, 网格在之后由 BlockUpdater 生成
 func (cm *ChunkManager) StepLoadChunk(a *App) {
-	if len(cm.loadingChunkMap) == 0 {
-		return
+	for i := 0; i < MAX_CHUNKS_PER_TICK; i++ {
+		select {
+		case data := <-cm.loader.Results():
+			cm.addLoadedChunk(a, data)
+		default:
+			return
+		}
 	}
-	// a.Log().Debug("loading chunk count: %v", len(cm.loadingChunkMap))
+}
 
-	playerPos := a.Player().GetPosition()
-	chunkPos := make([]ChunkPos, 0, len(cm.loadingChunkMap))
-	for _, p := range cm.loadingChunkMap {
-		chunkPos = append(chunkPos, p)
+func (cm *ChunkManager) addLoadedChunk(a *App, data *world.Chunk) {
+	pos := data.Pos()
+	// 取消后仍然送达的区块
+	if _, ok := cm.loadingChunkMap[pos.Id()]; !ok {
+		return
 	}
-	nearestPos := cm.GetNearestChunk(*playerPos, chunkPos)
-
-	// a.Log().Debug("load chunk: %v", nearestPos)
+	delete(cm.loadingChunkMap, pos.Id())
 
-	chunk := NewChunk(nearestPos.X, nearestPos.Z)
+	chunk := NewLoadedChunk(data)
 	chunk.Start(a)
 	cm.Add(chunk)
-	a.SaveManager().SaveChunk(chunk)
-	cm.loadedChunkMap[nearestPos.Id()] = chunk
-	delete(cm.loadingChunkMap, nearestPos.Id())
+	cm.loadedChunkMap[pos.Id()] = chunk
+	cm.addToWorld(a, chunk)
 
 	a.World().bu.RefreshChunkBlocks(chunk)
-	a.World().lu.AddWaitLumChunk(nearestPos)
+	a.World().lu.LoadChunkLum(pos)
+}
+
+// addToWorld 将区块数据加入世界, 装饰物或暂存写入改变了方块的区块需要重建网格和光照
+func (cm *ChunkManager) addToWorld(a *App, chunk *Chunk) {
+	for _, cpos := range a.World().data.AddChunk(chunk.Data()) {
+		if c := cm.Chunk(cpos); c != nil {
+			a.World().bu.RefreshChunkBlocks(c)
+			a.World().lu.AddWaitLumChunk(cpos)
+		}
+	}
 }
 
 func (cm *ChunkManager) Chunk(cpos ChunkPos) *Chunk {
@@ -239,18 +286,17 @@ func (cm *ChunkManager) GetChunk(x, y, z float32) *Chunk {
 	return cm.Chunk(pos)
 }
 
-func (cm *ChunkManager) GetChunkByPos(pos util.Pos) *Chunk {
-	p := pos.ToVec3()
-	return cm.GetChunk(p.X, p.X, p.Z)
+func (cm *ChunkManager) GetChunkByPos(pos world.Pos) *Chunk {
+	return cm.Chunk(pos.ChunkPos())
 }
 
 func (cm *ChunkManager) SaveAll() {
 	for _, item := range cm.loadedChunkMap {
-		Instance().SaveManager().SaveChunk(item)
+		cm.app.SaveManager().SaveChunk(item.Data())
 	}
 
 	for _, item := range cm.UnloadingChunkMap {
-		Instance().SaveManager().SaveChunk(item.Chunk)
+		cm.app.SaveManager().SaveChunk(item.Data())
 	}
 }
 
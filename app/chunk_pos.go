@@ -1,20 +1,12 @@
 package app
 
 import (
-	"fmt"
-
 	"github.com/g3n/engine/math32"
+	"github.com/weiWang95/mcworld/app/world"
 	"github.com/weiWang95/mcworld/lib/util"
 )
 
-type ChunkPos struct {
-	X int64
-	Z int64
-}
-
-func (c ChunkPos) Id() string {
-	return fmt.Sprintf("%d-%d", c.X, c.Z)
-}
+type ChunkPos = world.ChunkPos
 
 func ToChunkPos(pos *math32.Vector3) ChunkPos {
 	x := util.FloorFloat(pos.X / float32(CHUNK_WIDTH))
@@ -22,3 +14,8 @@ func ToChunkPos(pos *math32.Vector3) ChunkPos {
 
 	return ChunkPos{X: x, Z: z}
 }
+
+// ToWorldPos 将场景坐标转换为方块坐标
+func ToWorldPos(pos math32.Vector3) world.Pos {
+	return world.NewPos(util.FloorFloat(pos.X), util.FloorFloat(pos.Y), util.FloorFloat(pos.Z))
+}
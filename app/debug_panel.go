@@ -5,8 +5,7 @@ import (
 
 	"github.com/g3n/engine/gui"
 	"github.com/g3n/engine/math32"
-	"github.com/weiWang95/mcworld/app/block"
-	"github.com/weiWang95/mcworld/app/blockv2"
+	"github.com/weiWang95/mcworld/app/world"
 )
 
 var transparentColor = math32.Color4{0, 0, 0, 0.1}
@@ -99,38 +98,55 @@ func (p *DebugPanel) update() {
 	lum, _ := p.app.World().GetLum(pos.X, pos.Y, pos.Z)
 
 	p.chunk.SetText(fmt.Sprintf("R:%d U:%d", p.app.curWorld.cm.renderedCount, p.app.curWorld.cm.unrenderedCount))
-	p.pos.SetText(fmt.Sprintf("%s %s", p.formatPos(*pos), p.formatLum(lum)))
+	p.pos.SetText(fmt.Sprintf("%s %s %s", p.formatPos(*pos), p.formatLum(lum), p.formatBiome(ToWorldPos(*pos))))
 	p.viewPort.SetText(p.formatPos(*player.GetViewport()))
 	p.camera.SetText(p.formatPos(player.Camera.Position()))
 	p.farPos.SetText(p.formatPos(player.farPos))
 
 	targetPos := player.Target.Position()
+	targetId, _ := p.app.World().Data().GetBlock(ToWorldPos(targetPos))
 	targetLum, _ := p.app.World().GetLum(targetPos.X, targetPos.Y, targetPos.Z)
 	targetTopLum, _ := p.app.World().GetLum(targetPos.X, targetPos.Y+1, targetPos.Z)
-	p.target.SetText(fmt.Sprintf("T: %T V:%v P:%s %s %s, PT: %s", player.Target.b, player.Target.Visible(), p.formatPos(targetPos), p.formatLum(targetLum), p.formatFaceLum(player.Target.b), p.formatLum(targetTopLum)))
+	p.target.SetText(fmt.Sprintf("T: %d V:%v P:%s %s %s, PT: %s", targetId, player.Target.Visible(), p.formatPos(targetPos), p.formatLum(targetLum), p.formatFaceLum(player.Target.block), p.formatLum(targetTopLum)))
+}
+
+func (p *DebugPanel) formatBiome(pos world.Pos) string {
+	id, _ := p.app.World().Data().GetBiome(pos)
+	if b := world.GetBiome(id); b != nil {
+		return b.Name
+	}
+	return "-"
 }
 
 func (p *DebugPanel) formatPos(pos math32.Vector3) string {
 	return fmt.Sprintf("X: %.1f, Y: %.1f, Z: %.1f", pos.X, pos.Y, pos.Z)
 }
 
-func (p *DebugPanel) formatLum(lum Luminance) string {
-	return fmt.Sprintf("Lum:[S:%v, B:%v]", lum.SunLum(), lum.BlockLum())
+func (p *DebugPanel) formatLum(lum world.Luminance) string {
+	c := lum.BlockColor()
+	return fmt.Sprintf("Lum:[S:%v, B:%v,%v,%v]", lum.SunLum(), c.R, c.G, c.B)
 }
 
-func (p *DebugPanel) formatFaceLum(b *blockv2.Block) string {
-	if b == nil {
+// formatFaceLum 方块各个面的光照, 即各个面相邻位置的光照
+func (p *DebugPanel) formatFaceLum(blockPos *math32.Vector3) string {
+	if blockPos == nil {
 		return ""
 	}
 
+	var lums [6]uint8
+	ToWorldPos(*blockPos).RangeAdjoin(func(pos world.Pos, face world.BlockFace) {
+		lum, _ := p.app.World().Data().GetLum(pos)
+		lums[face] = p.app.World().lu.CurLum(lum)
+	})
+
 	return fmt.Sprintf(
 		"Face Lum:[F:%d B:%d L:%d R:%d T:%d B:%d]",
-		b.GetFaceLum(int(block.BlockFaceFront)),
-		b.GetFaceLum(int(block.BlockFaceBack)),
-		b.GetFaceLum(int(block.BlockFaceLeft)),
-		b.GetFaceLum(int(block.BlockFaceRight)),
-		b.GetFaceLum(int(block.BlockFaceTop)),
-		b.GetFaceLum(int(block.BlockFaceBottom)),
+		lums[world.BlockFaceFront],
+		lums[world.BlockFaceBack],
+		lums[world.BlockFaceLeft],
+		lums[world.BlockFaceRight],
+		lums[world.BlockFaceTop],
+		lums[world.BlockFaceBottom],
 	)
 }
 
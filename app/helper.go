@@ -5,14 +5,9 @@ import (
 
 	"github.com/g3n/engine/math32"
 	"github.com/weiWang95/mcworld/app/block"
-	"github.com/weiWang95/mcworld/app/blockv2"
 	"github.com/weiWang95/mcworld/lib/util"
 )
 
-func PosOverRange(pos util.Pos) bool {
-	return pos.Y < 0 || pos.Y >= CHUNK_HEIGHT
-}
-
 // 获取射线与X平面焦点
 func GetIntermediateWithX(start, end math32.Vector3, x float32) *math32.Vector3 {
 	return GetIntermediate(start, end, &x, nil, nil)
@@ -182,7 +177,8 @@ func CollisionRayTrace(box *BoundBox, start, end math32.Vector3) *math32.Vector3
 	return hitPos
 }
 
-func RayTraceBlock(world *World, start, end math32.Vector3) (*blockv2.Block, *math32.Vector3) {
+// RayTraceBlock 沿射线查找第一个方块, 返回方块的最低角坐标和射线与方块的交点
+func RayTraceBlock(world *World, start, end math32.Vector3) (*math32.Vector3, *math32.Vector3) {
 	// Instance().log.Debug("start ray trace block! start:%v, end:%v", start, end)
 
 	startX, startY, startZ := util.FloorFloat(start.X), util.FloorFloat(start.Y), util.FloorFloat(start.Z)
@@ -192,8 +188,7 @@ func RayTraceBlock(world *World, start, end math32.Vector3) (*blockv2.Block, *ma
 		// Instance().log.Debug("start check block -> %v, %v, %v", startX, startY, startZ)
 		// 检测到终点方块
 		if startX == endX && startY == endY && startZ == endZ {
-			b, _ := world.GetBlockByPosition(end.X, end.Y, end.Z)
-			return b, nil
+			return nil, nil
 		}
 
 		xChanged, yChanged, zChanged := true, true, true
@@ -284,34 +279,21 @@ func RayTraceBlock(world *World, start, end math32.Vector3) (*blockv2.Block, *ma
 			startZ -= 1
 		}
 
-		block, _ := world.GetBlockByPosition(float32(startX), float32(startY), float32(startZ))
-		if block == nil {
+		blockPos := math32.NewVector3(float32(startX), float32(startY), float32(startZ))
+		if !world.HasBlock(*blockPos) {
 			continue
 		}
 
 		box := NewBlockBoundBox(startX, startY, startZ)
 		pos := CollisionRayTrace(box, start, end)
 		if pos != nil {
-			return block, pos
+			return blockPos, pos
 		}
 	}
 
 	return nil, nil
 }
 
-// BlockIsTransparent 方块是否透明
-func BlockIsTransparent(block *blockv2.Block, chunkLoaded bool) bool {
-	if !chunkLoaded {
-		return false
-	}
-
-	if block == nil {
-		return true
-	}
-
-	return block.Transparent()
-}
-
 func GetBlockFace(pos math32.Vector3, hit math32.Vector3) block.BlockFace {
 	dx, dy, dz := hit.X-pos.X, hit.Y-pos.Y, hit.Z-pos.Z
 
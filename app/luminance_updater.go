@@ -1,24 +1,26 @@
 package app
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/g3n/engine/util/logger"
-	"github.com/weiWang95/mcworld/app/block"
-	"github.com/weiWang95/mcworld/app/blockv2"
-	"github.com/weiWang95/mcworld/lib/util"
+	"github.com/weiWang95/mcworld/app/world"
 )
 
+// MAX_LUM_RESULTS_PER_TICK 每帧最多处理的光照计算结果数
+const MAX_LUM_RESULTS_PER_TICK = 16
+
+// LuminanceUpdater 调度光照计算, 光照变化的区段交给 BlockUpdater 重建网格, 光照计算由 world.World 完成.
+// 光照更新需要依次执行, 全部交给一个后台协程, 不占用渲染线程
 type LuminanceUpdater struct {
 	app   *App
 	world *World
 	log   *logger.Logger
 
-	waitLumMap   map[string]ChunkPos
-	switchLumMap map[string]ChunkPos
-
-	lumTicker       *TickChecker
-	lumSwitchTicker *TickChecker
+	pool       *taskPool
+	waitLumMap map[ChunkPos]struct{} // 等待重新计算光照的区块
+	running    map[ChunkPos]struct{} // 正在计算的区块, 完成前再次加入的区块留在 waitLumMap 中
 }
 
 func NewLuminanceUpdater(app *App) *LuminanceUpdater {
@@ -27,336 +29,87 @@ func NewLuminanceUpdater(app *App) *LuminanceUpdater {
 	u.world = app.World()
 	u.log = app.Log()
 
-	u.lumTicker = NewTickChecker(4)
-	u.lumSwitchTicker = NewTickChecker(2)
-
-	u.waitLumMap = make(map[string]ChunkPos)
+	u.pool = newTaskPool(u.log, 1)
+	u.waitLumMap = make(map[ChunkPos]struct{})
+	u.running = make(map[ChunkPos]struct{})
 
 	return u
 }
 
 func (u *LuminanceUpdater) Update(app *App, t time.Duration) {
-	if u.lumTicker.Next(t) {
-		u.StepInitLum()
-	}
-	if u.lumSwitchTicker.Next(t) {
-		u.StepSwitchDayNight()
-	}
-}
-
-func (u *LuminanceUpdater) AddWaitLumChunk(cpos ChunkPos) {
-	u.waitLumMap[cpos.Id()] = cpos
-}
-
-func (u *LuminanceUpdater) StepInitLum() {
-	if len(u.waitLumMap) == 0 {
-		return
-	}
-	// Instance().Log().Debug("wait init lum chunks:%d", len(u.waitLumMap))
-
-	for key, cpos := range u.waitLumMap {
-		// Instance().Log().Debug("init lum chunk:%v", cpos)
-
-		u.InitChunkLum(cpos)
-
-		delete(u.waitLumMap, key)
-		break
-	}
-}
-
-func (u *LuminanceUpdater) InitChunkLum(cpos ChunkPos) {
-	chunk := u.world.cm.Chunk(cpos)
-	if chunk == nil {
-		return
-	}
-
-	updateMap := u.initChunkSunLum(chunk)
-	u.updateBlocksLum(updateMap)
-}
-
-func (u *LuminanceUpdater) initChunkSunLum(chunk *Chunk) map[string]util.Pos {
-	updateMap := make(map[string]util.Pos, CHUNK_WIDTH*CHUNK_WIDTH*CHUNK_HEIGHT)
-
-	for z := int64(0); z < CHUNK_WIDTH; z++ {
-		for x := int64(0); x < CHUNK_WIDTH; x++ {
-			sunLum := u.world.sunLevel
-
-			for y := CHUNK_HEIGHT - 1; y >= 0; y-- {
-				pos := util.NewPos(x, y, z)
-
-				cur := chunk.GetLum(pos)
-				cur = cur.SetSunLum(sunLum)
-
-				b := chunk.getBlockByPos(pos)
-				if b != nil {
-					cur = cur.SetSunLum(0)
-				}
-				chunk.SetLum(pos, cur)
-
-				wPos := chunk.GetWorldPos(x, y, z)
-				updateMap[wPos.GetId()] = wPos
-			}
-		}
-	}
-
-	return updateMap
+	u.pool.Finish(MAX_LUM_RESULTS_PER_TICK)
+	u.StepInitLum()
 }
 
-func (u *LuminanceUpdater) UpdateSumLum() {
-	u.switchLumMap = map[string]ChunkPos{}
-	for _, c := range u.world.cm.loadedChunkMap {
-		u.AddWaitLumChunk(*c.pos)
-	}
+// Cleanup 停止后台光照计算
+func (u *LuminanceUpdater) Cleanup() {
+	u.pool.Close()
 }
 
-func (u *LuminanceUpdater) SwitchDayNight() {
-	u.switchLumMap = map[string]ChunkPos{}
-	for _, c := range u.world.cm.loadedChunkMap {
-		u.switchLumMap[c.pos.Id()] = *c.pos
-	}
+func (u *LuminanceUpdater) AddWaitLumChunk(cpos ChunkPos) {
+	u.waitLumMap[cpos] = struct{}{}
 }
 
-func (u *LuminanceUpdater) StepSwitchDayNight() {
-	if len(u.switchLumMap) == 0 {
-		return
-	}
-	// Instance().Log().Debug("wait switch lum chunks:%d", len(u.switchLumMap))
-
-	playerPos := u.app.Player().GetPosition()
-	chunkPos := make([]ChunkPos, 0, len(u.switchLumMap))
-	for _, p := range u.switchLumMap {
-		chunkPos = append(chunkPos, p)
-	}
-	nearestPos := u.world.cm.GetNearestChunk(*playerPos, chunkPos)
-	for key, cpos := range u.switchLumMap {
-		// Instance().Log().Debug("switch lum chunk:%v", cpos)
-		if cpos.X != nearestPos.X || cpos.Z != nearestPos.Z {
+// StepInitLum 把等待的区块交给后台计算光照
+func (u *LuminanceUpdater) StepInitLum() {
+	for cpos := range u.waitLumMap {
+		if _, ok := u.running[cpos]; ok {
 			continue
 		}
-
-		chunk := u.world.cm.Chunk(cpos)
-		if chunk != nil {
-			u.refreshChunkLum(chunk)
-		}
-
-		delete(u.switchLumMap, key)
-		break
-	}
-}
-
-func (u *LuminanceUpdater) refreshChunkLum(chunk *Chunk) {
-	for y := int64(0); y < CHUNK_HEIGHT; y++ {
-		for x := int64(0); x < CHUNK_WIDTH; x++ {
-			for z := int64(0); z < CHUNK_WIDTH; z++ {
-				u.refreshBlockLum(chunk.GetWorldPos(x, y, z))
-			}
-		}
-	}
-}
-
-func (u *LuminanceUpdater) TiggerUpdate(pos util.Pos) {
-	b, loaded := u.world.GetBlockByVec(pos.ToVec3())
-	if !loaded {
-		return
-	}
-	hasBlock := b != nil
-	if hasBlock {
-		u.setLum(pos, u.getLum(pos).SetSunLum(0))
-	}
-
-	// 阳光直射
-	isBeat := true
-	if pos.Y+1 < CHUNK_HEIGHT {
-		topLum, _ := u.world.GetLumByVec(pos.AddY(1).ToVec3())
-		isBeat = topLum.SunLum() == MAX_LUM
-	}
-
-	updates := make(map[string]util.Pos)
-	updates[pos.GetId()] = pos
-	if pos.Y+1 < CHUNK_HEIGHT {
-		topPos := pos.AddY(1)
-		updates[topPos.GetId()] = topPos
-	}
-	if pos.Y-1 >= 0 {
-		bottomPos := pos.SubY(1)
-		updates[bottomPos.GetId()] = bottomPos
-	}
-
-	for {
-		pos = pos.SubY(1)
-
-		updates[pos.GetId()] = pos
-		b, _ := u.world.GetBlockByVec(pos.ToVec3())
-		if b != nil {
-			break
-		}
-
-		// 更新垂直方向方块阳光强度
-		if isBeat && hasBlock {
-			u.setLum(pos, u.getLum(pos).SetSunLum(0))
-		} else if isBeat && !hasBlock {
-			u.setLum(pos, u.getLum(pos).SetSunLum(MAX_LUM))
-		}
-
-		if pos.Y-1 <= 0 {
-			break
-		}
-	}
-	u.updateBlocksLum(updates)
-}
-
-func (u *LuminanceUpdater) updateBlocksLum(updateMap map[string]util.Pos) {
-	refreshBlockMap := make(map[string]util.Pos)
-
-	for i := 0; i < 16; i++ {
-		newMap := make(map[string]util.Pos)
-		for _, p := range updateMap {
-			for _, item := range u.affectedBlocks(p) {
-				refreshBlockMap[item.GetId()] = item
-			}
-
-			for _, item := range u.updateLum(p, i) {
-				newMap[item.GetId()] = item
-			}
-		}
-
-		if len(newMap) == 0 {
-			break
-		}
-
-		updateMap = newMap
-	}
-
-	for _, item := range refreshBlockMap {
-		u.refreshBlockLum(item)
+		u.InitChunkLum(cpos)
+		delete(u.waitLumMap, cpos)
 	}
 }
 
-func (u *LuminanceUpdater) updateLum(pos util.Pos, times int) []util.Pos {
-	b, loaded := u.world.GetBlockByVec(pos.ToVec3())
-	if !loaded {
-		return nil
-	}
-	cur := u.getLum(pos)
-	oldLum := cur
-
-	if b != nil {
-		cur = NewLuminance(0, b.GetBlockLum())
-		u.setLum(pos, cur)
-	} else {
-		max := u.getNearbyMaxLum(pos)
-		maxSunLum, maxBlockLum := max.SunLum(), max.BlockLum()
-		if cur.SunLum() != MAX_LUM {
-			if maxSunLum > 0 {
-				cur = cur.SetSunLum(maxSunLum - 1)
-			} else {
-				cur = cur.SetSunLum(0)
-			}
-			u.setLum(pos, cur)
-		}
-
-		if maxBlockLum > 0 {
-			cur = cur.SetBlockLum(maxBlockLum - 1)
-		} else {
-			cur = cur.SetBlockLum(0)
-		}
-		u.setLum(pos, cur)
-	}
-
-	if (b == nil || !b.GetLumable()) && oldLum == cur {
-		return nil
-	}
-
-	updates := make([]util.Pos, 0)
-	pos.RangeAdjoin(func(p util.Pos, face block.BlockFace) {
-		if u.needUpdateLum(p, cur) {
-			updates = append(updates, p)
-		}
+// LoadChunkLum 区块加载后调用, 存档中的光照有效时只检查区块边界, 否则重新计算
+func (u *LuminanceUpdater) LoadChunkLum(cpos ChunkPos) {
+	u.submit("load chunk lum "+cpos.Id(), func() []world.SectionPos {
+		return u.world.data.LoadChunkLum(cpos)
 	})
-
-	return updates
 }
 
-func (u *LuminanceUpdater) setLum(pos util.Pos, lum Luminance) {
-	chunk := u.world.cm.GetChunkByPos(pos)
-	if chunk == nil {
-		return
-	}
-
-	chunk.SetLum(chunk.ConvertChunkPos(pos), lum)
+func (u *LuminanceUpdater) InitChunkLum(cpos ChunkPos) {
+	var sections []world.SectionPos
+	u.running[cpos] = struct{}{}
+	u.pool.Submit(&task{
+		name: "init chunk lum " + cpos.Id(),
+		run:  func() { sections = u.world.data.InitChunkLum(cpos) },
+		done: func() {
+			delete(u.running, cpos)
+			u.refreshSections(sections)
+		},
+	})
 }
 
-func (u *LuminanceUpdater) getLum(pos util.Pos) Luminance {
-	lum, loaded := u.world.GetLumByVec(pos.ToVec3())
-	if !loaded {
-		return NewLuminance(0, 0)
-	}
-
-	return lum
+// SwitchDayNight 保存的阳光不随时间变化, 顶点中分别保存阳光与方块光, 昼夜变化只需更新着色器的天空亮度
+func (u *LuminanceUpdater) SwitchDayNight() {
+	u.world.mats.SetSkyLight(u.world.SunLumRate())
 }
 
-func (u *LuminanceUpdater) getNearbyMaxLum(pos util.Pos) Luminance {
-	var max Luminance
-
-	pos.RangeAdjoin(func(p util.Pos, face block.BlockFace) {
-		max = MaxLum(u.getLum(p), max)
+func (u *LuminanceUpdater) TiggerUpdate(pos world.Pos) {
+	u.submit(fmt.Sprintf("lum update %v", pos), func() []world.SectionPos {
+		return u.world.data.TiggerLumUpdate(pos)
 	})
-
-	return max
 }
 
-func (u *LuminanceUpdater) needUpdateLum(pos util.Pos, l Luminance) bool {
-	if PosOverRange(pos) {
-		return false
-	}
-
-	b, loaded := u.world.GetBlockByVec(pos.ToVec3())
-	if !loaded || b != nil {
-		return false
-	}
-
-	lum, loaded := u.world.GetLumByVec(pos.ToVec3())
-	if !loaded {
-		return false
-	}
-
-	return (l.SunLum() != lum.SunLum() || l.SunLum() != 0) || (l.BlockLum() != lum.BlockLum())
-}
-
-func (u *LuminanceUpdater) affectedBlocks(pos util.Pos) []util.Pos {
-	arr := make([]util.Pos, 0)
-	_, loaded := u.world.GetBlockByVec(pos.ToVec3())
-	if loaded {
-		arr = append(arr, pos)
-	}
-
-	pos.RangeAdjoin(func(p util.Pos, face block.BlockFace) {
-		_, loaded := u.world.GetBlockByVec(p.ToVec3())
-		if loaded && !PosOverRange(p) {
-			arr = append(arr, p)
-		}
+// submit 在后台执行光照更新, 完成后重建光照变化的区段
+func (u *LuminanceUpdater) submit(name string, update func() []world.SectionPos) {
+	var sections []world.SectionPos
+	u.pool.Submit(&task{
+		name: name,
+		run:  func() { sections = update() },
+		done: func() { u.refreshSections(sections) },
 	})
-
-	return arr
 }
 
-func (u *LuminanceUpdater) refreshBlockLum(pos util.Pos) {
-	b, loaded := u.world.GetBlockByVec(pos.ToVec3())
-	if !loaded || b == nil || !b.Visible() {
-		return
+// refreshSections 标记光照发生变化的区段需要重建网格
+func (u *LuminanceUpdater) refreshSections(sections []world.SectionPos) {
+	for _, sp := range sections {
+		u.world.bu.MarkSection(sp.Chunk, sp.Index)
 	}
-
-	pos.RangeAdjoin(func(p util.Pos, face block.BlockFace) {
-		faceBlock, loaded := u.world.GetBlockByVec(p.ToVec3())
-		if loaded && faceBlock == nil {
-			// b.SetLum(u.CurLum(u.getLum(p)), int(face))
-			b.SetFaceLum(blockv2.BlockFace(face), u.CurLum(u.getLum(p)))
-		}
-	})
-	// b.RefreshLum()
 }
 
-func (u *LuminanceUpdater) CurLum(l Luminance) uint8 {
+func (u *LuminanceUpdater) CurLum(l world.Luminance) uint8 {
 	sun := uint8(float32(l.SunLum()) * u.world.SunLumRate())
 	if sun > l.BlockLum() {
 		return sun
@@ -0,0 +1,85 @@
+package mesher
+
+import "github.com/weiWang95/mcworld/app/world"
+
+// aoShade 环境光遮蔽等级对应的明暗, 下标为 cornerLight.ao
+var aoShade = [4]float32{0.5, 0.65, 0.8, 1}
+
+// lightSample 顶点周围一个方块的光照与占用情况
+type lightSample struct {
+	lum world.Luminance
+	// open 可见的透明方块, 光照参与平均
+	open bool
+	// solid 遮挡环境光
+	solid bool
+}
+
+// cornerLight 面的一个顶点的光照, 为周围透光方块的光照之和与数量, 以及环境光遮蔽等级.
+// 保存整数便于比较, 相同的顶点才能合并
+type cornerLight struct {
+	sun     uint8
+	r, g, b uint8
+	count   uint8
+	ao      uint8 // 0~3, 3 为没有遮挡
+}
+
+// newCornerLight 由面前方的方块、顶点两侧的方块和对角的方块计算顶点光照.
+// 两侧都被挡住时对角的方块看不到, 不参与计算
+func newCornerLight(front, side1, side2, corner lightSample) cornerLight {
+	var c cornerLight
+	c.ao = vertexAO(side1.solid, side2.solid, corner.solid)
+
+	samples := []lightSample{front, side1, side2}
+	if !side1.solid || !side2.solid {
+		samples = append(samples, corner)
+	}
+	for _, s := range samples {
+		if !s.open {
+			continue
+		}
+		block := s.lum.BlockColor()
+		c.sun += s.lum.SunLum()
+		c.r += block.R
+		c.g += block.G
+		c.b += block.B
+		c.count++
+	}
+
+	return c
+}
+
+// vertexAO 顶点的环境光遮蔽等级, 由两侧和对角方块是否实心决定
+func vertexAO(side1, side2, corner bool) uint8 {
+	if side1 && side2 {
+		return 0
+	}
+
+	ao := uint8(3)
+	for _, solid := range []bool{side1, side2, corner} {
+		if solid {
+			ao--
+		}
+	}
+	return ao
+}
+
+// light 顶点的光照等级与环境光遮蔽明暗. 未计算平滑光照或周围没有透光方块时使用面的光照 lum
+func (c cornerLight) light(lum world.Luminance) (light [4]float32, ao float32) {
+	if c == (cornerLight{}) {
+		return lumLevels(lum), 1
+	}
+
+	ao = aoShade[c.ao]
+	if c.count == 0 {
+		return lumLevels(lum), ao
+	}
+
+	n := float32(c.count)
+	return [4]float32{float32(c.sun) / n, float32(c.r) / n, float32(c.g) / n, float32(c.b) / n}, ao
+}
+
+// lumLevels 阳光与红绿蓝方块光的等级
+func lumLevels(lum world.Luminance) [4]float32 {
+	c := lum.BlockColor()
+	return [4]float32{float32(lum.SunLum()), float32(c.R), float32(c.G), float32(c.B)}
+}
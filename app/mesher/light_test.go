@@ -0,0 +1,121 @@
+package mesher
+
+import (
+	"testing"
+
+	"github.com/weiWang95/mcworld/app/world"
+)
+
+func TestVertexAO(t *testing.T) {
+	cases := []struct {
+		side1, side2, corner bool
+		want                 uint8
+	}{
+		{false, false, false, 3},
+		{false, false, true, 2},
+		{true, false, false, 2},
+		{false, true, true, 1},
+		// 两侧都实心时对角看不到, 完全遮蔽
+		{true, true, false, 0},
+		{true, true, true, 0},
+	}
+	for _, c := range cases {
+		if got := vertexAO(c.side1, c.side2, c.corner); got != c.want {
+			t.Errorf("vertexAO(%v, %v, %v) = %d, want %d", c.side1, c.side2, c.corner, got, c.want)
+		}
+	}
+}
+
+func TestCornerLight(t *testing.T) {
+	lit := lightSample{lum: world.NewLuminance(15, 0), open: true}
+	dim := lightSample{lum: world.NewLuminance(7, 3), open: true}
+	solid := lightSample{solid: true}
+
+	c := newCornerLight(lit, lit, lit, lit)
+	if l, ao := c.light(0); c.count != 4 || ao != 1 || l != [4]float32{15, 0, 0, 0} {
+		t.Fatalf("open corner: %+v light %v ao %v", c, l, ao)
+	}
+
+	// 两侧都实心时对角的光照不计入
+	c = newCornerLight(lit, solid, solid, dim)
+	if l, ao := c.light(0); c.count != 1 || ao != aoShade[0] || l != [4]float32{15, 0, 0, 0} {
+		t.Fatalf("enclosed corner: %+v light %v ao %v", c, l, ao)
+	}
+
+	// 周围透光方块的平均值, 阳光 (15+7+15+15)/4, 方块光 3/4
+	c = newCornerLight(lit, dim, lit, lit)
+	if l, _ := c.light(0); l != [4]float32{13, 0.75, 0.75, 0.75} {
+		t.Fatalf("average light %v", l)
+	}
+
+	// 各颜色通道分别平均
+	red := lightSample{lum: world.NewColorLuminance(0, world.LightColor{R: 15}), open: true}
+	blue := lightSample{lum: world.NewColorLuminance(0, world.LightColor{R: 7, B: 8}), open: true}
+	c = newCornerLight(red, red, blue, red)
+	if l, _ := c.light(0); l != [4]float32{0, 13, 0, 2} {
+		t.Fatalf("colored light %v", l)
+	}
+
+	// 未计算平滑光照时使用面的光照
+	face := world.NewColorLuminance(9, world.LightColor{R: 1, G: 2, B: 3})
+	if l, ao := (cornerLight{}).light(face); l != [4]float32{9, 1, 2, 3} || ao != 1 {
+		t.Fatalf("flat light %v ao %v", l, ao)
+	}
+}
+
+// buildQuad 用四个顶点的光照生成一个朝上的四边形, 返回它的三角形索引
+func buildQuad(t *testing.T, corners [4]cornerLight) []uint32 {
+	b := newBuilder(testTextures{})
+	key := faceKey{lum: world.NewLuminance(15, 0), corners: corners}
+	b.addQuad(b.groups[b.keyIndex(key)-1], faceDefs[2], 0, 0, 0, 1, 1)
+
+	m := b.build()
+	checkWinding(t, m)
+	return m.Indices
+}
+
+func TestDiagonalFlip(t *testing.T) {
+	open := cornerLight{sun: 15, count: 1, ao: 3}
+	shaded := cornerLight{sun: 15, count: 1, ao: 0}
+
+	// 顶点 0、2 较亮时沿 0-2 分割
+	if got := buildQuad(t, [4]cornerLight{open, shaded, open, shaded}); got[0] != 0 || got[2] != 2 || got[3] != 0 {
+		t.Fatalf("indices %v, want split along 0-2", got)
+	}
+	// 顶点 1、3 较亮时沿 1-3 分割
+	if got := buildQuad(t, [4]cornerLight{shaded, open, shaded, open}); got[0] != 1 || got[2] != 3 || got[3] != 1 {
+		t.Fatalf("indices %v, want split along 1-3", got)
+	}
+}
+
+func TestTopLayerLight(t *testing.T) {
+	chunks := []world.ChunkPos{{}, {X: 1}, {X: -1}, {Z: 1}, {Z: -1}}
+	w := newTestWorld(t, chunks...)
+	c := w.Chunk(world.ChunkPos{})
+	w.SetBlock(world.NewPos(5, c.MaxY()-1, 7), world.BlockStone)
+	for _, cpos := range chunks {
+		w.InitChunkLum(cpos)
+	}
+
+	// 世界最高一层方块的顶面为直射阳光
+	idx := c.Dimension().SectionCount() - 1
+	for _, smooth := range []bool{false, true} {
+		mr := NewMesher(w, testTextures{})
+		mr.SetSmoothLighting(smooth)
+		m := mr.BuildSection(c, idx)
+
+		top := 0
+		for v := 0; v < m.VertexCount(); v++ {
+			if m.Normals[v*3+1] != 1 {
+				continue
+			}
+			top++
+			if sun := m.Lights[v*4]; sun != float32(world.MAX_LUM) {
+				t.Fatalf("smooth %v: top face sun %v", smooth, sun)
+			}
+		}
+		if top != 4 {
+			t.Fatalf("smooth %v: %d top face vertices", smooth, top)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package mesher
+
+// Mesh 区段网格的顶点数据, 坐标为区段内坐标 (原点为区段最低角)
+type Mesh struct {
+	Positions []float32 // 顶点坐标 x, y, z
+	Normals   []float32 // 顶点法线 x, y, z
+	Uvs       []float32 // 纹理坐标 u, v, 以方块为单位, 合并后的面按方块重复平铺
+	Tiles     []float32 // 顶点所在面的纹理在图集中的范围 u0, v0, u1, v1
+	Lights    []float32 // 顶点光照 阳光, 红, 绿, 蓝, 0~15, 平滑光照时为周围方块的平均值
+	Occlusion []float32 // 顶点的环境光遮蔽明暗, 0~1
+	Indices   []uint32  // 三角形索引
+	Groups    []Group   // 按图集分组, 每组对应一个材质
+}
+
+// Group 一段使用相同图集的三角形索引
+type Group struct {
+	Page  int
+	Start int // Indices 中的起始位置
+	Count int // 索引数量
+}
+
+// IsEmpty 网格是否没有任何面
+func (m *Mesh) IsEmpty() bool {
+	return m == nil || len(m.Indices) == 0
+}
+
+// VertexCount 顶点数量
+func (m *Mesh) VertexCount() int {
+	return len(m.Positions) / 3
+}
+
+// QuadCount 四边形数量
+func (m *Mesh) QuadCount() int {
+	return len(m.Indices) / 6
+}
@@ -0,0 +1,475 @@
+// Package mesher 为区块的每个区段生成网格顶点数据.
+// 被遮挡的面会被剔除, 相邻共面且纹理与光照都相同的面会被贪心合并为一个四边形.
+// 顶点数据中分别保存阳光与方块光, 昼夜变化由着色器处理, 不需要重建网格.
+// 开启平滑光照时每个顶点按周围方块计算光照与环境光遮蔽.
+// 草和花绘制为两个沿对角线交叉的平面, 透明方块不遮挡相邻方块的面.
+// 只依赖 world 和 atlas 包, 输出的是普通数组, 可以脱离渲染引擎使用.
+package mesher
+
+import (
+	"sort"
+
+	"github.com/weiWang95/mcworld/app/atlas"
+	"github.com/weiWang95/mcworld/app/world"
+)
+
+const (
+	size   = world.SECTION_SIZE
+	padded = size + 2 // 区段外扩一格, 用于判断边界上的面是否可见
+)
+
+// IBlockSource 读取区段之外的方块与光照, 坐标为世界坐标, 以及方块的形状
+type IBlockSource interface {
+	GetBlock(pos world.Pos) (id world.BlockId, chunkLoaded bool)
+	GetLum(pos world.Pos) (lum world.Luminance, chunkLoaded bool)
+	BlockTransparent(id world.BlockId) bool
+	BlockRenderType(id world.BlockId) world.RenderType
+}
+
+// ITextureSource 查询方块各个面使用的纹理及纹理在图集中的位置
+type ITextureSource interface {
+	FaceTexture(id world.BlockId, face world.BlockFace) int
+	TextureRegion(texture int) atlas.Region
+}
+
+// faceDef 一个朝向的面在网格中的摆放方式
+type faceDef struct {
+	face world.BlockFace
+	axis int   // 法线所在轴, 0:x 1:y 2:z
+	dir  int64 // 法线方向, 1 或 -1
+	// 面内的两个轴, a×b 与法线同向, 按 a、b 顺序排列的顶点为逆时针
+	a, b int
+	// 纹理 u、v 方向, 与原来 Cube 各个面的贴图方向一致
+	u, v [3]float32
+}
+
+var faceDefs = []faceDef{
+	{world.BlockFaceBack, 2, 1, 0, 1, [3]float32{1, 0, 0}, [3]float32{0, 1, 0}},
+	{world.BlockFaceFront, 2, -1, 1, 0, [3]float32{-1, 0, 0}, [3]float32{0, 1, 0}},
+	{world.BlockFaceTop, 1, 1, 2, 0, [3]float32{1, 0, 0}, [3]float32{0, 0, -1}},
+	{world.BlockFaceBottom, 1, -1, 0, 2, [3]float32{1, 0, 0}, [3]float32{0, 0, 1}},
+	{world.BlockFaceRight, 0, 1, 1, 2, [3]float32{0, 0, -1}, [3]float32{0, 1, 0}},
+	{world.BlockFaceLeft, 0, -1, 2, 1, [3]float32{0, 0, 1}, [3]float32{0, 1, 0}},
+}
+
+// crossQuad 交叉平面的一面, 顶点为方块内坐标, 按逆时针排列
+type crossQuad struct {
+	normal  [3]float32
+	corners [4][3]float32
+	uvs     [4][2]float32
+}
+
+const diag = 0.70710677 // 对角线方向法线的分量
+
+// crossQuads 两个交叉的平面, 材质剔除背面, 每个平面需要正反两面
+var crossQuads = []crossQuad{
+	{[3]float32{-diag, 0, diag}, [4][3]float32{{0, 0, 0}, {1, 0, 1}, {1, 1, 1}, {0, 1, 0}}, [4][2]float32{{0, 0}, {1, 0}, {1, 1}, {0, 1}}},
+	{[3]float32{diag, 0, -diag}, [4][3]float32{{0, 0, 0}, {0, 1, 0}, {1, 1, 1}, {1, 0, 1}}, [4][2]float32{{0, 0}, {0, 1}, {1, 1}, {1, 0}}},
+	{[3]float32{-diag, 0, -diag}, [4][3]float32{{1, 0, 0}, {0, 0, 1}, {0, 1, 1}, {1, 1, 0}}, [4][2]float32{{0, 0}, {1, 0}, {1, 1}, {0, 1}}},
+	{[3]float32{diag, 0, diag}, [4][3]float32{{1, 0, 0}, {1, 1, 0}, {0, 1, 1}, {0, 0, 1}}, [4][2]float32{{0, 0}, {0, 1}, {1, 1}, {1, 0}}},
+}
+
+// faceKey 可以合并的面需要相同的纹理和光照, 平滑光照时四个顶点的光照也要相同
+type faceKey struct {
+	texture int
+	lum     world.Luminance
+	corners [4]cornerLight
+}
+
+// mergeable 四个顶点相同的面才能合并, 否则合并后的插值与逐个方块不同
+func (k faceKey) mergeable() bool {
+	return k.corners[0] == k.corners[1] && k.corners[0] == k.corners[2] && k.corners[0] == k.corners[3]
+}
+
+// Mesher 区段网格生成器
+type Mesher struct {
+	src    IBlockSource
+	tex    ITextureSource
+	smooth bool
+}
+
+func NewMesher(src IBlockSource, tex ITextureSource) *Mesher {
+	return &Mesher{src: src, tex: tex}
+}
+
+// SetSmoothLighting 开关平滑光照与环境光遮蔽, 关闭时面的四个顶点使用相同的光照, 没有遮蔽
+func (m *Mesher) SetSmoothLighting(on bool) {
+	m.smooth = on
+}
+
+func (m *Mesher) SmoothLighting() bool {
+	return m.smooth
+}
+
+// sectionView 区段及其外扩一格的方块, 坐标为区段内坐标, 范围 [-1, size]
+type sectionView struct {
+	src     IBlockSource
+	section *world.Section
+	origin  world.Pos // 区段最低角的世界坐标
+	minY    int64
+	maxY    int64
+	// 是否读取棱和角上的方块, 平滑光照需要
+	corners bool
+
+	ids   []world.BlockId
+	open  []bool // 相邻方块的面在此处是否可见
+	solid []bool // 不透明的方块, 遮挡环境光
+	cube  []bool // 区段内绘制为立方体的方块
+	lums  []world.Luminance
+	// 区段内绘制为交叉平面的方块
+	crosses [][3]int64
+}
+
+func (v *sectionView) index(x, y, z int64) int {
+	return int(((y+1)*padded+(x+1))*padded + (z + 1))
+}
+
+func inSection(x, y, z int64) bool {
+	return x >= 0 && x < size && y >= 0 && y < size && z >= 0 && z < size
+}
+
+func (v *sectionView) load() {
+	v.ids = make([]world.BlockId, padded*padded*padded)
+	v.open = make([]bool, len(v.ids))
+	v.solid = make([]bool, len(v.ids))
+	v.cube = make([]bool, len(v.ids))
+	v.lums = make([]world.Luminance, len(v.ids))
+
+	for y := int64(-1); y <= size; y++ {
+		for x := int64(-1); x <= size; x++ {
+			for z := int64(-1); z <= size; z++ {
+				i := v.index(x, y, z)
+				if inSection(x, y, z) {
+					id := v.section.GetBlock(x, y, z)
+					v.ids[i] = id
+					v.open[i] = v.src.BlockTransparent(id)
+					v.solid[i] = !v.open[i]
+					v.lums[i] = v.section.GetLum(x, y, z)
+					if id != world.BlockAir {
+						switch v.src.BlockRenderType(id) {
+						case world.RenderCube:
+							v.cube[i] = true
+						case world.RenderCross:
+							v.crosses = append(v.crosses, [3]int64{x, y, z})
+						}
+					}
+					continue
+				}
+
+				// 不计算平滑光照时只需要与区段共面的相邻方块, 棱和角上的方块不影响任何面
+				out := 0
+				for _, c := range []int64{x, y, z} {
+					if c < 0 || c >= size {
+						out++
+					}
+				}
+				if out > 1 && !v.corners {
+					continue
+				}
+
+				pos := v.origin.Add(world.NewPos(x, y, z))
+				id, loaded := v.src.GetBlock(pos)
+				v.ids[i] = id
+				transparent := v.src.BlockTransparent(id)
+				v.solid[i] = !transparent
+				// 未加载的区块和世界底部之下视为实心, 不显示朝向它们的面
+				v.open[i] = loaded && transparent && pos.Y >= v.minY
+				if !v.open[i] {
+					continue
+				}
+				if pos.Y >= v.maxY {
+					// 世界顶部之上没有保存光照, 总是直射阳光
+					v.lums[i] = world.NewLuminance(world.MAX_LUM, 0)
+				} else {
+					v.lums[i], _ = v.src.GetLum(pos)
+				}
+			}
+		}
+	}
+}
+
+func (v *sectionView) lum(x, y, z int64) world.Luminance {
+	return v.lums[v.index(x, y, z)]
+}
+
+// BuildSection 生成区块 c 第 idx 个区段的网格, 区段没有可见面时返回 nil
+func (m *Mesher) BuildSection(c *world.Chunk, idx int) *Mesh {
+	s := c.Section(idx)
+	if s == nil || s.Blocks().IsEmpty() {
+		return nil
+	}
+
+	v := &sectionView{
+		src:     m.src,
+		section: s,
+		origin:  c.GetWorldPos(0, c.SectionMinY(idx), 0),
+		minY:    c.MinY(),
+		maxY:    c.MaxY(),
+		corners: m.smooth,
+	}
+	v.load()
+
+	b := newBuilder(m.tex)
+	mask := make([]int, size*size)
+	for _, fd := range faceDefs {
+		for layer := int64(0); layer < size; layer++ {
+			m.fillMask(v, fd, layer, mask, b)
+			b.greedy(fd, layer, mask)
+		}
+	}
+	for _, p := range v.crosses {
+		m.addCross(v, p, b)
+	}
+
+	return b.build()
+}
+
+// fillMask 填充一层中每个位置可见面的 key 序号, 0 表示没有可见面
+func (m *Mesher) fillMask(v *sectionView, fd faceDef, layer int64, mask []int, b *builder) {
+	var p, n [3]int64
+	p[fd.axis] = layer
+	for j := int64(0); j < size; j++ {
+		for i := int64(0); i < size; i++ {
+			k := j*size + i
+			mask[k] = 0
+
+			p[fd.a], p[fd.b] = i, j
+			pi := v.index(p[0], p[1], p[2])
+			if !v.cube[pi] {
+				continue
+			}
+			id := v.ids[pi]
+
+			// 相邻的同种透明方块 (例如连成一片的水) 之间不绘制
+			n = p
+			n[fd.axis] += fd.dir
+			ni := v.index(n[0], n[1], n[2])
+			if !v.open[ni] || v.ids[ni] == id {
+				continue
+			}
+
+			key := faceKey{
+				texture: m.tex.FaceTexture(id, fd.face),
+				lum:     v.lum(n[0], n[1], n[2]),
+			}
+			if m.smooth {
+				key.corners = v.faceCorners(fd, n)
+			}
+			mask[k] = b.keyIndex(key)
+		}
+	}
+}
+
+// addCross 添加交叉平面, 光照为方块自身所在位置的光照
+func (m *Mesher) addCross(v *sectionView, p [3]int64, b *builder) {
+	key := faceKey{
+		texture: m.tex.FaceTexture(v.ids[v.index(p[0], p[1], p[2])], world.BlockFaceNone),
+		lum:     v.lum(p[0], p[1], p[2]),
+	}
+	b.addCross(b.groups[b.keyIndex(key)-1], p)
+}
+
+// faceCorners 计算面的四个顶点的光照, 顺序与 addQuad 中的顶点相同. front 为面前方的方块
+func (v *sectionView) faceCorners(fd faceDef, front [3]int64) [4]cornerLight {
+	var corners [4]cornerLight
+	for i, d := range [4][2]int64{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+		side1, side2, corner := front, front, front
+		side1[fd.a] += d[0]
+		side2[fd.b] += d[1]
+		corner[fd.a] += d[0]
+		corner[fd.b] += d[1]
+
+		corners[i] = newCornerLight(
+			v.sample(front[0], front[1], front[2]),
+			v.sample(side1[0], side1[1], side1[2]),
+			v.sample(side2[0], side2[1], side2[2]),
+			v.sample(corner[0], corner[1], corner[2]),
+		)
+	}
+	return corners
+}
+
+func (v *sectionView) sample(x, y, z int64) lightSample {
+	i := v.index(x, y, z)
+	return lightSample{lum: v.lums[i], open: v.open[i], solid: v.solid[i]}
+}
+
+// quads 同一 key 的四边形顶点
+type quads struct {
+	key       faceKey
+	region    atlas.Region
+	positions []float32
+	normals   []float32
+	uvs       []float32
+	lights    []float32
+	occlusion []float32
+}
+
+type builder struct {
+	tex    ITextureSource
+	keys   map[faceKey]int
+	groups []*quads
+}
+
+func newBuilder(tex ITextureSource) *builder {
+	return &builder{tex: tex, keys: make(map[faceKey]int)}
+}
+
+// keyIndex 返回 key 的序号, 从 1 开始
+func (b *builder) keyIndex(key faceKey) int {
+	if i, ok := b.keys[key]; ok {
+		return i
+	}
+
+	b.groups = append(b.groups, &quads{key: key, region: b.tex.TextureRegion(key.texture)})
+	b.keys[key] = len(b.groups)
+	return len(b.groups)
+}
+
+// greedy 在一层中合并相同 key 的相邻面, 先沿 a 轴扩展宽度, 再沿 b 轴扩展高度
+func (b *builder) greedy(fd faceDef, layer int64, mask []int) {
+	for j := int64(0); j < size; j++ {
+		for i := int64(0); i < size; {
+			k := mask[j*size+i]
+			if k == 0 {
+				i++
+				continue
+			}
+
+			// 不能合并的面单独输出
+			mergeable := b.groups[k-1].key.mergeable()
+
+			w := int64(1)
+			for mergeable && i+w < size && mask[j*size+i+w] == k {
+				w++
+			}
+
+			h := int64(1)
+		grow:
+			for mergeable && j+h < size {
+				for x := int64(0); x < w; x++ {
+					if mask[(j+h)*size+i+x] != k {
+						break grow
+					}
+				}
+				h++
+			}
+
+			for y := j; y < j+h; y++ {
+				for x := i; x < i+w; x++ {
+					mask[y*size+x] = 0
+				}
+			}
+
+			b.addQuad(b.groups[k-1], fd, layer, i, j, w, h)
+			i += w
+		}
+	}
+}
+
+func (b *builder) addQuad(q *quads, fd faceDef, layer, i, j, w, h int64) {
+	depth := layer
+	if fd.dir > 0 {
+		depth++
+	}
+
+	var normal [3]float32
+	normal[fd.axis] = float32(fd.dir)
+
+	corners := [4][2]int64{{i, j}, {i + w, j}, {i + w, j + h}, {i, j + h}}
+	for n, c := range corners {
+		var p [3]float32
+		p[fd.axis] = float32(depth)
+		p[fd.a], p[fd.b] = float32(c[0]), float32(c[1])
+
+		q.positions = append(q.positions, p[0], p[1], p[2])
+		q.normals = append(q.normals, normal[0], normal[1], normal[2])
+		q.uvs = append(q.uvs, dot(p, fd.u), dot(p, fd.v))
+
+		light, ao := q.key.corners[n].light(q.key.lum)
+		q.lights = append(q.lights, light[:]...)
+		q.occlusion = append(q.occlusion, ao)
+	}
+}
+
+func (b *builder) addCross(q *quads, p [3]int64) {
+	for _, cq := range crossQuads {
+		for n, c := range cq.corners {
+			q.positions = append(q.positions, float32(p[0])+c[0], float32(p[1])+c[1], float32(p[2])+c[2])
+			q.normals = append(q.normals, cq.normal[0], cq.normal[1], cq.normal[2])
+			q.uvs = append(q.uvs, cq.uvs[n][0], cq.uvs[n][1])
+
+			light, ao := q.key.corners[n].light(q.key.lum)
+			q.lights = append(q.lights, light[:]...)
+			q.occlusion = append(q.occlusion, ao)
+		}
+	}
+}
+
+// brightness 顶点的大致亮度, 只用于比较
+func (q *quads) brightness(v uint32) float32 {
+	l := q.lights[v*4:]
+	return q.occlusion[v] * (l[0] + l[1] + l[2] + l[3])
+}
+
+func dot(a, b [3]float32) float32 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+// build 按图集和纹理排序后拼接所有四边形, 同一图集的四边形合为一组, 没有面时返回 nil
+func (b *builder) build() *Mesh {
+	if len(b.groups) == 0 {
+		return nil
+	}
+
+	sort.Slice(b.groups, func(i, j int) bool {
+		qi, qj := b.groups[i], b.groups[j]
+		if qi.region.Page != qj.region.Page {
+			return qi.region.Page < qj.region.Page
+		}
+		return qi.key.texture < qj.key.texture
+	})
+
+	m := new(Mesh)
+	for _, q := range b.groups {
+		base := uint32(m.VertexCount())
+		start := len(m.Indices)
+
+		count := uint32(len(q.positions) / 3)
+		for v := uint32(0); v < count; v += 4 {
+			// 沿较亮的对角线分割, 避免遮蔽在两个三角形间插值不对称
+			if q.brightness(v)+q.brightness(v+2) < q.brightness(v+1)+q.brightness(v+3) {
+				m.Indices = append(m.Indices,
+					base+v+1, base+v+2, base+v+3,
+					base+v+1, base+v+3, base+v,
+				)
+				continue
+			}
+			m.Indices = append(m.Indices,
+				base+v, base+v+1, base+v+2,
+				base+v, base+v+2, base+v+3,
+			)
+		}
+		for v := uint32(0); v < count; v++ {
+			m.Tiles = append(m.Tiles, q.region.U0, q.region.V0, q.region.U1, q.region.V1)
+		}
+
+		m.Positions = append(m.Positions, q.positions...)
+		m.Normals = append(m.Normals, q.normals...)
+		m.Uvs = append(m.Uvs, q.uvs...)
+		m.Lights = append(m.Lights, q.lights...)
+		m.Occlusion = append(m.Occlusion, q.occlusion...)
+
+		if n := len(m.Groups); n > 0 && m.Groups[n-1].Page == q.region.Page {
+			m.Groups[n-1].Count = len(m.Indices) - m.Groups[n-1].Start
+			continue
+		}
+		m.Groups = append(m.Groups, Group{
+			Page:  q.region.Page,
+			Start: start,
+			Count: len(m.Indices) - start,
+		})
+	}
+
+	return m
+}
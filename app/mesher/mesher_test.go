@@ -0,0 +1,262 @@
+package mesher
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/weiWang95/mcworld/app/atlas"
+	"github.com/weiWang95/mcworld/app/world"
+)
+
+// testTextures 每种方块一张纹理, 在图集中的位置相同
+type testTextures struct{}
+
+func (testTextures) FaceTexture(id world.BlockId, face world.BlockFace) int { return int(id) }
+
+func (testTextures) TextureRegion(texture int) atlas.Region {
+	return atlas.Region{U0: 0.25, V0: 0.5, U1: 0.5, V1: 0.75}
+}
+
+// testRegistry 水透明, 草是交叉的平面, 其余方块是不透明的立方体
+type testRegistry struct{}
+
+func (testRegistry) GetBlockLight(id world.BlockId) world.LightColor { return world.LightColor{} }
+
+func (testRegistry) IsTransparent(id world.BlockId) bool {
+	return id == world.BlockWater || id == world.BlockTallGrass
+}
+
+func (testRegistry) IsSolid(id world.BlockId) bool { return id != world.BlockTallGrass }
+
+func (testRegistry) GetRenderType(id world.BlockId) world.RenderType {
+	if id == world.BlockTallGrass {
+		return world.RenderCross
+	}
+	return world.RenderCube
+}
+
+// newTestWorld 只有空气的世界, 加载 chunks 中的区块
+func newTestWorld(t *testing.T, chunks ...world.ChunkPos) *world.World {
+	wg, err := world.NewGenerator(world.VOID_GENERATOR, []byte(`{"platform":false}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := world.NewWorld(nil, testRegistry{}, wg, world.DefaultDimension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cpos := range chunks {
+		c, _ := w.LoadChunk(cpos, nil)
+		w.AddChunk(c)
+	}
+	return w
+}
+
+// quad 四边形的法线与包围盒, 坐标为区段内坐标
+type quad struct {
+	normal   [3]float32
+	min, max [3]float32
+}
+
+func (q quad) String() string {
+	return fmt.Sprintf("n%v %v-%v", q.normal, q.min, q.max)
+}
+
+// meshQuads 按顶点取出网格中的四边形并排序
+func meshQuads(t *testing.T, m *Mesh) []quad {
+	if m == nil {
+		return nil
+	}
+	if len(m.Indices) != m.VertexCount()/4*6 {
+		t.Fatalf("%d indices for %d vertices", len(m.Indices), m.VertexCount())
+	}
+
+	qs := make([]quad, 0, m.VertexCount()/4)
+	for v := 0; v < m.VertexCount(); v += 4 {
+		var q quad
+		copy(q.normal[:], m.Normals[v*3:])
+		copy(q.min[:], m.Positions[v*3:])
+		copy(q.max[:], m.Positions[v*3:])
+		for i := v + 1; i < v+4; i++ {
+			for a := 0; a < 3; a++ {
+				p := m.Positions[i*3+a]
+				if p < q.min[a] {
+					q.min[a] = p
+				}
+				if p > q.max[a] {
+					q.max[a] = p
+				}
+			}
+		}
+		qs = append(qs, q)
+	}
+	sortQuads(qs)
+	return qs
+}
+
+func sortQuads(qs []quad) {
+	sort.Slice(qs, func(i, j int) bool { return qs[i].String() < qs[j].String() })
+}
+
+func checkQuads(t *testing.T, m *Mesh, want []quad) {
+	t.Helper()
+	got := meshQuads(t, m)
+	sortQuads(want)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("quads\n got %v\nwant %v", got, want)
+	}
+	checkWinding(t, m)
+}
+
+// checkWinding 三角形按逆时针排列, 与法线同向
+func checkWinding(t *testing.T, m *Mesh) {
+	t.Helper()
+	pos := func(i uint32) [3]float32 {
+		return [3]float32{m.Positions[i*3], m.Positions[i*3+1], m.Positions[i*3+2]}
+	}
+	for i := 0; i < len(m.Indices); i += 3 {
+		a, b, c := pos(m.Indices[i]), pos(m.Indices[i+1]), pos(m.Indices[i+2])
+		e1 := [3]float32{b[0] - a[0], b[1] - a[1], b[2] - a[2]}
+		e2 := [3]float32{c[0] - a[0], c[1] - a[1], c[2] - a[2]}
+		cross := [3]float32{e1[1]*e2[2] - e1[2]*e2[1], e1[2]*e2[0] - e1[0]*e2[2], e1[0]*e2[1] - e1[1]*e2[0]}
+		n := m.Indices[i] * 3
+		if dot(cross, [3]float32{m.Normals[n], m.Normals[n+1], m.Normals[n+2]}) <= 0 {
+			t.Fatalf("triangle %d winds against its normal", i/3)
+		}
+	}
+}
+
+// box 一个长方体的六个面
+func box(x0, y0, z0, x1, y1, z1 float32) []quad {
+	return []quad{
+		{[3]float32{-1, 0, 0}, [3]float32{x0, y0, z0}, [3]float32{x0, y1, z1}},
+		{[3]float32{1, 0, 0}, [3]float32{x1, y0, z0}, [3]float32{x1, y1, z1}},
+		{[3]float32{0, -1, 0}, [3]float32{x0, y0, z0}, [3]float32{x1, y0, z1}},
+		{[3]float32{0, 1, 0}, [3]float32{x0, y1, z0}, [3]float32{x1, y1, z1}},
+		{[3]float32{0, 0, -1}, [3]float32{x0, y0, z0}, [3]float32{x1, y1, z0}},
+		{[3]float32{0, 0, 1}, [3]float32{x0, y0, z1}, [3]float32{x1, y1, z1}},
+	}
+}
+
+// testSection 原点所在区块中 y=0 所在的区段
+func testSection(w *world.World) (*world.Chunk, int, int64) {
+	c := w.Chunk(world.ChunkPos{})
+	idx := int((0 - c.MinY()) / world.SECTION_SIZE)
+	return c, idx, c.SectionMinY(idx)
+}
+
+func TestSingleBlock(t *testing.T) {
+	w := newTestWorld(t, world.ChunkPos{}, world.ChunkPos{X: 1}, world.ChunkPos{X: -1}, world.ChunkPos{Z: 1}, world.ChunkPos{Z: -1})
+	c, idx, minY := testSection(w)
+	w.SetBlock(world.NewPos(5, minY+3, 7), world.BlockBrick)
+
+	m := NewMesher(w, testTextures{}).BuildSection(c, idx)
+	checkQuads(t, m, box(5, 3, 7, 6, 4, 8))
+	if len(m.Groups) != 1 || m.Groups[0].Count != 36 {
+		t.Fatalf("groups %+v", m.Groups)
+	}
+	if len(m.Tiles) != 4*m.VertexCount() || m.Tiles[0] != 0.25 || m.Tiles[3] != 0.75 {
+		t.Fatalf("tiles %v", m.Tiles[:4])
+	}
+
+	if NewMesher(w, testTextures{}).BuildSection(c, idx+1) != nil {
+		t.Fatal("empty section should have no mesh")
+	}
+}
+
+func TestGreedySlab(t *testing.T) {
+	w := newTestWorld(t, world.ChunkPos{}, world.ChunkPos{X: 1}, world.ChunkPos{X: -1}, world.ChunkPos{Z: 1}, world.ChunkPos{Z: -1})
+	c, idx, minY := testSection(w)
+	for x := int64(2); x < 4; x++ {
+		for z := int64(2); z < 4; z++ {
+			w.SetBlock(world.NewPos(x, minY, z), world.BlockStone)
+		}
+	}
+
+	// 2×2 的平板合并为六个面, 纹理坐标按方块平铺
+	m := NewMesher(w, testTextures{}).BuildSection(c, idx)
+	checkQuads(t, m, box(2, 0, 2, 4, 1, 4))
+	for v := 0; v < m.VertexCount(); v++ {
+		u, vv := m.Uvs[v*2], m.Uvs[v*2+1]
+		if u < -4 || u > 4 || vv < -4 || vv > 4 || (u != float32(int(u)) || vv != float32(int(vv))) {
+			t.Fatalf("uv %v,%v is not in block units", u, vv)
+		}
+	}
+}
+
+func TestUnloadedNeighbour(t *testing.T) {
+	// 只加载原点区块, 朝向未加载区块的面被剔除
+	w := newTestWorld(t, world.ChunkPos{})
+	c, idx, minY := testSection(w)
+	w.SetBlock(world.NewPos(world.CHUNK_WIDTH-1, minY+1, 4), world.BlockStone)
+
+	all := box(15, 1, 4, 16, 2, 5)
+	m := NewMesher(w, testTextures{}).BuildSection(c, idx)
+	checkQuads(t, m, append(all[:1:1], all[2:]...))
+
+	// 相邻区块加载后露出 +x 的面
+	neighbour, _ := w.LoadChunk(world.ChunkPos{X: 1}, nil)
+	w.AddChunk(neighbour)
+	m = NewMesher(w, testTextures{}).BuildSection(c, idx)
+	checkQuads(t, m, box(15, 1, 4, 16, 2, 5))
+}
+
+func TestHiddenFaces(t *testing.T) {
+	w := newTestWorld(t, world.ChunkPos{}, world.ChunkPos{X: 1}, world.ChunkPos{X: -1}, world.ChunkPos{Z: 1}, world.ChunkPos{Z: -1})
+	c, idx, minY := testSection(w)
+	// 两个相邻方块之间的面不可见, 其余面合并
+	w.SetBlock(world.NewPos(5, minY+3, 7), world.BlockBrick)
+	w.SetBlock(world.NewPos(6, minY+3, 7), world.BlockBrick)
+
+	m := NewMesher(w, testTextures{}).BuildSection(c, idx)
+	checkQuads(t, m, box(5, 3, 7, 7, 4, 8))
+}
+
+func TestTransparentNeighbour(t *testing.T) {
+	w := newTestWorld(t, world.ChunkPos{}, world.ChunkPos{X: 1}, world.ChunkPos{X: -1}, world.ChunkPos{Z: 1}, world.ChunkPos{Z: -1})
+	c, idx, minY := testSection(w)
+	// 石头朝向水的面可见, 水朝向石头的面和两格水之间的面不可见
+	w.SetBlock(world.NewPos(5, minY+3, 7), world.BlockStone)
+	w.SetBlock(world.NewPos(6, minY+3, 7), world.BlockWater)
+	w.SetBlock(world.NewPos(7, minY+3, 7), world.BlockWater)
+
+	water := box(6, 3, 7, 8, 4, 8)
+	m := NewMesher(w, testTextures{}).BuildSection(c, idx)
+	checkQuads(t, m, append(box(5, 3, 7, 6, 4, 8), water[1:]...))
+}
+
+func TestCrossPlant(t *testing.T) {
+	w := newTestWorld(t, world.ChunkPos{}, world.ChunkPos{X: 1}, world.ChunkPos{X: -1}, world.ChunkPos{Z: 1}, world.ChunkPos{Z: -1})
+	c, idx, minY := testSection(w)
+	// 草不遮挡下方方块的顶面, 自身绘制为两个交叉平面的正反两面
+	w.SetBlock(world.NewPos(5, minY+2, 7), world.BlockSoil)
+	w.SetBlock(world.NewPos(5, minY+3, 7), world.BlockTallGrass)
+
+	want := box(5, 2, 7, 6, 3, 8)
+	for _, n := range [][3]float32{{-diag, 0, diag}, {diag, 0, -diag}, {-diag, 0, -diag}, {diag, 0, diag}} {
+		want = append(want, quad{n, [3]float32{5, 3, 7}, [3]float32{6, 4, 8}})
+	}
+	m := NewMesher(w, testTextures{}).BuildSection(c, idx)
+	checkQuads(t, m, want)
+	// 交叉平面的纹理覆盖整个方块
+	for v := 0; v < m.VertexCount(); v++ {
+		if m.Normals[v*3] == 0 || m.Normals[v*3+2] == 0 {
+			continue
+		}
+		if u, vv := m.Uvs[v*2], m.Uvs[v*2+1]; u < 0 || u > 1 || vv < 0 || vv > 1 {
+			t.Fatalf("cross uv %v,%v", u, vv)
+		}
+	}
+
+	// 平滑光照下草不产生环境光遮蔽
+	w.SetBlock(world.NewPos(6, minY+3, 7), world.BlockTallGrass)
+	sm := NewMesher(w, testTextures{})
+	sm.SetSmoothLighting(true)
+	m = sm.BuildSection(c, idx)
+	for v := 0; v < m.VertexCount(); v++ {
+		if m.Occlusion[v] != 1 {
+			t.Fatalf("vertex %d occluded by plants: %v", v, m.Occlusion[v])
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/g3n/engine/window"
 	"github.com/weiWang95/mcworld/app/block"
 	"github.com/weiWang95/mcworld/app/blockv2"
+	"github.com/weiWang95/mcworld/app/world"
 )
 
 const PLAYER_JUMP_SPEED = 4.85 // 1.5: 5.42 1.2: 4.85
@@ -79,6 +80,9 @@ type Player struct {
 	curInventoryIdx uint8
 
 	inventory *PlayerInventory
+
+	// 恢复存档位置后等待所在区块加载, 加载前不移动, 加载后检查是否卡在方块中
+	waitChunk bool
 }
 
 func NewPlayer() *Player {
@@ -144,10 +148,124 @@ func (p *Player) Start(a *App) {
 
 	a.Scene().Add(p)
 
-	p.initInventory()
+	p.restore(a.Level())
+}
+
+// restore 从世界元数据恢复玩家状态, 第一次进入世界时位于出生点并获得初始物品
+func (p *Player) restore(level *world.LevelData) {
+	data := level.Player
+	if data == nil {
+		p.SetPositionVec(vec3(level.Spawn))
+		p.initInventory()
+		p.waitChunk = true
+		return
+	}
+
+	pos := data.Position
+	if !isFinite(pos.X) || !isFinite(pos.Y) || !isFinite(pos.Z) {
+		pos = level.Spawn
+	}
+	p.SetPositionVec(vec3(pos))
+	p.SetPlayMode(PlayMode(data.Mode))
+	p.SetLook(data.Yaw, data.Pitch)
+
+	if data.Slot < QUICKBAR_SIZE {
+		p.curInventoryIdx = data.Slot
+	}
+	if data.Inventory == nil {
+		p.initInventory()
+	} else {
+		p.inventory.Import(data.Inventory)
+	}
+
+	p.waitChunk = true
+}
+
+// Data 导出需要保存的玩家状态
+func (p *Player) Data() *world.PlayerData {
+	pos := p.GetPosition()
+	yaw, pitch := p.Look()
+
+	return &world.PlayerData{
+		Position:  world.Vec3{X: pos.X, Y: pos.Y, Z: pos.Z},
+		Mode:      uint8(p.playMode),
+		Yaw:       yaw,
+		Pitch:     pitch,
+		Slot:      p.curInventoryIdx,
+		Inventory: p.inventory.Export(),
+	}
+}
+
+// checkRestoredPosition 所在区块加载后, 若玩家卡在方块中则向上移到第一个能容纳玩家的位置
+func (p *Player) checkRestoredPosition(a *App) bool {
+	pos := p.GetPosition()
+	data := a.World().Data()
+	if data.ChunkAt(ToWorldPos(*pos)) == nil {
+		return false
+	}
+
+	height := int(math32.Ceil(p.Model.GetBoundBox().BY - pos.Y))
+	dim := data.Dimension()
+	y := math32.Max(pos.Y, float32(dim.MinY))
+	for ; y < float32(dim.MaxY()); y++ {
+		free := true
+		for i := 0; i < height; i++ {
+			if a.World().HasSolidBlock(*math32.NewVector3(pos.X, y+float32(i), pos.Z)) {
+				free = false
+				break
+			}
+		}
+		if free {
+			break
+		}
+	}
+
+	if y != pos.Y {
+		a.Log().Info("player restored inside blocks at %v, move to y:%.2f", pos, y)
+		p.SetPositionVec(*math32.NewVector3(pos.X, y, pos.Z))
+		p.vSpeed = 0
+	}
+	return true
+}
+
+// Look 返回视线方向, yaw 为绕 y 轴的角度, pitch 为与 y 轴的夹角
+func (p *Player) Look() (yaw, pitch float32) {
+	tcam := p.Camera.Position()
+	tcam.Sub(p.GetViewport())
+
+	radius := tcam.Length()
+	if radius == 0 {
+		return 0, math32.Pi / 2
+	}
+	return math32.Atan2(tcam.X, tcam.Z), math32.Acos(tcam.Y / radius)
+}
+
+// SetLook 设置视线方向
+func (p *Player) SetLook(yaw, pitch float32) {
+	if !isFinite(yaw) || !isFinite(pitch) {
+		return
+	}
+
+	curYaw, curPitch := p.Look()
+	p.Rotate(yaw-curYaw, pitch-curPitch)
+}
+
+func vec3(v world.Vec3) math32.Vector3 {
+	return *math32.NewVector3(v.X, v.Y, v.Z)
+}
+
+func isFinite(v float32) bool {
+	return !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0)
 }
 
 func (p *Player) Update(a *App, t time.Duration) {
+	if p.waitChunk {
+		p.waitChunk = !p.checkRestoredPosition(a)
+		if p.waitChunk {
+			return
+		}
+	}
+
 	delta := float32(t) / float32(time.Second)
 	vSpeed := p.vSpeed * delta
 
@@ -159,9 +277,8 @@ func (p *Player) Update(a *App, t time.Duration) {
 	if !p.IsCreatePlayMode() {
 		if p.vSpeed > 0 {
 			npos := math32.NewVector3(pos.X, p.Model.GetBoundBox().BY+vSpeed, pos.Z)
-			block, _ := a.World().GetBlockByVec(*npos)
-			if block != nil {
-				vSpeed = float32(int64(pos.Y)) - pos.Y
+			if a.World().HasSolidBlock(*npos) {
+				vSpeed = math32.Floor(pos.Y) - pos.Y
 				p.vSpeed = 0
 				p.inFall = true
 			} else {
@@ -170,13 +287,12 @@ func (p *Player) Update(a *App, t time.Duration) {
 				p.inFall = true
 			}
 		} else {
-			block, _ := a.World().GetBlockByVec(*pos.Clone().Add(math32.NewVector3(0, vSpeed-0.01, 0)))
-			if block == nil {
+			if !a.World().HasSolidBlock(*pos.Clone().Add(math32.NewVector3(0, vSpeed-0.01, 0))) {
 				p.vSpeed += DEFAULT_GRAVITY_SPEED * delta
 				p.vSpeed = math32.Clamp(p.vSpeed, MAX_GRAVITY_SPEED, 40)
 				p.inFall = true
 			} else {
-				vSpeed = float32(int64(pos.Y)) - pos.Y
+				vSpeed = math32.Floor(pos.Y) - pos.Y
 				p.vSpeed = 0
 				p.inFall = false
 			}
@@ -338,34 +454,32 @@ func (p *Player) Move(a *App, speed float32, vSpeed float32) {
 	pos := p.GetPosition()
 	if tcam.X > 0 {
 		// xBlock := a.World().GetBlockByPosition(pos.X+p.Model.GetBoundBox().X/2+tcam.X, pos.Y, pos.Z)
-		xBlock, _ := a.World().GetBlockByPosition(p.Model.GetBoundBox().BX+tcam.X, pos.Y, pos.Z)
-		if xBlock != nil {
+		if a.World().HasSolidBlock(*math32.NewVector3(p.Model.GetBoundBox().BX+tcam.X, pos.Y, pos.Z)) {
 			tcam.X = 0
 		}
 	} else if tcam.X < 0 {
 		// xBlock := a.World().GetBlockByPosition(pos.X-p.Model.GetBoundBox().X/2+tcam.X, pos.Y, pos.Z)
-		xBlock, _ := a.World().GetBlockByPosition(p.Model.GetBoundBox().X+tcam.X, pos.Y, pos.Z)
-		if xBlock != nil {
+		if a.World().HasSolidBlock(*math32.NewVector3(p.Model.GetBoundBox().X+tcam.X, pos.Y, pos.Z)) {
 			tcam.X = 0
 		}
 	}
 
 	if tcam.Z > 0 {
-		zBlock, _ := a.World().GetBlockByPosition(pos.X, pos.Y, p.Model.GetBoundBox().BZ+tcam.Z)
-		if zBlock != nil {
+		if a.World().HasSolidBlock(*math32.NewVector3(pos.X, pos.Y, p.Model.GetBoundBox().BZ+tcam.Z)) {
 			tcam.Z = 0
 		}
 
 	} else if tcam.Z < 0 {
-		zBlock, _ := a.World().GetBlockByPosition(pos.X, pos.Y, p.Model.GetBoundBox().Z+tcam.Z)
-		if zBlock != nil {
+		if a.World().HasSolidBlock(*math32.NewVector3(pos.X, pos.Y, p.Model.GetBoundBox().Z+tcam.Z)) {
 			tcam.Z = 0
 		}
 	}
 
-	if viewport.Y+vSpeed < -10 {
-		vSpeed = float32(CHUNK_HEIGHT) - 1 - viewport.Y
-		viewport.Y = float32(CHUNK_HEIGHT) - 1
+	// 掉出世界后回到世界顶部
+	dim := a.World().Data().Dimension()
+	if viewport.Y+vSpeed < float32(dim.MinY)-10 {
+		vSpeed = float32(dim.MaxY()) - 1 - viewport.Y
+		viewport.Y = float32(dim.MaxY()) - 1
 	}
 	viewport.Add(math32.NewVector3(tcam.X, vSpeed, tcam.Z))
 
@@ -389,23 +503,23 @@ func (p *Player) Jump() {
 }
 
 func (p *Player) WreckBlock() {
-	block, _ := p.GetTarget()
-	if block == nil {
+	blockPos, _ := p.GetTarget()
+	if blockPos == nil {
 		return
 	}
 
-	Instance().curWorld.WreckBlock(block.GetPosition())
+	Instance().curWorld.WreckBlock(*blockPos)
 }
 
 func (p *Player) PlaceBlock() {
 	Instance().log.Debug("place block! start:%v, end:%v", p.GetViewport(), p.farPos)
-	b, hitPos := p.GetTarget()
-	if b == nil {
+	blockPos, hitPos := p.GetTarget()
+	if blockPos == nil {
 		return
 	}
 
-	face := GetBlockFace(b.GetPosition(), *hitPos)
-	pos := b.GetPosition()
+	face := GetBlockFace(*blockPos, *hitPos)
+	pos := *blockPos
 	switch face {
 	case block.BlockFaceFront:
 		pos = *(pos.Add(math32.NewVector3(0, 0, -1)))
@@ -422,10 +536,9 @@ func (p *Player) PlaceBlock() {
 	default:
 		return
 	}
-	Instance().Log().Debug("place block -> b:%v hit:%v face:%v place pos: %v", b.GetPosition(), hitPos, face, pos)
+	Instance().Log().Debug("place block -> b:%v hit:%v face:%v place pos: %v", blockPos, hitPos, face, pos)
 
-	placeBlock, _ := Instance().curWorld.GetBlockByVec(pos)
-	if placeBlock != nil {
+	if Instance().curWorld.HasBlock(pos) {
 		return
 	}
 
@@ -434,18 +547,17 @@ func (p *Player) PlaceBlock() {
 	if item == nil {
 		return
 	}
-	nb := Instance().bm.NewBlock(item.blockId)
-	nb.SetPositionVec(&pos)
-	Instance().curWorld.PlaceBlock(nb, pos)
+	Instance().curWorld.PlaceBlock(item.blockId, pos)
 }
 
-func (p *Player) GetTarget() (*blockv2.Block, *math32.Vector3) {
-	b, pos := RayTraceBlock(Instance().curWorld, *p.GetViewport(), p.farPos)
-	if b == nil || pos == nil {
+// GetTarget 返回视线指向的方块坐标和视线与方块的交点
+func (p *Player) GetTarget() (*math32.Vector3, *math32.Vector3) {
+	blockPos, pos := RayTraceBlock(Instance().curWorld, *p.GetViewport(), p.farPos)
+	if blockPos == nil || pos == nil {
 		return nil, nil
 	}
 
-	return b, pos
+	return blockPos, pos
 }
 
 // onMouse is called when an OnMouseDown/OnMouseUp event is received.
@@ -594,6 +706,18 @@ func (p *Player) addWreckLine() {
 	p.Add(p.wreckLine)
 }
 
+func (p *Player) PlayMode() PlayMode {
+	return p.playMode
+}
+
+func (p *Player) SetPlayMode(mode PlayMode) {
+	if mode != PlayModeCreate && mode != PlayModeLife {
+		mode = PlayModeLife
+	}
+	p.playMode = mode
+	p.vSpeed = 0
+}
+
 func (p *Player) IsCreatePlayMode() bool {
 	return p.playMode == PlayModeCreate
 }
@@ -1,6 +1,12 @@
 package app
 
-import "github.com/weiWang95/mcworld/app/blockv2"
+import (
+	"github.com/weiWang95/mcworld/app/blockv2"
+	"github.com/weiWang95/mcworld/app/world"
+)
+
+// QUICKBAR_SIZE 快捷栏格子数, 存档中格子序号 0-9 为快捷栏, 之后按行排列背包
+const QUICKBAR_SIZE = 10
 
 type InventoryItem struct {
 	blockId blockv2.BlockId
@@ -33,7 +39,7 @@ func NewInventoryItems(blockId blockv2.BlockId, count uint8) []InventoryItem {
 type PlayerInventory struct {
 	itemMap  map[blockv2.BlockId][]*InventoryItem
 	bag      [4][10]*InventoryItem
-	quickbar [10]*InventoryItem
+	quickbar [QUICKBAR_SIZE]*InventoryItem
 }
 
 func NewPlayerInventory() *PlayerInventory {
@@ -142,3 +148,59 @@ func (p *PlayerInventory) reindexItem(item *InventoryItem) {
 
 	p.itemMap[item.blockId] = append(p.itemMap[item.blockId], item)
 }
+
+// slot 返回存档格子序号对应的位置, 超出范围时返回 nil
+func (p *PlayerInventory) slot(idx int) **InventoryItem {
+	if idx < 0 {
+		return nil
+	}
+	if idx < QUICKBAR_SIZE {
+		return &p.quickbar[idx]
+	}
+
+	idx -= QUICKBAR_SIZE
+	row, col := idx/len(p.bag[0]), idx%len(p.bag[0])
+	if row >= len(p.bag) {
+		return nil
+	}
+	return &p.bag[row][col]
+}
+
+// Export 导出所有非空格子
+func (p *PlayerInventory) Export() []world.ItemStack {
+	stacks := make([]world.ItemStack, 0)
+	total := QUICKBAR_SIZE + len(p.bag)*len(p.bag[0])
+	for i := 0; i < total; i++ {
+		item := *p.slot(i)
+		if item == nil || item.count == 0 {
+			continue
+		}
+		stacks = append(stacks, world.ItemStack{Slot: i, Id: item.blockId, Count: item.count})
+	}
+
+	return stacks
+}
+
+// Import 用存档数据替换背包内容, 无效的格子、方块和数量会被丢弃或修正
+func (p *PlayerInventory) Import(stacks []world.ItemStack) {
+	p.quickbar = [QUICKBAR_SIZE]*InventoryItem{}
+	p.bag = [4][10]*InventoryItem{}
+
+	for _, stack := range stacks {
+		slot := p.slot(stack.Slot)
+		if slot == nil || *slot != nil || stack.Count == 0 {
+			continue
+		}
+		if Instance().bm.GetBlockAttr(stack.Id) == nil {
+			continue
+		}
+
+		count := stack.Count
+		if max := Instance().bm.GetMaxStack(stack.Id); count > max {
+			count = max
+		}
+		*slot = NewInventoryItem(stack.Id, count)
+	}
+
+	p.reindexItems()
+}
@@ -7,13 +7,12 @@ import (
 	"github.com/g3n/engine/graphic"
 	"github.com/g3n/engine/material"
 	"github.com/g3n/engine/math32"
-	"github.com/weiWang95/mcworld/app/blockv2"
 )
 
 type PlayerTarget struct {
 	core.Node
 
-	b     *blockv2.Block
+	block *math32.Vector3 // 指向的方块坐标, 没有指向方块时为 nil
 	box   *graphic.Lines
 	point *graphic.Mesh
 }
@@ -28,13 +27,12 @@ func NewPlayerTarget() *PlayerTarget {
 	return target
 }
 
-func (target *PlayerTarget) SetTarget(b *blockv2.Block, hit *math32.Vector3) {
-	target.b = b
-	target.SetVisible(target.b != nil)
-	if target.b != nil {
-		pos := b.GetPosition()
-		target.SetPositionVec(&pos)
-		target.box.SetPositionVec(&pos)
+func (target *PlayerTarget) SetTarget(blockPos *math32.Vector3, hit *math32.Vector3) {
+	target.block = blockPos
+	target.SetVisible(target.block != nil)
+	if target.block != nil {
+		target.SetPositionVec(blockPos)
+		target.box.SetPositionVec(blockPos)
 		target.point.SetPositionVec(hit)
 	}
 }
@@ -0,0 +1,373 @@
+// Package saves 管理存档根目录下的多个世界: 创建、列出、重命名、复制和删除.
+// 每个世界是根目录下的一个子目录, 由 world.NewFileSaveManager 读写, 本包只处理目录和元数据.
+package saves
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/weiWang95/mcworld/app/world"
+)
+
+var (
+	ErrNotFound    = errors.New("world not found")
+	ErrInvalidName = errors.New("invalid world name")
+)
+
+const MAX_NAME_LENGTH = 64
+
+// Summary 世界列表中的一项
+type Summary struct {
+	Id         string // 世界目录名, 在根目录下唯一
+	Name       string
+	Seed       int64
+	Generator  string
+	CreatedAt  time.Time
+	LastPlayed time.Time
+	// Err 元数据无法读取时的原因, 此时其它字段只有 Id 和 Name 可用
+	Err error
+}
+
+// CreateOptions 创建世界的参数
+type CreateOptions struct {
+	Name             string
+	Seed             int64
+	Generator        string // 为空时使用 world.DEFAULT_GENERATOR
+	GeneratorOptions json.RawMessage
+}
+
+// Library 存档根目录
+type Library struct {
+	root string
+}
+
+func NewLibrary(root string) (*Library, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0777); err != nil {
+		return nil, err
+	}
+
+	return &Library{root: root}, nil
+}
+
+func (l *Library) Root() string {
+	return l.root
+}
+
+// Dir 世界的存档目录, 可以直接传给 world.NewFileSaveManager
+func (l *Library) Dir(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", ErrNotFound
+	}
+
+	dir := filepath.Join(l.root, id)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", ErrNotFound
+	}
+
+	return dir, nil
+}
+
+// List 列出所有世界, 最近游玩的在前
+func (l *Library) List() ([]Summary, error) {
+	infos, err := ioutil.ReadDir(l.root)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Summary, 0, len(infos))
+	for _, info := range infos {
+		if !info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		list = append(list, l.summary(info.Name(), info.ModTime()))
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if !list[i].LastPlayed.Equal(list[j].LastPlayed) {
+			return list[i].LastPlayed.After(list[j].LastPlayed)
+		}
+		return list[i].Id < list[j].Id
+	})
+
+	return list, nil
+}
+
+func (l *Library) summary(id string, modTime time.Time) Summary {
+	s := Summary{Id: id, Name: id, LastPlayed: modTime}
+
+	level, err := world.ReadLevel(filepath.Join(l.root, id))
+	if os.IsNotExist(err) {
+		// 还没有 level.json 的旧存档, 打开时会自动迁移
+		return s
+	}
+	if err != nil {
+		s.Err = err
+		return s
+	}
+
+	s.Name = level.Name
+	s.Seed = level.Seed
+	s.Generator = level.Generator
+	s.CreatedAt = level.CreatedAt
+	s.LastPlayed = level.LastPlayed
+	return s
+}
+
+// Get 返回一个世界的信息
+func (l *Library) Get(id string) (Summary, error) {
+	dir, err := l.Dir(id)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return Summary{}, err
+	}
+	return l.summary(id, info.ModTime()), nil
+}
+
+// Create 创建一个新世界, 目录名由名称生成
+func (l *Library) Create(opts CreateOptions) (Summary, error) {
+	name, err := checkName(opts.Name)
+	if err != nil {
+		return Summary{}, err
+	}
+	if _, err := world.NewGenerator(opts.Generator, opts.GeneratorOptions); err != nil {
+		return Summary{}, err
+	}
+
+	id, err := l.mkdir(name)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	level := world.NewLevelData(name, opts.Seed)
+	if opts.Generator != "" {
+		level.Generator = opts.Generator
+	}
+	level.GeneratorOptions = opts.GeneratorOptions
+
+	if err := world.WriteLevel(filepath.Join(l.root, id), level); err != nil {
+		os.RemoveAll(filepath.Join(l.root, id))
+		return Summary{}, err
+	}
+
+	return l.Get(id)
+}
+
+// Rename 修改世界名称, 目录名不变
+func (l *Library) Rename(id, name string) error {
+	name, err := checkName(name)
+	if err != nil {
+		return err
+	}
+
+	dir, err := l.Dir(id)
+	if err != nil {
+		return err
+	}
+
+	level, err := world.ReadLevel(dir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("world %s has no level data, open it once to migrate", id)
+	}
+	if err != nil {
+		return err
+	}
+
+	level.Name = name
+	return world.WriteLevel(dir, level)
+}
+
+// Duplicate 复制一个世界, 新世界使用新的名称和创建时间
+func (l *Library) Duplicate(id, name string) (Summary, error) {
+	name, err := checkName(name)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	src, err := l.Dir(id)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	newId, err := l.mkdir(name)
+	if err != nil {
+		return Summary{}, err
+	}
+	dst := filepath.Join(l.root, newId)
+
+	if err := copyDir(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return Summary{}, err
+	}
+
+	level, err := world.ReadLevel(dst)
+	if err == nil {
+		level.Name = name
+		level.CreatedAt = time.Now()
+		err = world.WriteLevel(dst, level)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		os.RemoveAll(dst)
+		return Summary{}, err
+	}
+
+	return l.Get(newId)
+}
+
+// Delete 删除一个世界及其所有存档数据
+func (l *Library) Delete(id string) error {
+	dir, err := l.Dir(id)
+	if err != nil {
+		return err
+	}
+
+	// 先改名再删除, 删除中途失败时不会留下半个世界出现在列表中
+	trash := filepath.Join(l.root, fmt.Sprintf(".deleted.%s.%d", id, time.Now().UnixNano()))
+	if err := os.Rename(dir, trash); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(trash)
+}
+
+// Import 将 dir 处的旧版单世界存档移入根目录, dir 不存在时什么也不做
+func (l *Library) Import(dir, name string) (string, error) {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+
+	id, err := l.mkdir(name)
+	if err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(l.root, id)
+	if err := os.Remove(dst); err != nil {
+		return "", err
+	}
+	if err := os.Rename(dir, dst); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// mkdir 按名称创建一个不重名的世界目录, 返回目录名
+func (l *Library) mkdir(name string) (string, error) {
+	base := dirName(name)
+	for i := 1; ; i++ {
+		id := base
+		if i > 1 {
+			id = fmt.Sprintf("%s-%d", base, i)
+		}
+
+		err := os.Mkdir(filepath.Join(l.root, id), 0777)
+		if err == nil {
+			return id, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+}
+
+func checkName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > MAX_NAME_LENGTH {
+		return "", ErrInvalidName
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return "", ErrInvalidName
+		}
+	}
+
+	return name, nil
+}
+
+// dirName 由世界名称生成目录名, 只保留字母、数字、- 和 _
+func dirName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	id := strings.Trim(b.String(), "_")
+	if id == "" {
+		id = "world"
+	}
+	return id
+}
+
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0777)
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,207 @@
+package saves
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weiWang95/mcworld/app/world"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "saves")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func newTestLibrary(t *testing.T) *Library {
+	lib, err := NewLibrary(tempDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lib
+}
+
+func listIds(t *testing.T, lib *Library) map[string]Summary {
+	list, err := lib.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]Summary)
+	for _, s := range list {
+		m[s.Id] = s
+	}
+	return m
+}
+
+func TestCreate(t *testing.T) {
+	lib := newTestLibrary(t)
+
+	a, err := lib.Create(CreateOptions{Name: "My World", Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Id != "My_World" || a.Name != "My World" || a.Seed != 1 || a.Generator != world.DEFAULT_GENERATOR {
+		t.Fatalf("%+v", a)
+	}
+
+	// 同名的世界使用不同的目录
+	b, err := lib.Create(CreateOptions{Name: "My World", Seed: 2, Generator: world.SUPERFLAT_GENERATOR})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Id != "My_World-2" || b.Generator != world.SUPERFLAT_GENERATOR {
+		t.Fatalf("%+v", b)
+	}
+
+	// 目录名不能跳出根目录
+	c, err := lib.Create(CreateOptions{Name: "../up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(filepath.Join(lib.Root(), c.Id)) != lib.Root() {
+		t.Fatalf("world dir %q escapes the root", c.Id)
+	}
+
+	for _, opts := range []CreateOptions{
+		{Name: "  "},
+		{Name: "bad\x00name"},
+		{Name: "Unknown", Generator: "no-such-generator"},
+	} {
+		if _, err := lib.Create(opts); err == nil {
+			t.Errorf("create %+v should fail", opts)
+		}
+	}
+	if n := len(listIds(t, lib)); n != 3 {
+		t.Fatalf("%d worlds after failed creates, want 3", n)
+	}
+}
+
+func TestListAndRename(t *testing.T) {
+	lib := newTestLibrary(t)
+	a, _ := lib.Create(CreateOptions{Name: "A", Seed: 1})
+	b, _ := lib.Create(CreateOptions{Name: "B", Seed: 2})
+
+	// 隐藏目录和普通文件不是世界
+	os.Mkdir(filepath.Join(lib.Root(), ".trash"), 0777)
+	ioutil.WriteFile(filepath.Join(lib.Root(), "notes.txt"), nil, 0666)
+	// 没有元数据的旧存档仍然列出
+	os.Mkdir(filepath.Join(lib.Root(), "legacy"), 0777)
+
+	ids := listIds(t, lib)
+	if len(ids) != 3 || ids[a.Id].Seed != 1 || ids[b.Id].Seed != 2 || ids["legacy"].Name != "legacy" {
+		t.Fatalf("%+v", ids)
+	}
+
+	if err := lib.Rename(a.Id, "Renamed"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := lib.Get(a.Id)
+	if err != nil || s.Name != "Renamed" || s.Seed != 1 {
+		t.Fatalf("%+v %v", s, err)
+	}
+	if err := lib.Rename(a.Id, ""); err != ErrInvalidName {
+		t.Fatalf("empty name: %v", err)
+	}
+	if err := lib.Rename("missing", "x"); err != ErrNotFound {
+		t.Fatalf("missing world: %v", err)
+	}
+	if err := lib.Rename("legacy", "x"); err == nil {
+		t.Fatal("renaming a world without level data should fail")
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	lib := newTestLibrary(t)
+	a, _ := lib.Create(CreateOptions{Name: "Source", Seed: 7})
+
+	// 打开一次, 写入存档版本
+	dir, _ := lib.Dir(a.Id)
+	sm, err := world.NewFileSaveManager(nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm.Close()
+
+	d, err := lib.Duplicate(a.Id, "Copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Id == a.Id || d.Name != "Copy" || d.Seed != 7 {
+		t.Fatalf("%+v", d)
+	}
+	if _, err := os.Stat(filepath.Join(lib.Root(), d.Id, "version")); err != nil {
+		t.Fatal("save data not copied", err)
+	}
+	if s, _ := lib.Get(a.Id); s.Name != "Source" {
+		t.Fatalf("source renamed to %q", s.Name)
+	}
+	if _, err := lib.Duplicate("missing", "x"); err != ErrNotFound {
+		t.Fatalf("missing world: %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	lib := newTestLibrary(t)
+	a, _ := lib.Create(CreateOptions{Name: "A"})
+	b, _ := lib.Create(CreateOptions{Name: "B"})
+
+	if err := lib.Delete(a.Id); err != nil {
+		t.Fatal(err)
+	}
+	ids := listIds(t, lib)
+	if _, ok := ids[a.Id]; ok || len(ids) != 1 {
+		t.Fatalf("%+v", ids)
+	}
+	if _, err := lib.Get(b.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{a.Id, "", "..", "../x", "."} {
+		if err := lib.Delete(id); err != ErrNotFound {
+			t.Errorf("delete %q: %v", id, err)
+		}
+	}
+	if infos, _ := ioutil.ReadDir(lib.Root()); len(infos) != 1 {
+		t.Fatalf("%d entries left in root", len(infos))
+	}
+}
+
+func TestImport(t *testing.T) {
+	lib := newTestLibrary(t)
+
+	// 旧版单世界存档: seed 文件和区块目录
+	old := filepath.Join(tempDir(t), "save")
+	os.MkdirAll(filepath.Join(old, "world", "w0"), 0777)
+	ioutil.WriteFile(filepath.Join(old, "seed"), []byte("99"), 0666)
+
+	id, err := lib.Import(old, world.DEFAULT_WORLD_NAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatal("old save still exists")
+	}
+
+	dir, err := lib.Dir(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := world.NewFileSaveManager(nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	if level := sm.LoadLevel(); level == nil || level.Seed != 99 {
+		t.Fatalf("%+v", level)
+	}
+
+	// 已经导入过时什么也不做
+	if id, err := lib.Import(old, "again"); id != "" || err != nil {
+		t.Fatalf("reimport: %q %v", id, err)
+	}
+}
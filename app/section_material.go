@@ -0,0 +1,135 @@
+package app
+
+import (
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/renderer"
+	"github.com/g3n/engine/texture"
+	"github.com/weiWang95/mcworld/app/blockv2"
+)
+
+const CHUNK_SHADER = "chunk"
+
+// 合并后的面跨越多个方块, 纹理坐标以方块为单位, 在片元着色器中取小数部分后映射到图集中的纹理范围.
+// 顶点光照分别保存阳光与红绿蓝方块光, 阳光乘以天空亮度后与方块光逐通道取较亮的, 再乘以环境光遮蔽.
+// 昼夜变化只需修改天空亮度
+const chunkVertexShader = `
+#include <attributes>
+
+in vec4 VertexTile;
+in vec4 VertexLight;
+in float VertexOcclusion;
+
+uniform mat4 MVP;
+
+out vec2 Texcoord;
+out vec4 Tile;
+out vec4 Light;
+out float Occlusion;
+
+void main() {
+    Texcoord = VertexTexcoord;
+    Tile = VertexTile;
+    Light = VertexLight;
+    Occlusion = VertexOcclusion;
+    gl_Position = MVP * vec4(VertexPosition, 1.0);
+}
+`
+
+const chunkFragmentShader = `
+precision highp float;
+
+uniform sampler2D MatTexture;
+uniform float SkyLight;
+
+in vec2 Texcoord;
+in vec4 Tile;
+in vec4 Light;
+in float Occlusion;
+
+out vec4 FragColor;
+
+void main() {
+    vec2 size = Tile.zw - Tile.xy;
+    // 纹理坐标 v 向上, 图集的 v 从图片顶部向下
+    vec2 local = vec2(fract(Texcoord.x), 1.0 - fract(Texcoord.y));
+    // 用未取小数的坐标计算导数, 避免平铺接缝处选错 mipmap 级别
+    vec4 color = textureGrad(MatTexture, Tile.xy + local*size, dFdx(Texcoord)*size, dFdy(Texcoord)*size);
+    // 草和花的纹理中透明的部分不绘制
+    if (color.a < 0.5) {
+        discard;
+    }
+
+    vec3 level = max(vec3(Light.x * SkyLight), Light.yzw) / 15.0;
+    vec3 shade = (level*0.8 + 0.2) * Occlusion;
+    FragColor = vec4(color.rgb * shade, color.a);
+}
+`
+
+// RegisterChunkShader 注册区段网格使用的着色器
+func RegisterChunkShader(r *renderer.Renderer) {
+	r.AddShader("chunk_vertex", chunkVertexShader)
+	r.AddShader("chunk_fragment", chunkFragmentShader)
+	r.AddProgram(CHUNK_SHADER, "chunk_vertex", "chunk_fragment")
+}
+
+// ChunkMaterial 区段网格的材质, 纹理为方块图集, 天空亮度由所属的 SectionMaterials 共享
+type ChunkMaterial struct {
+	material.Material
+
+	sky    *float32
+	uniSky gls.Uniform
+}
+
+func NewChunkMaterial(tex *texture.Texture2D, sky *float32) *ChunkMaterial {
+	m := new(ChunkMaterial)
+	m.Material.Init()
+	m.SetShader(CHUNK_SHADER)
+	m.SetShaderUnique(true)
+	m.SetUseLights(material.UseLightNone)
+	m.SetSide(material.SideFront)
+	m.AddTexture(tex)
+
+	m.sky = sky
+	m.uniSky.Init("SkyLight")
+
+	return m
+}
+
+func (m *ChunkMaterial) RenderSetup(gs *gls.GLS) {
+	m.Material.RenderSetup(gs)
+	gs.Uniform1f(m.uniSky.Location(gs), *m.sky)
+}
+
+// SectionMaterials 区段网格使用的材质, 每张图集一个
+type SectionMaterials struct {
+	bm *blockv2.BlockManager
+	// sky 天空亮度 0~1, 即阳光的比例
+	sky float32
+
+	mats map[int]*ChunkMaterial
+}
+
+func NewSectionMaterials(bm *blockv2.BlockManager, sky float32) *SectionMaterials {
+	m := new(SectionMaterials)
+	m.bm = bm
+	m.sky = sky
+	m.mats = make(map[int]*ChunkMaterial)
+	return m
+}
+
+func (m *SectionMaterials) Get(page int) *ChunkMaterial {
+	if mat, ok := m.mats[page]; ok {
+		return mat
+	}
+
+	mat := NewChunkMaterial(m.bm.AtlasPage(page), &m.sky)
+	m.mats[page] = mat
+
+	return mat
+}
+
+// SetSkyLight 设置天空亮度, 下一帧起所有区段生效, 不需要重建网格
+func (m *SectionMaterials) SetSkyLight(sky float32) {
+	m.sky = sky
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"sync"
+
+	"github.com/g3n/engine/util/logger"
+)
+
+// task 后台任务, run 在工作协程中执行, done 在渲染线程中执行, run 出错时 done 仍会执行
+type task struct {
+	name string
+	run  func()
+	done func()
+}
+
+// taskPool 在后台协程中执行网格生成与光照计算, 结果交回渲染线程处理.
+// Submit 与 Finish 只在渲染线程调用, 工作协程已满时任务暂存在 queue 中, 不会阻塞渲染线程
+type taskPool struct {
+	log *logger.Logger
+
+	tasks   chan *task
+	results chan *task
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	queue  []*task
+	closed bool
+}
+
+// newTaskPool 创建 workers 个工作协程的任务池, 一个工作协程时任务按提交顺序执行
+func newTaskPool(log *logger.Logger, workers int) *taskPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := new(taskPool)
+	p.log = log
+	p.tasks = make(chan *task, workers*2)
+	p.results = make(chan *task, workers*2)
+	p.done = make(chan struct{})
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+
+	return p
+}
+
+// Submit 提交任务
+func (p *taskPool) Submit(t *task) {
+	if p.closed {
+		return
+	}
+
+	p.queue = append(p.queue, t)
+	p.flush()
+}
+
+// Finish 处理最多 max 个已完成的任务, 返回处理的个数
+func (p *taskPool) Finish(max int) int {
+	n := 0
+	for ; n < max; n++ {
+		select {
+		case t := <-p.results:
+			t.done()
+		default:
+			p.flush()
+			return n
+		}
+	}
+
+	p.flush()
+	return n
+}
+
+// Close 停止工作协程并等待正在执行的任务完成, 未处理的任务被丢弃
+func (p *taskPool) Close() {
+	if p.closed {
+		return
+	}
+	p.closed = true
+	p.queue = nil
+	close(p.done)
+	p.wg.Wait()
+}
+
+// flush 把暂存的任务交给工作协程, 直到通道已满
+func (p *taskPool) flush() {
+	for len(p.queue) > 0 {
+		select {
+		case p.tasks <- p.queue[0]:
+			p.queue[0] = nil
+			p.queue = p.queue[1:]
+		default:
+			return
+		}
+	}
+}
+
+func (p *taskPool) work() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case t := <-p.tasks:
+			p.run(t)
+			select {
+			case p.results <- t:
+			case <-p.done:
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *taskPool) run(t *task) {
+	defer func() {
+		if err := recover(); err != nil {
+			p.log.Error("%s panic: %v", t.name, err)
+		}
+	}()
+
+	t.run()
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"fmt"
 	"math"
 	"time"
 
@@ -8,18 +9,14 @@ import (
 	"github.com/g3n/engine/light"
 	"github.com/g3n/engine/math32"
 	"github.com/g3n/engine/util/logger"
-	"github.com/weiWang95/mcworld/app/blockv2"
-	"github.com/weiWang95/mcworld/lib/util"
+	"github.com/weiWang95/mcworld/app/world"
 )
 
 const DEFAULT_VIEW_DISTANCE int64 = 0
 const DEFAULT_GRAVITY_SPEED float32 = -9.8
 const MAX_GRAVITY_SPEED float32 = -20
 
-const DAY_TOTAL_TIME int64 = 12000          // 每日时长
-const DAY_NIGHT_TRANSITION_TIME int64 = 600 // 昼夜交替过渡时长
-const MIN_SUN_LEVEL = 0                     // 最小阳光登录
-
+// World 世界的渲染层, 世界数据保存在 world.World 中
 type World struct {
 	core.Node
 	*logger.Logger
@@ -27,19 +24,20 @@ type World struct {
 	lightCount float64
 	ambLight   *light.Ambient
 
-	sunLevel   uint8
-	curTime    int64
 	timeTicker *TickChecker
 
-	wg IWorldGenerator
-	cm *ChunkManager
-	bu *BlockUpdater
-	lu *LuminanceUpdater
+	wg   world.IWorldGenerator
+	data *world.World
+	cm   *ChunkManager
+	bu   *BlockUpdater
+	lu   *LuminanceUpdater
+	mats *SectionMaterials
 }
 
-func NewWorld() *World {
+func NewWorld(wg world.IWorldGenerator) *World {
 	w := new(World)
 	w.Node = *core.NewNode()
+	w.wg = wg
 
 	return w
 }
@@ -56,22 +54,20 @@ func (w *World) Update(a *App, t time.Duration) {
 	w.bu.Update(a, t)
 	w.lu.Update(a, t)
 
-	if w.timeTicker.Next(t) {
-		w.curTime += 1
-		if w.curTime > DAY_TOTAL_TIME {
-			w.curTime = 0
-		}
-		newSunLevel := w.CalSunLevel()
-		if w.sunLevel != newSunLevel {
-			a.Log().Debug("sun level update: t:%v %v -> %v", w.curTime, w.sunLevel, newSunLevel)
-			w.sunLevel = newSunLevel
-			w.lu.UpdateSumLum()
-			w.lu.SwitchDayNight()
-		}
+	if w.timeTicker.Next(t) && w.data.Tick() {
+		w.lu.SwitchDayNight()
 	}
 }
 
+// Cleanup 停止后台加载、网格生成与光照计算, 需要在保存之前调用
 func (w *World) Cleanup(a *App) {
+	if w.cm == nil {
+		return
+	}
+
+	w.cm.Cleanup()
+	w.bu.Cleanup()
+	w.lu.Cleanup()
 }
 
 func (w *World) setup(a *App) {
@@ -80,17 +76,24 @@ func (w *World) setup(a *App) {
 
 	// seed := time.Now().UnixNano()
 	// seed := int64(202210080000000)
-	w.setupWorldGenerator(a.seed)
+	data, err := world.NewWorld(a.Log(), a.bm, w.wg, world.DefaultDimension)
+	if err != nil {
+		panic(err)
+	}
+	w.data = data
+	w.data.SetCurTime(a.level.Time)
+	w.data.AddPendingWrites(a.SaveManager().LoadPendingWrites())
 
 	w.cm = NewChunkManager(a)
 	w.cm.Start(a)
 	w.Add(w.cm)
 
 	w.bu = NewBlockUpdater(a)
+	w.bu.SetSmoothLighting(a.smoothLighting)
 	w.lu = NewLuminanceUpdater(a)
+	w.mats = NewSectionMaterials(a.bm, w.data.SunLumRate())
 
 	w.timeTicker = NewTickChecker(1)
-	w.sunLevel = MIN_SUN_LEVEL
 }
 
 func (w *World) setupLight() {
@@ -99,9 +102,24 @@ func (w *World) setupLight() {
 	w.Add(w.ambLight)
 }
 
-func (w *World) setupWorldGenerator(seed int64) {
-	w.wg = &WorldGenerator{}
-	w.wg.Setup(seed)
+// newWorldGenerator 按世界元数据中的名称与参数创建生成器
+func newWorldGenerator(a *App, level *world.LevelData) (world.IWorldGenerator, error) {
+	wg, err := world.NewGenerator(level.Generator, level.GeneratorOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if dg, ok := wg.(*world.WorldGenerator); ok {
+		ores, err := world.LoadOres(fmt.Sprintf("%s/config/%s", a.DirData(), world.ORE_FILE))
+		if err != nil {
+			a.Log().Warn("load ores fail, use default: %v", err)
+		} else {
+			dg.Ores = ores
+		}
+	}
+
+	wg.Setup(level.Seed)
+	return wg, nil
 }
 
 func (w *World) updateLight(a *App, t time.Duration) {
@@ -110,30 +128,29 @@ func (w *World) updateLight(a *App, t time.Duration) {
 	w.lightCount += float64(t / (10 * time.Second))
 }
 
-func (w *World) GetBlockByVec(vec math32.Vector3) (block *blockv2.Block, chunkLoaded bool) {
-	return w.GetBlockByPosition(vec.X, vec.Y, vec.Z)
+// Data 返回世界数据
+func (w *World) Data() *world.World {
+	return w.data
 }
 
-func (w *World) GetBlockByPosition(x, y, z float32) (block *blockv2.Block, chunkLoaded bool) {
-	chunk := w.cm.GetChunk(x, y, z)
-	if chunk == nil {
-		return nil, false
-	}
-
-	return chunk.GetBlock(x, y, z), true
+// HasBlock 坐标处是否有方块
+func (w *World) HasBlock(vec math32.Vector3) bool {
+	id, _ := w.data.GetBlock(ToWorldPos(vec))
+	return id != world.BlockAir
 }
 
-func (w *World) GetLum(x, y, z float32) (lum Luminance, chunkLoaded bool) {
-	chunk := w.cm.GetChunk(x, y, z)
-	if chunk == nil {
-		return 0, false
-	}
+// HasSolidBlock 坐标处是否有阻挡移动的方块, 草和花可以穿过
+func (w *World) HasSolidBlock(vec math32.Vector3) bool {
+	id, _ := w.data.GetBlock(ToWorldPos(vec))
+	return w.data.BlockSolid(id)
+}
 
-	return chunk.GetLumByWorldPos(x, y, z), true
+func (w *World) GetLum(x, y, z float32) (lum world.Luminance, chunkLoaded bool) {
+	return w.data.GetLum(ToWorldPos(*math32.NewVector3(x, y, z)))
 }
 
-func (w *World) GetLumByVec(vec math32.Vector3) (lum Luminance, chunkLoaded bool) {
-	return w.GetLum(vec.X, vec.Y, vec.Z)
+func (w *World) GetLumByVec(vec math32.Vector3) (lum world.Luminance, chunkLoaded bool) {
+	return w.data.GetLum(ToWorldPos(vec))
 }
 
 func (w *World) WreckBlock(pos math32.Vector3) {
@@ -141,52 +158,25 @@ func (w *World) WreckBlock(pos math32.Vector3) {
 	// area := w.getArea(pos.X, pos.Z)
 	// area.ReplaceBlock(pos, nil)
 	chunk := w.cm.GetChunk(pos.X, pos.Y, pos.Z)
-	if chunk.ReplaceBlock(pos, nil) {
-		w.bu.TiggerUpdate(util.NewPosFromVec3(pos))
-		w.lu.TiggerUpdate(util.NewPosFromVec3(pos))
+	if chunk != nil && chunk.ReplaceBlock(pos, world.BlockAir) {
+		w.bu.TiggerUpdate(ToWorldPos(pos))
+		w.lu.TiggerUpdate(ToWorldPos(pos))
 	}
 }
 
-func (w *World) PlaceBlock(block *blockv2.Block, pos math32.Vector3) {
-	w.Debug("place block:%T -> %v", block, pos)
+func (w *World) PlaceBlock(id world.BlockId, pos math32.Vector3) {
+	w.Debug("place block:%d -> %v", id, pos)
 	chunk := w.cm.GetChunk(pos.X, pos.Y, pos.Z)
-	if chunk.ReplaceBlock(pos, block) {
-		w.bu.TiggerUpdate(util.NewPosFromVec3(pos))
-		w.lu.TiggerUpdate(util.NewPosFromVec3(pos))
+	if chunk != nil && chunk.ReplaceBlock(pos, id) {
+		w.bu.TiggerUpdate(ToWorldPos(pos))
+		w.lu.TiggerUpdate(ToWorldPos(pos))
 	}
 }
 
-func (w *World) WorldGenerator() IWorldGenerator {
-	return w.wg
-}
-
-func (w *World) CalSunLevel() uint8 {
-	tHalf := DAY_NIGHT_TRANSITION_TIME / 2
-	dawnStart := DAY_TOTAL_TIME/2 - DAY_NIGHT_TRANSITION_TIME
-	dawnEnd := DAY_TOTAL_TIME/2 - tHalf
-	duskStart := DAY_TOTAL_TIME - DAY_NIGHT_TRANSITION_TIME
-	duskEnd := DAY_TOTAL_TIME
-
-	speed := 2
-	stepTime := DAY_NIGHT_TRANSITION_TIME / (int64(MAX_LUM - MIN_SUN_LEVEL)) * int64(speed)
-
-	if w.curTime >= 0 && w.curTime < dawnStart {
-		return MIN_SUN_LEVEL
-	} else if w.curTime >= dawnStart && w.curTime < dawnEnd {
-		// return MIN_SUN_LEVEL
-		addSun := (w.curTime - dawnStart) / stepTime
-		return MIN_SUN_LEVEL + uint8(addSun)*uint8(speed)
-	} else if w.curTime >= dawnEnd && w.curTime < duskStart {
-		return MAX_LUM
-	} else if w.curTime >= duskStart && w.curTime <= duskEnd {
-		// return MAX_LUM
-		addSun := (w.curTime - duskStart) / stepTime
-		return MAX_LUM - uint8(addSun)*uint8(speed)
-	}
-
-	return MAX_LUM
+func (w *World) WorldGenerator() world.IWorldGenerator {
+	return w.data.WorldGenerator()
 }
 
 func (w *World) SunLumRate() float32 {
-	return float32(w.sunLevel) / float32(MAX_LUM)
+	return w.data.SunLumRate()
 }
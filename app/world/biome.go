@@ -0,0 +1,171 @@
+package world
+
+import "math"
+
+type BiomeId uint8
+
+// BiomeNone 表示尚未确定群系, 例如旧存档中的区块
+const (
+	BiomeNone BiomeId = iota
+	BiomePlains
+	BiomeDesert
+	BiomeForest
+	BiomeMountains
+	BiomeOcean
+	BiomeTundra
+)
+
+// BIOME_BLEND_WIDTH 气候空间中群系过渡带的宽度, 越大边界越平缓
+const BIOME_BLEND_WIDTH = 0.3
+
+// Biome 群系, 由温度与湿度决定, 并决定地形高度与各层方块
+type Biome struct {
+	Id   BiomeId
+	Name string
+
+	// 群系在气候空间中的中心, 取值约为 [-1, 1]
+	Temperature float64
+	Humidity    float64
+
+	// 地形高度 = BaseHeight + HeightVariation * 起伏噪声
+	BaseHeight      float64
+	HeightVariation float64
+	// Ridged 为 true 时使用山脊噪声, 地形更加陡峭
+	Ridged bool
+	// Overhang 三维噪声对地表的最大扰动格数, 产生悬崖和拱桥, 0 表示纯高度图
+	Overhang float64
+
+	Surface         BlockId // 最上层方块
+	Subsurface      BlockId // 表层之下 SubsurfaceDepth 格
+	SubsurfaceDepth int64
+	Filler          BlockId // 其余部分
+
+	// Decorations 地形生成后放置的装饰物, 每列最多放置一个, 靠前的优先
+	Decorations []Decoration
+}
+
+var (
+	oakTree   = &TreeFeature{Log: BlockLog, Leaves: BlockLeaves, MinHeight: 4, MaxHeight: 6, Ground: []BlockId{BlockGrass, BlockSoil}}
+	snowyTree = &TreeFeature{Log: BlockLog, Leaves: BlockLeaves, MinHeight: 5, MaxHeight: 7, Ground: []BlockId{BlockSnow, BlockSoil}}
+	tallGrass = &PlantFeature{Blocks: []BlockId{BlockTallGrass}, Ground: []BlockId{BlockGrass}}
+	flowers   = &PlantFeature{Blocks: []BlockId{BlockRose, BlockDandelion}, Ground: []BlockId{BlockGrass}}
+	cactus    = &ColumnFeature{Block: BlockCactus, MinHeight: 1, MaxHeight: 3, Ground: []BlockId{BlockSand}}
+)
+
+var biomes = []*Biome{
+	{
+		Id: BiomePlains, Name: "plains",
+		Temperature: 0.1, Humidity: 0,
+		BaseHeight: 16, HeightVariation: 4,
+		Surface: BlockGrass, Subsurface: BlockSoil, SubsurfaceDepth: 3, Filler: BlockStone,
+		Decorations: []Decoration{{oakTree, 0.4}, {flowers, 4}, {tallGrass, 14}},
+	},
+	{
+		Id: BiomeDesert, Name: "desert",
+		Temperature: 0.45, Humidity: -0.4,
+		BaseHeight: 15, HeightVariation: 3, Overhang: 5,
+		Surface: BlockSand, Subsurface: BlockSand, SubsurfaceDepth: 4, Filler: BlockSandstone,
+		Decorations: []Decoration{{cactus, 1.5}},
+	},
+	{
+		Id: BiomeForest, Name: "forest",
+		Temperature: 0.15, Humidity: 0.35,
+		BaseHeight: 18, HeightVariation: 6,
+		Surface: BlockGrass, Subsurface: BlockSoil, SubsurfaceDepth: 4, Filler: BlockStone,
+		Decorations: []Decoration{{oakTree, 7}, {flowers, 2}, {tallGrass, 6}},
+	},
+	{
+		Id: BiomeMountains, Name: "mountains",
+		Temperature: -0.2, Humidity: -0.15,
+		BaseHeight: 30, HeightVariation: 45, Ridged: true, Overhang: 14,
+		Surface: BlockStone, Subsurface: BlockStone, SubsurfaceDepth: 1, Filler: BlockStone,
+		Decorations: []Decoration{{snowyTree, 0.5}},
+	},
+	{
+		Id: BiomeOcean, Name: "ocean",
+		Temperature: 0.2, Humidity: 0.7,
+		BaseHeight: -6, HeightVariation: 5,
+		Surface: BlockSand, Subsurface: BlockSand, SubsurfaceDepth: 3, Filler: BlockStone,
+	},
+	{
+		Id: BiomeTundra, Name: "tundra",
+		Temperature: -0.5, Humidity: 0.2,
+		BaseHeight: 16, HeightVariation: 5,
+		Surface: BlockSnow, Subsurface: BlockSoil, SubsurfaceDepth: 3, Filler: BlockStone,
+		Decorations: []Decoration{{snowyTree, 0.8}},
+	},
+}
+
+var biomeMap = func() map[BiomeId]*Biome {
+	m := make(map[BiomeId]*Biome, len(biomes))
+	for _, b := range biomes {
+		m[b.Id] = b
+	}
+	return m
+}()
+
+// GetBiome 返回群系定义, 未知的群系返回 nil
+func GetBiome(id BiomeId) *Biome {
+	return biomeMap[id]
+}
+
+// GetBiomeByName 按名称查找群系, 不存在时返回 nil
+func GetBiomeByName(name string) *Biome {
+	for _, b := range biomes {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// Biomes 返回所有群系, 调用方不应修改返回值
+func Biomes() []*Biome {
+	return biomes
+}
+
+// climateDistance 气候点与群系中心的距离
+func (b *Biome) climateDistance(temperature, humidity float64) float64 {
+	dt, dh := temperature-b.Temperature, humidity-b.Humidity
+	return math.Sqrt(dt*dt + dh*dh)
+}
+
+// BiomeWeight 群系在混合中所占的权重
+type BiomeWeight struct {
+	Biome  *Biome
+	Weight float64
+}
+
+// BlendBiomes 计算气候点上各群系的权重, 权重之和为 1, 第一项为最近的群系.
+// 比最近群系远出 BIOME_BLEND_WIDTH 以上的群系不参与混合
+func BlendBiomes(temperature, humidity float64) []BiomeWeight {
+	nearest := biomes[0]
+	nearestDist := nearest.climateDistance(temperature, humidity)
+	for _, b := range biomes[1:] {
+		if d := b.climateDistance(temperature, humidity); d < nearestDist {
+			nearest, nearestDist = b, d
+		}
+	}
+
+	weights := []BiomeWeight{{Biome: nearest, Weight: 1}}
+	total := 1.0
+	for _, b := range biomes {
+		if b == nearest {
+			continue
+		}
+
+		// 到两个群系中心的距离差越小越接近边界, 在边界上两者权重相同
+		t := 1 - (b.climateDistance(temperature, humidity)-nearestDist)/BIOME_BLEND_WIDTH
+		if t <= 0 {
+			continue
+		}
+		w := t * t * (3 - 2*t) // smoothstep
+		weights = append(weights, BiomeWeight{Biome: b, Weight: w})
+		total += w
+	}
+
+	for i := range weights {
+		weights[i].Weight /= total
+	}
+	return weights
+}
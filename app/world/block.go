@@ -0,0 +1,120 @@
+package world
+
+import (
+	"strconv"
+	"strings"
+)
+
+type BlockId uint64
+
+const BlockAir BlockId = 0
+
+// 生成器放置的方块 id, 与 data/config/block.json 一致
+const (
+	BlockGrass     BlockId = 2
+	BlockBrick     BlockId = 3
+	BlockLamp      BlockId = 4
+	BlockSoil      BlockId = 5
+	BlockStone     BlockId = 6
+	BlockSand      BlockId = 7
+	BlockWater     BlockId = 8
+	BlockSnow      BlockId = 9
+	BlockSandstone BlockId = 10
+	BlockCoalOre   BlockId = 11
+	BlockIronOre   BlockId = 12
+	BlockGoldOre   BlockId = 13
+	BlockLog       BlockId = 14
+	BlockLeaves    BlockId = 15
+	BlockTallGrass BlockId = 16
+	BlockRose      BlockId = 17
+	BlockDandelion BlockId = 18
+	BlockCactus    BlockId = 19
+)
+
+// blockNames 生成器配置中可以使用的方块名称, 与 data/config/block.json 中的名称一致
+var blockNames = map[string]BlockId{
+	"air":       BlockAir,
+	"grass":     BlockGrass,
+	"brick":     BlockBrick,
+	"lamp":      BlockLamp,
+	"soil":      BlockSoil,
+	"stone":     BlockStone,
+	"sand":      BlockSand,
+	"water":     BlockWater,
+	"snow":      BlockSnow,
+	"sandstone": BlockSandstone,
+	"coalore":   BlockCoalOre,
+	"ironore":   BlockIronOre,
+	"goldore":   BlockGoldOre,
+	"log":       BlockLog,
+	"leaves":    BlockLeaves,
+	"tallgrass": BlockTallGrass,
+	"rose":      BlockRose,
+	"dandelion": BlockDandelion,
+	"cactus":    BlockCactus,
+}
+
+// ParseBlockId 按名称 (不区分大小写) 或数字 id 查找方块
+func ParseBlockId(text string) (BlockId, bool) {
+	text = strings.TrimSpace(text)
+	if id, ok := blockNames[strings.ToLower(text)]; ok {
+		return id, true
+	}
+	if v, err := strconv.ParseUint(text, 10, 64); err == nil {
+		return BlockId(v), true
+	}
+	return BlockAir, false
+}
+
+// RenderType 方块的绘制方式
+type RenderType uint8
+
+const (
+	RenderCube  RenderType = iota // 立方体, 只绘制露出的面
+	RenderCross                   // 两个沿对角线交叉的平面, 用于草和花
+)
+
+// IBlockRegistry 世界逻辑需要的方块属性, 不涉及方块的渲染方式
+type IBlockRegistry interface {
+	// GetBlockLight 方块发出的红绿蓝光照
+	GetBlockLight(id BlockId) LightColor
+	// IsTransparent 方块是否透明, 透明方块不遮挡相邻方块的面
+	IsTransparent(id BlockId) bool
+	// IsSolid 方块是否阻挡移动
+	IsSolid(id BlockId) bool
+	// GetRenderType 方块的绘制方式
+	GetRenderType(id BlockId) RenderType
+}
+
+// BlockTransparent 方块是否透明, 没有方块属性时只有空气透明
+func (w *World) BlockTransparent(id BlockId) bool {
+	if id == BlockAir {
+		return true
+	}
+	if w.br == nil {
+		return false
+	}
+
+	return w.br.IsTransparent(id)
+}
+
+// BlockSolid 方块是否阻挡移动, 没有方块属性时除空气外都阻挡
+func (w *World) BlockSolid(id BlockId) bool {
+	if id == BlockAir {
+		return false
+	}
+	if w.br == nil {
+		return true
+	}
+
+	return w.br.IsSolid(id)
+}
+
+// BlockRenderType 方块的绘制方式, 没有方块属性时都是立方体
+func (w *World) BlockRenderType(id BlockId) RenderType {
+	if w.br == nil {
+		return RenderCube
+	}
+
+	return w.br.GetRenderType(id)
+}
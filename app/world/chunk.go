@@ -0,0 +1,384 @@
+package world
+
+import "sync"
+
+const CHUNK_WIDTH = SECTION_SIZE
+
+// Chunk 区块数据, 只保存方块 id 与光照, 不包含任何渲染对象
+// 区块由垂直排列的区段组成, 全部为空气的区段不分配内存
+//
+// 导出的方法都可以并发调用, 每次调用单独加锁. 方块和光照只由世界逻辑所在的协程修改,
+// 其它协程 (存档、网络等) 需要多个方块之间一致时应读取 Snapshot.
+type Chunk struct {
+	pos ChunkPos
+	dim Dimension
+
+	mu       sync.RWMutex
+	sections []*Section
+	// 未分配区段的光照
+	emptyLum Luminance
+	// 每列的群系, 下标为 x*CHUNK_WIDTH+z
+	biomes []BiomeId
+	// 是否已放置树木等装饰物
+	decorated bool
+	// 光照是否已计算, 未计算的区块不参与光照传播
+	lightValid bool
+}
+
+func NewChunk(x, z int64, dim Dimension) *Chunk {
+	c := new(Chunk)
+	c.pos = ChunkPos{X: x, Z: z}
+	c.dim = dim
+	c.sections = make([]*Section, dim.SectionCount())
+	c.biomes = make([]BiomeId, CHUNK_WIDTH*CHUNK_WIDTH)
+	return c
+}
+
+func (c *Chunk) Pos() ChunkPos {
+	return c.pos
+}
+
+func (c *Chunk) Dimension() Dimension {
+	return c.dim
+}
+
+func (c *Chunk) MinY() int64 {
+	return c.dim.MinY
+}
+
+func (c *Chunk) MaxY() int64 {
+	return c.dim.MaxY()
+}
+
+func (c *Chunk) SectionCount() int {
+	return len(c.sections)
+}
+
+// Section 返回第 i 个区段的副本, 区段为空时返回 nil
+func (c *Chunk) Section(i int) *Section {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.sections[i] == nil {
+		return nil
+	}
+	return c.sections[i].clone()
+}
+
+// HasSection 第 i 个区段是否已分配
+func (c *Chunk) HasSection(i int) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.sections[i] != nil
+}
+
+// SectionMinY 第 i 个区段最低点的 y 坐标
+func (c *Chunk) SectionMinY(i int) int64 {
+	return c.dim.MinY + int64(i)*SECTION_SIZE
+}
+
+func (c *Chunk) sectionIdx(y int64) int {
+	return int((y - c.dim.MinY) / SECTION_SIZE)
+}
+
+// sectionY y 在所在区段内的高度
+func (c *Chunk) sectionY(y int64) int64 {
+	return (y - c.dim.MinY) % SECTION_SIZE
+}
+
+func (c *Chunk) section(y int64) *Section {
+	return c.sections[c.sectionIdx(y)]
+}
+
+func (c *Chunk) ensureSection(y int64) *Section {
+	i := c.sectionIdx(y)
+	if c.sections[i] == nil {
+		c.sections[i] = NewSection(c.emptyLum)
+	}
+
+	return c.sections[i]
+}
+
+// Generate 由世界生成器填充区块, 生成器实现 IChunkPopulator 时随后进行区块级处理
+func (c *Chunk) Generate(wg IWorldGenerator) {
+	c.generateTerrain(wg)
+
+	if p, ok := wg.(IChunkPopulator); ok {
+		p.Populate(c)
+	}
+}
+
+func (c *Chunk) generateTerrain(wg IWorldGenerator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	blocks := make([]BlockId, c.dim.Height)
+	for x := int64(0); x < CHUNK_WIDTH; x++ {
+		for z := int64(0); z < CHUNK_WIDTH; z++ {
+			pos := c.GetWorldPos(x, 0, z)
+			c.biomes[x*CHUNK_WIDTH+z] = wg.GenerateColumn(pos.X, pos.Z, c.MinY(), blocks)
+			for i, id := range blocks {
+				if id != BlockAir {
+					c.setBlock(x, c.MinY()+int64(i), z, id)
+				}
+			}
+		}
+	}
+}
+
+// FillMissingBiomes 补全没有群系的列, 用于没有保存群系的旧存档
+func (c *Chunk) FillMissingBiomes(wg IWorldGenerator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for x := int64(0); x < CHUNK_WIDTH; x++ {
+		for z := int64(0); z < CHUNK_WIDTH; z++ {
+			if c.biomes[x*CHUNK_WIDTH+z] != BiomeNone {
+				continue
+			}
+			pos := c.GetWorldPos(x, 0, z)
+			c.biomes[x*CHUNK_WIDTH+z] = wg.GetBiome(float64(pos.X), float64(pos.Z))
+		}
+	}
+}
+
+func (c *Chunk) Decorated() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.decorated
+}
+
+func (c *Chunk) SetDecorated(decorated bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.decorated = decorated
+}
+
+// LightValid 光照是否已计算并且与方块一致
+func (c *Chunk) LightValid() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.lightValid
+}
+
+// InvalidateLight 方块在光照计算之外发生变化, 需要重新计算光照
+func (c *Chunk) InvalidateLight() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lightValid = false
+}
+
+// Biome 返回区块内 x z 列的群系
+func (c *Chunk) Biome(x, z int64) BiomeId {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.biome(x, z)
+}
+
+func (c *Chunk) biome(x, z int64) BiomeId {
+	if x < 0 || x >= CHUNK_WIDTH || z < 0 || z >= CHUNK_WIDTH {
+		return BiomeNone
+	}
+
+	return c.biomes[x*CHUNK_WIDTH+z]
+}
+
+// RangeBlocks 遍历区块内所有非空气方块, x z 为区块内坐标, y 为世界高度.
+// 遍历期间持有读锁, fn 不能修改本区块
+func (c *Chunk) RangeBlocks(fn func(x, y, z int64, id BlockId)) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	c.rangeBlocks(fn)
+}
+
+func (c *Chunk) rangeBlocks(fn func(x, y, z int64, id BlockId)) {
+	for i, s := range c.sections {
+		if s == nil || s.blocks.IsEmpty() {
+			continue
+		}
+
+		minY := c.SectionMinY(i)
+		for y := int64(0); y < SECTION_SIZE; y++ {
+			for x := int64(0); x < CHUNK_WIDTH; x++ {
+				for z := int64(0); z < CHUNK_WIDTH; z++ {
+					if id := s.GetBlock(x, y, z); id != BlockAir {
+						fn(x, minY+y, z, id)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Compact 压缩所有区段的方块存储
+func (c *Chunk) Compact() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range c.sections {
+		if s != nil {
+			s.blocks.Compact()
+		}
+	}
+}
+
+func (c *Chunk) PosOverRange(x, y, z int64) bool {
+	return !c.dim.InRange(y) ||
+		x < 0 || x >= CHUNK_WIDTH ||
+		z < 0 || z >= CHUNK_WIDTH
+}
+
+// GetWorldPos 区块内坐标转世界坐标
+func (c *Chunk) GetWorldPos(x, y, z int64) Pos {
+	return NewPos(c.pos.X*CHUNK_WIDTH+x, y, c.pos.Z*CHUNK_WIDTH+z)
+}
+
+// ConvertChunkPos 世界坐标转区块内坐标, y 保持不变
+func (c *Chunk) ConvertChunkPos(pos Pos) Pos {
+	return NewPos(pos.X-c.pos.X*CHUNK_WIDTH, pos.Y, pos.Z-c.pos.Z*CHUNK_WIDTH)
+}
+
+func (c *Chunk) GetBlock(x, y, z int64) BlockId {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.getBlock(x, y, z)
+}
+
+func (c *Chunk) getBlock(x, y, z int64) BlockId {
+	if c.PosOverRange(x, y, z) {
+		return BlockAir
+	}
+
+	s := c.section(y)
+	if s == nil {
+		return BlockAir
+	}
+
+	return s.GetBlock(x, c.sectionY(y), z)
+}
+
+func (c *Chunk) SetBlock(x, y, z int64, id BlockId) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.setBlock(x, y, z, id)
+}
+
+func (c *Chunk) setBlock(x, y, z int64, id BlockId) bool {
+	if c.PosOverRange(x, y, z) {
+		return false
+	}
+
+	if id == BlockAir && c.section(y) == nil {
+		return true
+	}
+
+	c.ensureSection(y).SetBlock(x, c.sectionY(y), z, id)
+	return true
+}
+
+func (c *Chunk) GetLum(pos Pos) Luminance {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.getLum(pos)
+}
+
+func (c *Chunk) getLum(pos Pos) Luminance {
+	if c.PosOverRange(pos.X, pos.Y, pos.Z) {
+		return 0
+	}
+
+	s := c.section(pos.Y)
+	if s == nil {
+		return c.emptyLum
+	}
+
+	return s.GetLum(pos.X, c.sectionY(pos.Y), pos.Z)
+}
+
+func (c *Chunk) SetLum(pos Pos, lum Luminance) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.PosOverRange(pos.X, pos.Y, pos.Z) {
+		return
+	}
+
+	if lum == c.emptyLum && c.section(pos.Y) == nil {
+		return
+	}
+
+	c.ensureSection(pos.Y).SetLum(pos.X, c.sectionY(pos.Y), pos.Z, lum)
+}
+
+// InitSkyLight 清除区块光照, 从世界顶部向下填充直射阳光, 直到遇到 passes 返回 false 的方块,
+// 返回每列最低的直射高度, 下标为 x*CHUNK_WIDTH+z.
+// 整段都在直射范围内的空区段保持不分配, 光照由 emptyLum 表示. 之后区块参与光照传播
+func (c *Chunk) InitSkyLight(passes func(id BlockId) bool) []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	heights := make([]int64, CHUNK_WIDTH*CHUNK_WIDTH)
+	top := c.MinY()
+	for x := int64(0); x < CHUNK_WIDTH; x++ {
+		for z := int64(0); z < CHUNK_WIDTH; z++ {
+			y := c.MaxY()
+			for y > c.MinY() {
+				// 空区段整段跳过
+				if c.section(y-1) == nil {
+					y = c.SectionMinY(c.sectionIdx(y - 1))
+					continue
+				}
+				if !passes(c.getBlock(x, y-1, z)) {
+					break
+				}
+				y--
+			}
+
+			heights[x*CHUNK_WIDTH+z] = y
+			if y > top {
+				top = y
+			}
+		}
+	}
+
+	sky := NewLuminance(MAX_LUM, 0)
+	c.emptyLum = sky
+	c.lightValid = true
+	for i, s := range c.sections {
+		minY := c.SectionMinY(i)
+		if minY >= top {
+			if s != nil {
+				s.lums, s.lum = nil, sky
+			}
+			continue
+		}
+
+		// 低于地表的空区段也需要分配, 否则会被当作直射
+		if s == nil {
+			s = NewSection(0)
+			c.sections[i] = s
+		}
+		s.lums, s.lum = nil, 0
+		for x := int64(0); x < CHUNK_WIDTH; x++ {
+			for z := int64(0); z < CHUNK_WIDTH; z++ {
+				for y := heights[x*CHUNK_WIDTH+z]; y < minY+SECTION_SIZE; y++ {
+					if y >= minY {
+						s.SetLum(x, y-minY, z, sky)
+					}
+				}
+			}
+		}
+	}
+
+	return heights
+}
@@ -0,0 +1,135 @@
+package world
+
+import "fmt"
+
+type cPos uint16
+
+// ChunkData 区块存档数据, 只保存非空的区段
+type ChunkData struct {
+	// Version 数据版本, 旧存档没有此字段, 读取后由 MigrateChunk 升级到 CHUNK_DATA_VERSION
+	Version  int
+	Pos      ChunkPos
+	Sections []SectionData
+	// Biomes 每列的群系, 下标为 x*16+z, 早期存档没有此字段
+	Biomes []BiomeId `msgpack:",omitempty"`
+	// Decorated 是否已放置装饰物, 版本 3 起使用
+	Decorated bool
+	// LightValid 区段中保存的光照是否有效, 版本 4 起使用, 无效时加载后重新计算
+	LightValid bool
+	// Data 版本 1 按方块保存, 高度固定从 0 开始, 只在迁移旧存档时使用
+	Data map[cPos]BlockData `msgpack:",omitempty"`
+}
+
+type SectionData struct {
+	Y       int64 // 区段最低点的 y 坐标
+	Palette []BlockId
+	Bits    uint8
+	Blocks  []uint64
+	// Lights 区段的光照, 整个区段相同时为空, 使用 Light. 版本 5 起使用
+	Lights []Luminance `msgpack:",omitempty"`
+	Light  Luminance
+	// LegacyLums 版本 4 的单色光照, 高 4 位为阳光, 只在迁移旧存档时使用
+	LegacyLums []uint8 `msgpack:"Lums,omitempty"`
+	LegacyLum  uint8   `msgpack:"Lum,omitempty"`
+}
+
+type BlockData struct {
+	Id    BlockId
+	State uint8
+}
+
+// ConvertChunk 生成区块的存档数据, 数据是区块当前状态的副本
+func ConvertChunk(c *Chunk) ChunkData {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data := ChunkData{
+		Version:    CHUNK_DATA_VERSION,
+		Pos:        c.pos,
+		Sections:   make([]SectionData, 0, len(c.sections)),
+		Biomes:     append([]BiomeId(nil), c.biomes...),
+		Decorated:  c.decorated,
+		LightValid: c.lightValid,
+	}
+
+	for i, s := range c.sections {
+		if s == nil {
+			continue
+		}
+		// 没有方块但光照与空区段不同的区段 (例如地下的空洞) 也需要保存
+		hasLight := c.lightValid && (s.lums != nil || s.lum != c.emptyLum)
+		if s.blocks.IsEmpty() && !hasLight {
+			continue
+		}
+
+		palette, bits, blocks := s.blocks.Export()
+		sd := SectionData{
+			Y:       c.SectionMinY(i),
+			Palette: palette,
+			Bits:    uint8(bits),
+			Blocks:  blocks,
+		}
+		if c.lightValid {
+			sd.Light = s.lum
+			if s.lums != nil {
+				sd.Lights = append([]Luminance(nil), s.lums...)
+			}
+		}
+		data.Sections = append(data.Sections, sd)
+	}
+
+	return data
+}
+
+// LoadFromData 由存档数据填充区块, 数据需要先由 MigrateChunk 升级到当前版本
+func (c *Chunk) LoadFromData(data ChunkData) error {
+	if data.Version != CHUNK_DATA_VERSION {
+		return fmt.Errorf("chunk %v data version %d, want %d", data.Pos, data.Version, CHUNK_DATA_VERSION)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.sections {
+		c.sections[i] = nil
+	}
+	for i := range c.biomes {
+		c.biomes[i] = BiomeNone
+	}
+	if len(data.Biomes) == len(c.biomes) {
+		copy(c.biomes, data.Biomes)
+	}
+	c.decorated = data.Decorated
+	c.lightValid = data.LightValid
+	// 光照有效时空区段都在直射范围内
+	c.emptyLum = 0
+	if c.lightValid {
+		c.emptyLum = NewLuminance(MAX_LUM, 0)
+	}
+
+	for _, sd := range data.Sections {
+		if !c.dim.InRange(sd.Y) || (sd.Y-c.dim.MinY)%SECTION_SIZE != 0 {
+			return fmt.Errorf("chunk %v section y %d out of world range", data.Pos, sd.Y)
+		}
+
+		blocks, err := NewPalettedStorageFrom(SECTION_VOLUME, sd.Palette, uint(sd.Bits), sd.Blocks)
+		if err != nil {
+			return fmt.Errorf("chunk %v section y %d: %v", data.Pos, sd.Y, err)
+		}
+
+		s := NewSection(c.emptyLum)
+		s.blocks = blocks
+		if c.lightValid {
+			if len(sd.Lights) != 0 && len(sd.Lights) != SECTION_VOLUME {
+				return fmt.Errorf("chunk %v section y %d: invalid light length %d", data.Pos, sd.Y, len(sd.Lights))
+			}
+			s.lum = sd.Light
+			if len(sd.Lights) != 0 {
+				s.lums = sd.Lights
+			}
+		}
+		c.sections[c.sectionIdx(sd.Y)] = s
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package world
+
+import "sync"
+
+// ChunkLoader 在后台协程中读取或生成区块, 完成的区块通过 Results 交回调用方.
+// 离中心最近的区块优先处理, 不再需要的区块可以随时取消.
+//
+// 工作协程只调用 World.LoadChunk 和 ISaveManager.SaveChunk, 不访问已加载的区块,
+// 因此 IWorldGenerator 需要支持并发调用.
+type ChunkLoader struct {
+	w  *World
+	sm ISaveManager
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	center  ChunkPos
+	queue   map[ChunkPos]bool
+	running map[ChunkPos]bool // 正在处理的区块, false 表示已取消
+	closed  bool
+
+	results chan *Chunk
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewChunkLoader 创建 workers 个工作协程的区块加载器, sm 可以为空
+func NewChunkLoader(w *World, sm ISaveManager, workers int) *ChunkLoader {
+	if workers < 1 {
+		workers = 1
+	}
+
+	l := new(ChunkLoader)
+	l.w = w
+	l.sm = sm
+	l.cond = sync.NewCond(&l.mu)
+	l.queue = make(map[ChunkPos]bool)
+	l.running = make(map[ChunkPos]bool)
+	l.results = make(chan *Chunk, workers*2)
+	l.done = make(chan struct{})
+
+	l.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go l.work()
+	}
+
+	return l
+}
+
+// Results 加载完成的区块, 区块尚未加入世界
+func (l *ChunkLoader) Results() <-chan *Chunk {
+	return l.results
+}
+
+// SetCenter 设置优先加载的中心
+func (l *ChunkLoader) SetCenter(pos ChunkPos) {
+	l.mu.Lock()
+	l.center = pos
+	l.mu.Unlock()
+}
+
+// Request 请求加载区块, 已在队列中或正在加载时不会重复加载
+func (l *ChunkLoader) Request(pos ChunkPos) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed || l.queue[pos] {
+		return
+	}
+	if _, ok := l.running[pos]; ok {
+		l.running[pos] = true
+		return
+	}
+
+	l.queue[pos] = true
+	l.cond.Signal()
+}
+
+// Cancel 取消区块的加载, 正在加载的区块完成后会被丢弃
+func (l *ChunkLoader) Cancel(pos ChunkPos) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cancel(pos)
+}
+
+// Retain 取消所有 keep 返回 false 的区块
+func (l *ChunkLoader) Retain(keep func(pos ChunkPos) bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for pos := range l.queue {
+		if !keep(pos) {
+			l.cancel(pos)
+		}
+	}
+	for pos := range l.running {
+		if !keep(pos) {
+			l.cancel(pos)
+		}
+	}
+}
+
+func (l *ChunkLoader) cancel(pos ChunkPos) {
+	delete(l.queue, pos)
+	if _, ok := l.running[pos]; ok {
+		l.running[pos] = false
+	}
+}
+
+// Pending 等待或正在加载的区块数
+func (l *ChunkLoader) Pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := len(l.queue)
+	for _, keep := range l.running {
+		if keep {
+			n++
+		}
+	}
+	return n
+}
+
+// Close 停止所有工作协程并等待正在加载的区块完成, 未交出的结果被丢弃
+func (l *ChunkLoader) Close() {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.closed = true
+	l.queue = make(map[ChunkPos]bool)
+	close(l.done)
+	l.cond.Broadcast()
+	l.mu.Unlock()
+
+	l.wg.Wait()
+}
+
+func (l *ChunkLoader) work() {
+	defer l.wg.Done()
+
+	for {
+		pos, ok := l.next()
+		if !ok {
+			return
+		}
+
+		c := l.load(pos)
+
+		l.mu.Lock()
+		keep := l.running[pos] && !l.closed
+		delete(l.running, pos)
+		l.mu.Unlock()
+
+		if !keep || c == nil {
+			continue
+		}
+
+		select {
+		case l.results <- c:
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// next 取出离中心最近的区块, 加载器关闭时返回 false
+func (l *ChunkLoader) next() (ChunkPos, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for len(l.queue) == 0 && !l.closed {
+		l.cond.Wait()
+	}
+	if l.closed {
+		return ChunkPos{}, false
+	}
+
+	var best ChunkPos
+	bestDist := int64(-1)
+	for pos := range l.queue {
+		dx, dz := pos.X-l.center.X, pos.Z-l.center.Z
+		if d := dx*dx + dz*dz; bestDist < 0 || d < bestDist {
+			best, bestDist = pos, d
+		}
+	}
+
+	delete(l.queue, best)
+	l.running[best] = true
+	return best, true
+}
+
+// load 读取或生成区块, 并保存新生成的区块
+func (l *ChunkLoader) load(pos ChunkPos) (c *Chunk) {
+	defer func() {
+		if err := recover(); err != nil {
+			l.w.log.Error("load chunk %s panic: %v", pos.Id(), err)
+			c = nil
+		}
+	}()
+
+	c, generated := l.w.LoadChunk(pos, l.sm)
+	if generated && l.sm != nil {
+		l.sm.SaveChunk(c)
+	}
+	return c
+}
@@ -0,0 +1,113 @@
+package world
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingSaveManager 记录每个区块被保存的次数
+type countingSaveManager struct {
+	ISaveManager
+
+	mu    sync.Mutex
+	saved map[ChunkPos]int
+}
+
+func (sm *countingSaveManager) SaveChunk(c *Chunk) error {
+	sm.mu.Lock()
+	sm.saved[c.Pos()]++
+	sm.mu.Unlock()
+	return sm.ISaveManager.SaveChunk(c)
+}
+
+func loadWithLoader(t *testing.T, w *World, sm ISaveManager, poses ...ChunkPos) {
+	l := NewChunkLoader(w, sm, 2)
+	defer l.Close()
+
+	for _, pos := range poses {
+		l.Request(pos)
+	}
+	for range poses {
+		select {
+		case c := <-l.Results():
+			if c == nil {
+				t.Fatal("chunk load failed")
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for chunks")
+		}
+	}
+}
+
+func TestChunkLoaderSavesGeneratedOnly(t *testing.T) {
+	dir := tempDir(t)
+	w := newGeneratedWorld(t, 3)
+	poses := []ChunkPos{{X: 0, Z: 0}, {X: 1, Z: 0}}
+
+	sm := &countingSaveManager{ISaveManager: newTestSaveManager(t, dir), saved: make(map[ChunkPos]int)}
+	loadWithLoader(t, w, sm, poses...)
+	sm.Close()
+	for _, pos := range poses {
+		if sm.saved[pos] != 1 {
+			t.Fatalf("generated chunk %v saved %d times, want 1", pos, sm.saved[pos])
+		}
+	}
+
+	// 从存档读取的区块不再保存
+	sm = &countingSaveManager{ISaveManager: newTestSaveManager(t, dir), saved: make(map[ChunkPos]int)}
+	defer sm.Close()
+	loadWithLoader(t, w, sm, poses...)
+	if len(sm.saved) != 0 {
+		t.Fatalf("loaded chunks saved again: %v", sm.saved)
+	}
+}
+
+func TestLoadChunkGenerated(t *testing.T) {
+	w := newGeneratedWorld(t, 3)
+	sm := newTestSaveManager(t, tempDir(t))
+	defer sm.Close()
+
+	c, generated := w.LoadChunk(ChunkPos{X: 4, Z: 4}, sm)
+	if !generated {
+		t.Fatal("missing chunk should be generated")
+	}
+	sm.SaveChunk(c)
+
+	if _, generated := w.LoadChunk(ChunkPos{X: 4, Z: 4}, sm); generated {
+		t.Fatal("saved chunk should be loaded")
+	}
+}
+
+func TestLoadQueuedChunk(t *testing.T) {
+	sm := newTestSaveManager(t, tempDir(t))
+	defer sm.Close()
+	cpos := ChunkPos{X: -2, Z: 9}
+
+	// 连续保存两次, 读取时总是得到最后一次保存的数据, 无论它是否已写入文件
+	c := generate(5, cpos)
+	sm.SaveChunk(c)
+	c.SetBlock(3, 4, 5, BlockLamp)
+	sm.SaveChunk(c)
+
+	data := sm.LoadChunk(cpos)
+	if data == nil {
+		t.Fatal("queued chunk not found")
+	}
+	loaded := NewChunk(cpos.X, cpos.Z, DefaultDimension)
+	if err := loaded.LoadFromData(*data); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.GetBlock(3, 4, 5) != BlockLamp {
+		t.Fatal("loaded stale chunk data")
+	}
+
+	// 读取的副本与队列中的数据互不影响
+	loaded.SetBlock(3, 4, 5, BlockBrick)
+	data = sm.LoadChunk(cpos)
+	loaded = NewChunk(cpos.X, cpos.Z, DefaultDimension)
+	loaded.LoadFromData(*data)
+	if loaded.GetBlock(3, 4, 5) != BlockLamp {
+		t.Fatal("queued data changed by a reader")
+	}
+}
@@ -0,0 +1,232 @@
+package world
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// PENDING_FILE 暂存写入的存档文件
+const PENDING_FILE = "pending.dat"
+
+// decorationSalt 装饰阶段的随机数 salt
+const decorationSalt uint64 = 0x6465636f
+
+// IChunkDecorator 生成器可选实现, 在区块及其周围 8 个区块都加入世界后放置树木等装饰物.
+// 装饰物可以写入相邻区块, 每个区块只装饰一次
+type IChunkDecorator interface {
+	Decorate(c *Chunk, w IFeatureWorld)
+}
+
+// BlockWrite 写入尚未加载的区块的方块, 区块加载后只在目标位置为空气时生效
+type BlockWrite struct {
+	Pos Pos
+	Id  BlockId
+}
+
+// Decorate 按每列的群系放置装饰物, 实现 IChunkDecorator
+func (wg *WorldGenerator) Decorate(c *Chunk, w IFeatureWorld) {
+	r := ChunkRand(wg.seed, c.Pos(), decorationSalt)
+	perColumn := float64(CHUNK_WIDTH * CHUNK_WIDTH)
+
+	for x := int64(0); x < CHUNK_WIDTH; x++ {
+		for z := int64(0); z < CHUNK_WIDTH; z++ {
+			b := GetBiome(c.Biome(x, z))
+			if b == nil {
+				continue
+			}
+
+			for _, d := range b.Decorations {
+				if r.Float64() >= d.Count/perColumn {
+					continue
+				}
+
+				ground, ok := c.topBlock(x, z)
+				if ok {
+					d.Feature.Place(w, r, c.GetWorldPos(ground.X, ground.Y, ground.Z))
+				}
+				// 每列最多一个装饰物
+				break
+			}
+		}
+	}
+}
+
+// topBlock 区块内 x z 列最高的非空气方块
+func (c *Chunk) topBlock(x, z int64) (Pos, bool) {
+	for y := c.MaxY() - 1; y >= c.MinY(); y-- {
+		if c.GetBlock(x, y, z) != BlockAir {
+			return NewPos(x, y, z), true
+		}
+	}
+	return Pos{}, false
+}
+
+// featureWriter 装饰阶段的方块读写, 写入未加载的区块时暂存
+type featureWriter struct {
+	w       *World
+	changed map[ChunkPos]bool
+}
+
+func (fw *featureWriter) GetBlock(pos Pos) (BlockId, bool) {
+	return fw.w.GetBlock(pos)
+}
+
+func (fw *featureWriter) SetBlock(pos Pos, id BlockId) {
+	if fw.w.PosOverRange(pos) {
+		return
+	}
+
+	c := fw.w.ChunkAt(pos)
+	if c == nil {
+		fw.w.addPendingWrite(BlockWrite{Pos: pos, Id: id})
+		return
+	}
+
+	if c.writeIfAir(c.ConvertChunkPos(pos), id) {
+		fw.changed[c.pos] = true
+	}
+}
+
+// writeIfAir 位置为空气时写入方块, pos 为区块内坐标
+func (c *Chunk) writeIfAir(pos Pos, id BlockId) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.getBlock(pos.X, pos.Y, pos.Z) != BlockAir {
+		return false
+	}
+	return c.setBlock(pos.X, pos.Y, pos.Z, id)
+}
+
+// decorateAround 装饰 cpos 周围所有已满足条件的区块, 方块发生变化的区块记录在 changed 中
+func (w *World) decorateAround(cpos ChunkPos, changed map[ChunkPos]bool) {
+	d, ok := w.wg.(IChunkDecorator)
+	if !ok {
+		return
+	}
+
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dz := int64(-1); dz <= 1; dz++ {
+			w.decorate(d, ChunkPos{X: cpos.X + dx, Z: cpos.Z + dz}, changed)
+		}
+	}
+}
+
+func (w *World) decorate(d IChunkDecorator, cpos ChunkPos, changed map[ChunkPos]bool) {
+	c := w.Chunk(cpos)
+	if c == nil || c.Decorated() {
+		return
+	}
+
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dz := int64(-1); dz <= 1; dz++ {
+			if w.Chunk(ChunkPos{X: cpos.X + dx, Z: cpos.Z + dz}) == nil {
+				return
+			}
+		}
+	}
+
+	d.Decorate(c, &featureWriter{w: w, changed: changed})
+	c.SetDecorated(true)
+	changed[cpos] = true
+}
+
+func (w *World) addPendingWrite(bw BlockWrite) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cpos := bw.Pos.ChunkPos()
+	w.pending[cpos] = append(w.pending[cpos], bw)
+}
+
+// applyPendingWrites 将暂存的方块写入刚加入世界的区块, 返回是否有方块变化
+func (w *World) applyPendingWrites(c *Chunk) bool {
+	w.mu.Lock()
+	writes := w.pending[c.pos]
+	delete(w.pending, c.pos)
+	w.mu.Unlock()
+
+	var changed bool
+	for _, bw := range writes {
+		if c.writeIfAir(c.ConvertChunkPos(bw.Pos), bw.Id) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// PendingWrites 返回所有暂存的方块写入, 用于保存
+func (w *World) PendingWrites() []BlockWrite {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	writes := make([]BlockWrite, 0)
+	for _, items := range w.pending {
+		writes = append(writes, items...)
+	}
+	return writes
+}
+
+// AddPendingWrites 恢复保存的暂存写入, 目标区块已加载时立即写入
+func (w *World) AddPendingWrites(writes []BlockWrite) []ChunkPos {
+	changed := make(map[ChunkPos]bool)
+	for _, bw := range writes {
+		if c := w.ChunkAt(bw.Pos); c != nil {
+			if c.writeIfAir(c.ConvertChunkPos(bw.Pos), bw.Id) {
+				changed[c.pos] = true
+			}
+			continue
+		}
+		w.addPendingWrite(bw)
+	}
+
+	return chunkPosList(changed)
+}
+
+func chunkPosList(m map[ChunkPos]bool) []ChunkPos {
+	list := make([]ChunkPos, 0, len(m))
+	for cpos := range m {
+		list = append(list, cpos)
+	}
+	return list
+}
+
+// LoadPendingWrites 读取暂存的方块写入, 文件损坏时隔离后返回空
+func (sm *fileSaveManager) LoadPendingWrites() []BlockWrite {
+	path := filepath.Join(sm.baseDir, PENDING_FILE)
+	bs, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		sm.log.Error("read pending writes:%s fail: %v", path, err)
+		return nil
+	}
+
+	var writes []BlockWrite
+	if err := msgpack.Unmarshal(bs, &writes); err != nil {
+		sm.quarantineFile("pending writes", path, err)
+		return nil
+	}
+	return writes
+}
+
+// SavePendingWrites 保存暂存的方块写入, 没有暂存写入时删除文件
+func (sm *fileSaveManager) SavePendingWrites(writes []BlockWrite) error {
+	path := filepath.Join(sm.baseDir, PENDING_FILE)
+	if len(writes) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	bs, err := msgpack.Marshal(writes)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, bs, 0666)
+}
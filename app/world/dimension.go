@@ -0,0 +1,38 @@
+package world
+
+import "fmt"
+
+const SECTION_SIZE int64 = 16
+const SECTION_VOLUME = int(SECTION_SIZE * SECTION_SIZE * SECTION_SIZE)
+
+// Dimension 世界的垂直范围, MinY 与 Height 必须是 SECTION_SIZE 的整数倍
+type Dimension struct {
+	MinY   int64 `json:"min_y"`
+	Height int64 `json:"height"`
+}
+
+var DefaultDimension = Dimension{MinY: -32, Height: 160}
+
+func (d Dimension) MaxY() int64 {
+	return d.MinY + d.Height
+}
+
+func (d Dimension) SectionCount() int {
+	return int(d.Height / SECTION_SIZE)
+}
+
+// InRange y 是否在世界高度范围内
+func (d Dimension) InRange(y int64) bool {
+	return y >= d.MinY && y < d.MaxY()
+}
+
+func (d Dimension) Validate() error {
+	if d.Height <= 0 {
+		return fmt.Errorf("world height %d must be positive", d.Height)
+	}
+	if d.MinY%SECTION_SIZE != 0 || d.Height%SECTION_SIZE != 0 {
+		return fmt.Errorf("world min y %d and height %d must be multiples of %d", d.MinY, d.Height, SECTION_SIZE)
+	}
+
+	return nil
+}
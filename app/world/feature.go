@@ -0,0 +1,139 @@
+package world
+
+import "math/rand"
+
+// IFeatureWorld 装饰物读写方块的接口, 坐标为世界坐标.
+// SetBlock 只在目标位置为空气时生效, 目标区块未加载时写入会被暂存
+type IFeatureWorld interface {
+	GetBlock(pos Pos) (id BlockId, chunkLoaded bool)
+	SetBlock(pos Pos, id BlockId)
+}
+
+// IFeature 树木、花草等装饰物, 可以跨越区块边界
+type IFeature interface {
+	// Place 在 ground 方块上方放置装饰物, 条件不满足时返回 false
+	Place(w IFeatureWorld, r *rand.Rand, ground Pos) bool
+}
+
+// Decoration 群系中的一种装饰物
+type Decoration struct {
+	Feature IFeature
+	// Count 群系完全覆盖一个区块时平均放置的数量
+	Count float64
+}
+
+// canPlaceOn ground 处已加载且方块在 allowed 中
+func canPlaceOn(w IFeatureWorld, ground Pos, allowed []BlockId) bool {
+	id, loaded := w.GetBlock(ground)
+	if !loaded {
+		return false
+	}
+
+	for _, item := range allowed {
+		if item == id {
+			return true
+		}
+	}
+	return false
+}
+
+// isAir 位置已加载且为空气
+func isAir(w IFeatureWorld, pos Pos) bool {
+	id, loaded := w.GetBlock(pos)
+	return loaded && id == BlockAir
+}
+
+// TreeFeature 树干与一团树叶组成的树
+type TreeFeature struct {
+	Log       BlockId
+	Leaves    BlockId
+	MinHeight int64
+	MaxHeight int64
+	Ground    []BlockId
+}
+
+func (f *TreeFeature) Place(w IFeatureWorld, r *rand.Rand, ground Pos) bool {
+	height := f.MinHeight + r.Int63n(f.MaxHeight-f.MinHeight+1)
+	if !canPlaceOn(w, ground, f.Ground) {
+		return false
+	}
+	for y := int64(1); y <= height+1; y++ {
+		if !isAir(w, ground.AddY(y)) {
+			return false
+		}
+	}
+
+	// 先放树干, 树叶只填充空气
+	for y := int64(1); y <= height; y++ {
+		w.SetBlock(ground.AddY(y), f.Log)
+	}
+
+	// 树叶: 树干顶部以下两层半径 2, 以上两层半径 1, 角上的树叶随机缺失
+	top := ground.AddY(height)
+	for dy := int64(-2); dy <= 1; dy++ {
+		radius := int64(2)
+		if dy > -1 {
+			radius = 1
+		}
+		for dx := -radius; dx <= radius; dx++ {
+			for dz := -radius; dz <= radius; dz++ {
+				corner := (dx == -radius || dx == radius) && (dz == -radius || dz == radius)
+				if corner && (dy == 1 || r.Intn(2) == 0) {
+					continue
+				}
+				w.SetBlock(top.Add(NewPos(dx, dy, dz)), f.Leaves)
+			}
+		}
+	}
+
+	return true
+}
+
+// PlantFeature 单个方块的植物, 例如草和花
+type PlantFeature struct {
+	Blocks []BlockId // 随机选择其中一种
+	Ground []BlockId
+}
+
+func (f *PlantFeature) Place(w IFeatureWorld, r *rand.Rand, ground Pos) bool {
+	id := f.Blocks[r.Intn(len(f.Blocks))]
+	if !canPlaceOn(w, ground, f.Ground) || !isAir(w, ground.AddY(1)) {
+		return false
+	}
+
+	w.SetBlock(ground.AddY(1), id)
+	return true
+}
+
+// ColumnFeature 竖直堆叠的同一种方块, 例如仙人掌, 四周需要留空
+type ColumnFeature struct {
+	Block     BlockId
+	MinHeight int64
+	MaxHeight int64
+	Ground    []BlockId
+}
+
+func (f *ColumnFeature) Place(w IFeatureWorld, r *rand.Rand, ground Pos) bool {
+	height := f.MinHeight + r.Int63n(f.MaxHeight-f.MinHeight+1)
+	if !canPlaceOn(w, ground, f.Ground) {
+		return false
+	}
+
+	for y := int64(1); y <= height; y++ {
+		pos := ground.AddY(y)
+		free := isAir(w, pos)
+		pos.RangeAdjoin(func(p Pos, face BlockFace) {
+			if face != BlockFaceTop && face != BlockFaceBottom && !isAir(w, p) {
+				free = false
+			}
+		})
+		if !free {
+			return false
+		}
+	}
+
+	for y := int64(1); y <= height; y++ {
+		w.SetBlock(ground.AddY(y), f.Block)
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DEFAULT_SUPERFLAT_LAYERS 默认的超平坦地层
+const DEFAULT_SUPERFLAT_LAYERS = "stone,3*soil,grass"
+
+// VOID_PLATFORM_RADIUS 虚空世界出生点平台的半径
+const VOID_PLATFORM_RADIUS int64 = 2
+
+// SuperflatOptions 超平坦生成器的参数, 缺少的字段保持默认值
+type SuperflatOptions struct {
+	// Layers 从下到上的地层, 以逗号分隔, 每层为 "数量*方块" 或 "方块", 方块为名称或数字 id
+	Layers string `json:"layers"`
+	// Biome 群系名称
+	Biome string `json:"biome"`
+	// BaseY 最下层的高度
+	BaseY int64 `json:"base_y"`
+}
+
+var DefaultSuperflatOptions = SuperflatOptions{
+	Layers: DEFAULT_SUPERFLAT_LAYERS,
+	Biome:  "plains",
+	BaseY:  DefaultDimension.MinY,
+}
+
+// SuperflatGenerator 所有列都相同的平坦世界, 没有洞穴、矿物和装饰物
+type SuperflatGenerator struct {
+	base   int64
+	layers []BlockId
+	biome  BiomeId
+}
+
+func NewSuperflatGenerator(opts SuperflatOptions) (*SuperflatGenerator, error) {
+	layers, err := ParseSuperflatLayers(opts.Layers)
+	if err != nil {
+		return nil, err
+	}
+
+	b := GetBiomeByName(opts.Biome)
+	if b == nil {
+		return nil, fmt.Errorf("unknown biome %q", opts.Biome)
+	}
+
+	return &SuperflatGenerator{base: opts.BaseY, layers: layers, biome: b.Id}, nil
+}
+
+func newSuperflatGenerator(options json.RawMessage) (IWorldGenerator, error) {
+	opts := DefaultSuperflatOptions
+	if len(options) != 0 {
+		if err := json.Unmarshal(options, &opts); err != nil {
+			return nil, fmt.Errorf("parse superflat options: %v", err)
+		}
+	}
+	return NewSuperflatGenerator(opts)
+}
+
+// ParseSuperflatLayers 解析地层字符串, 例如 "stone,3*soil,grass"
+func ParseSuperflatLayers(text string) ([]BlockId, error) {
+	layers := make([]BlockId, 0)
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		count := int64(1)
+		name := item
+		if i := strings.Index(item, "*"); i >= 0 {
+			n, err := strconv.ParseInt(strings.TrimSpace(item[:i]), 10, 64)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid layer count %q", item)
+			}
+			count, name = n, item[i+1:]
+		}
+
+		id, ok := ParseBlockId(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown layer block %q", name)
+		}
+		// 超出世界高度的地层没有意义, 也避免巨大的数量占用内存
+		if int64(len(layers))+count > DefaultDimension.Height {
+			return nil, fmt.Errorf("layers %q higher than world height %d", text, DefaultDimension.Height)
+		}
+		for i := int64(0); i < count; i++ {
+			layers = append(layers, id)
+		}
+	}
+
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("no layers in %q", text)
+	}
+	return layers, nil
+}
+
+func (g *SuperflatGenerator) Setup(seed int64) {
+}
+
+func (g *SuperflatGenerator) GetBlock(x, y, z float64) BlockId {
+	i := int64(math.Floor(y)) - g.base
+	if i < 0 || i >= int64(len(g.layers)) {
+		return BlockAir
+	}
+	return g.layers[i]
+}
+
+func (g *SuperflatGenerator) GenerateColumn(x, z, minY int64, blocks []BlockId) BiomeId {
+	for i := range blocks {
+		blocks[i] = g.GetBlock(float64(x), float64(minY+int64(i)), float64(z))
+	}
+	return g.biome
+}
+
+func (g *SuperflatGenerator) GetBiome(x, z float64) BiomeId {
+	return g.biome
+}
+
+// VoidOptions 虚空生成器的参数
+type VoidOptions struct {
+	// Platform 在出生点下方放置一小块石头平台
+	Platform bool `json:"platform"`
+}
+
+// VoidGenerator 只有空气的世界
+type VoidGenerator struct {
+	Platform bool
+}
+
+func newVoidGenerator(options json.RawMessage) (IWorldGenerator, error) {
+	opts := VoidOptions{Platform: true}
+	if len(options) != 0 {
+		if err := json.Unmarshal(options, &opts); err != nil {
+			return nil, fmt.Errorf("parse void options: %v", err)
+		}
+	}
+	return &VoidGenerator{Platform: opts.Platform}, nil
+}
+
+func (g *VoidGenerator) Setup(seed int64) {
+}
+
+func (g *VoidGenerator) GetBlock(x, y, z float64) BlockId {
+	if !g.Platform || int64(math.Floor(y)) != int64(math.Floor(float64(DefaultSpawn.Y)))-2 {
+		return BlockAir
+	}
+
+	dx := int64(math.Floor(x - float64(DefaultSpawn.X)))
+	dz := int64(math.Floor(z - float64(DefaultSpawn.Z)))
+	if dx < -VOID_PLATFORM_RADIUS || dx > VOID_PLATFORM_RADIUS || dz < -VOID_PLATFORM_RADIUS || dz > VOID_PLATFORM_RADIUS {
+		return BlockAir
+	}
+	return BlockStone
+}
+
+func (g *VoidGenerator) GenerateColumn(x, z, minY int64, blocks []BlockId) BiomeId {
+	for i := range blocks {
+		blocks[i] = g.GetBlock(float64(x), float64(minY+int64(i)), float64(z))
+	}
+	return BiomeNone
+}
+
+func (g *VoidGenerator) GetBiome(x, z float64) BiomeId {
+	return BiomeNone
+}
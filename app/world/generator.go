@@ -0,0 +1,371 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/weiWang95/mcworld/lib/perlin"
+)
+
+// SEA_LEVEL 默认的海平面, 低于海平面的地表上方填充水
+const SEA_LEVEL int64 = 12
+
+// SNOW_HEIGHT 默认的积雪高度, 地表高于此高度时覆盖积雪
+const SNOW_HEIGHT int64 = 64
+
+// CAVE_MIN_Y 洞穴最低高度, 保留世界底部的实心层
+const CAVE_MIN_Y int64 = -28
+
+// 默认的噪声参数, 见 GeneratorOptions
+const (
+	CLIMATE_SCALE = 0.003 // 温度湿度噪声的频率, 越小群系越大
+	HEIGHT_SCALE  = 0.015
+	RIDGE_SCALE   = 0.008
+
+	OVERHANG_SCALE   = 0.05
+	OVERHANG_STRETCH = 2.5
+
+	CHEESE_SCALE     = 0.025 // 大型空洞
+	CHEESE_THRESHOLD = 0.32
+	TUNNEL_SCALE     = 0.02 // 细长隧道, 两个噪声同时接近 0 的位置
+	TUNNEL_WIDTH     = 0.035
+	// CAVE_SURFACE_DEPTH 地表以下这些格内不生成大型空洞, 海底以下同样不生成隧道, 避免地表和海底大面积塌陷
+	CAVE_SURFACE_DEPTH int64 = 6
+)
+
+// noiseYOffset perlin.Noise3D 第三个参数为负时会退化为二维噪声, 高度放在第三维并加上偏移保证非负
+const noiseYOffset = 1000.0
+
+// IWorldGenerator 世界生成器, 以列为单位填充地形, 每列相同的二维噪声等数据只需计算一次.
+// 只能逐个方块查询的生成器可以通过 BlockGeneratorAdapter 使用
+type IWorldGenerator interface {
+	Setup(seed int64)
+	// GenerateColumn 填充世界坐标 x z 处的一段列, blocks[i] 为高度 minY+i 处的方块, 返回该列的群系
+	GenerateColumn(x, z, minY int64, blocks []BlockId) BiomeId
+	// GetBiome 返回 x z 所在列的群系
+	GetBiome(x, z float64) BiomeId
+}
+
+// IBlockGenerator 逐个方块查询的生成器
+type IBlockGenerator interface {
+	Setup(seed int64)
+	GetBlock(x, y, z float64) BlockId
+	GetBiome(x, z float64) BiomeId
+}
+
+// BlockGeneratorAdapter 将 IBlockGenerator 适配为 IWorldGenerator, 列中每个方块单独查询
+type BlockGeneratorAdapter struct {
+	IBlockGenerator
+}
+
+func (a BlockGeneratorAdapter) GenerateColumn(x, z, minY int64, blocks []BlockId) BiomeId {
+	for i := range blocks {
+		blocks[i] = a.GetBlock(float64(x), float64(minY+int64(i)), float64(z))
+	}
+	return a.GetBiome(float64(x), float64(z))
+}
+
+// GeneratorBlock 查询生成器在 x y z 处生成的方块, 生成器实现 IBlockGenerator 时直接查询, 否则生成长度为 1 的列
+func GeneratorBlock(wg IWorldGenerator, x, y, z float64) BlockId {
+	if bg, ok := wg.(IBlockGenerator); ok {
+		return bg.GetBlock(x, y, z)
+	}
+
+	var blocks [1]BlockId
+	wg.GenerateColumn(int64(math.Floor(x)), int64(math.Floor(z)), int64(math.Floor(y)), blocks[:])
+	return blocks[0]
+}
+
+// IChunkPopulator 生成器可选实现, 在区块地形填充完成后对整个区块做进一步处理, 例如放置矿物
+type IChunkPopulator interface {
+	Populate(c *Chunk)
+}
+
+// GeneratorOptions 默认生成器的参数, 即 LevelData.GeneratorOptions 的格式.
+// 解析时以 DefaultGeneratorOptions 为基础, 缺少的字段保持默认值
+type GeneratorOptions struct {
+	Caves     bool `json:"caves"`
+	Overhangs bool `json:"overhangs"`
+
+	SeaLevel   int64 `json:"sea_level"`
+	SnowHeight int64 `json:"snow_height"`
+	// HeightFactor 群系地形起伏的倍数
+	HeightFactor float64 `json:"height_factor"`
+
+	// 各噪声的频率, 越小变化越平缓
+	ClimateScale    float64 `json:"climate_scale"`
+	HeightScale     float64 `json:"height_scale"`
+	RidgeScale      float64 `json:"ridge_scale"`
+	OverhangScale   float64 `json:"overhang_scale"`
+	OverhangStretch float64 `json:"overhang_stretch"`
+	CheeseScale     float64 `json:"cheese_scale"`
+	CheeseThreshold float64 `json:"cheese_threshold"`
+	TunnelScale     float64 `json:"tunnel_scale"`
+	TunnelWidth     float64 `json:"tunnel_width"`
+}
+
+var DefaultGeneratorOptions = GeneratorOptions{
+	Caves:     true,
+	Overhangs: true,
+
+	SeaLevel:     SEA_LEVEL,
+	SnowHeight:   SNOW_HEIGHT,
+	HeightFactor: 1,
+
+	ClimateScale:    CLIMATE_SCALE,
+	HeightScale:     HEIGHT_SCALE,
+	RidgeScale:      RIDGE_SCALE,
+	OverhangScale:   OVERHANG_SCALE,
+	OverhangStretch: OVERHANG_STRETCH,
+	CheeseScale:     CHEESE_SCALE,
+	CheeseThreshold: CHEESE_THRESHOLD,
+	TunnelScale:     TUNNEL_SCALE,
+	TunnelWidth:     TUNNEL_WIDTH,
+}
+
+// ParseGeneratorOptions 解析默认生成器的参数, data 为空时返回默认参数
+func ParseGeneratorOptions(data json.RawMessage) (GeneratorOptions, error) {
+	opts := DefaultGeneratorOptions
+	if len(data) != 0 {
+		if err := json.Unmarshal(data, &opts); err != nil {
+			return opts, fmt.Errorf("parse generator options: %v", err)
+		}
+	}
+
+	return opts, opts.Validate()
+}
+
+func (o GeneratorOptions) Validate() error {
+	scales := []float64{o.ClimateScale, o.HeightScale, o.RidgeScale, o.OverhangScale, o.CheeseScale, o.TunnelScale}
+	for _, v := range scales {
+		if v <= 0 {
+			return fmt.Errorf("generator noise scale %v must be positive", v)
+		}
+	}
+	if o.OverhangStretch <= 0 || o.HeightFactor < 0 || o.TunnelWidth < 0 {
+		return fmt.Errorf("invalid generator options overhang_stretch %v height_factor %v tunnel_width %v",
+			o.OverhangStretch, o.HeightFactor, o.TunnelWidth)
+	}
+
+	return nil
+}
+
+// WorldGenerator 分层地形生成器, 由温度与湿度噪声选择群系,
+// 每个群系决定地形高度和各层方块, 群系边界处高度按权重平滑过渡.
+// 地表附近由三维密度噪声产生悬崖与拱桥, 地下由三维噪声挖出洞穴.
+// 所有噪声只与种子和世界坐标有关, 区块边界处连续. Setup 之后只读, 可以被多个协程同时调用
+type WorldGenerator struct {
+	// Options 为空时使用 DefaultGeneratorOptions
+	Options *GeneratorOptions
+	// Ores 为空时使用 DefaultOres
+	Ores []OreConfig
+
+	seed int64
+	opts GeneratorOptions
+	ores []OreConfig
+
+	height      *perlin.Perlin
+	ridge       *perlin.Perlin
+	temperature *perlin.Perlin
+	humidity    *perlin.Perlin
+	overhang    *perlin.Perlin
+	cheese      *perlin.Perlin
+	tunnelA     *perlin.Perlin
+	tunnelB     *perlin.Perlin
+}
+
+func (wg *WorldGenerator) Setup(seed int64) {
+	wg.seed = seed
+	wg.opts = DefaultGeneratorOptions
+	if wg.Options != nil {
+		wg.opts = *wg.Options
+	}
+
+	ores := wg.Ores
+	if ores == nil {
+		ores = DefaultOres
+	}
+	wg.ores = make([]OreConfig, 0, len(ores))
+	for _, ore := range ores {
+		// 无效的配置已由 LoadOres 报告
+		if err := ore.init(); err == nil {
+			wg.ores = append(wg.ores, ore)
+		}
+	}
+
+	wg.height = perlin.NewPerlin(2, 2, 5, seed)
+	wg.ridge = perlin.NewPerlin(2, 2, 4, seed+1)
+	wg.temperature = perlin.NewPerlin(2, 2, 3, seed+2)
+	wg.humidity = perlin.NewPerlin(2, 2, 3, seed+3)
+	wg.overhang = perlin.NewPerlin(2, 2, 3, seed+4)
+	wg.cheese = perlin.NewPerlin(2, 2, 2, seed+5)
+	wg.tunnelA = perlin.NewPerlin(2, 2, 2, seed+6)
+	wg.tunnelB = perlin.NewPerlin(2, 2, 2, seed+7)
+}
+
+// column 一列地形的高度与群系
+type column struct {
+	x, z     float64
+	height   int64
+	biome    *Biome
+	overhang float64 // 混合后的地表扰动格数
+}
+
+// Climate 返回 x z 处的温度与湿度, 取值约为 [-1, 1]
+func (wg *WorldGenerator) Climate(x, z float64) (temperature, humidity float64) {
+	temperature = wg.temperature.Noise2D(wg.opts.ClimateScale*x, wg.opts.ClimateScale*z) * 2
+	humidity = wg.humidity.Noise2D(wg.opts.ClimateScale*x, wg.opts.ClimateScale*z) * 2
+	return temperature, humidity
+}
+
+func (wg *WorldGenerator) column(x, z float64) column {
+	weights := BlendBiomes(wg.Climate(x, z))
+
+	rolling := wg.height.Noise2D(wg.opts.HeightScale*x, wg.opts.HeightScale*z)
+	ridged := 1 - math.Abs(wg.ridge.Noise2D(wg.opts.RidgeScale*x, wg.opts.RidgeScale*z))*2
+
+	var h, overhang float64
+	for _, w := range weights {
+		n := rolling
+		if w.Biome.Ridged {
+			n = ridged
+		}
+		h += w.Weight * (w.Biome.BaseHeight + wg.opts.HeightFactor*w.Biome.HeightVariation*n)
+		overhang += w.Weight * w.Biome.Overhang
+	}
+	if !wg.opts.Overhangs {
+		overhang = 0
+	}
+
+	return column{x: x, z: z, height: int64(math.Floor(h)), biome: weights[0].Biome, overhang: overhang}
+}
+
+func (wg *WorldGenerator) GetBiome(x, z float64) BiomeId {
+	return wg.column(x, z).biome.Id
+}
+
+func (wg *WorldGenerator) GetBlock(x, y, z float64) BlockId {
+	col := wg.column(x, z)
+	return wg.block(col, int64(math.Floor(y)))
+}
+
+// GenerateColumn 实现 IWorldGenerator, 列的高度与群系只计算一次,
+// 自上而下计算时记录上方连续的实心方块, 每个高度的密度只计算一次
+func (wg *WorldGenerator) GenerateColumn(x, z, minY int64, blocks []BlockId) BiomeId {
+	col := wg.column(float64(x), float64(z))
+	maxDepth := col.biome.SubsurfaceDepth + 1
+
+	// 列顶部之上连续的实心方块
+	top := minY + int64(len(blocks))
+	depth := int64(0)
+	for depth < maxDepth && wg.solid(col, top+depth) {
+		depth++
+	}
+
+	for i := len(blocks) - 1; i >= 0; i-- {
+		y := minY + int64(i)
+		if !wg.solid(col, y) {
+			blocks[i] = wg.fluid(y)
+			depth = 0
+			continue
+		}
+
+		blocks[i] = wg.solidBlock(col, y, depth)
+		if depth < maxDepth {
+			depth++
+		}
+	}
+
+	return col.biome.Id
+}
+
+// block 返回列中高度 y 处的方块
+func (wg *WorldGenerator) block(col column, y int64) BlockId {
+	if !wg.solid(col, y) {
+		return wg.fluid(y)
+	}
+
+	// 上方连续实心方块的数量, 决定处于哪一层
+	depth := int64(0)
+	for depth <= col.biome.SubsurfaceDepth && wg.solid(col, y+depth+1) {
+		depth++
+	}
+	return wg.solidBlock(col, y, depth)
+}
+
+// fluid 非实心位置的方块, 海平面以下为水
+func (wg *WorldGenerator) fluid(y int64) BlockId {
+	if y <= wg.opts.SeaLevel {
+		return BlockWater
+	}
+	return BlockAir
+}
+
+// solidBlock 实心位置的方块, depth 为上方连续实心方块的数量, 最多计到 SubsurfaceDepth+1
+func (wg *WorldGenerator) solidBlock(col column, y, depth int64) BlockId {
+	if wg.cave(col, y) {
+		return BlockAir
+	}
+
+	b := col.biome
+	switch {
+	case depth == 0:
+		if y > wg.opts.SnowHeight {
+			return BlockSnow
+		}
+		// 水下不长草
+		if y < wg.opts.SeaLevel && b.Surface == BlockGrass {
+			return b.Subsurface
+		}
+		return b.Surface
+	case depth <= b.SubsurfaceDepth:
+		return b.Subsurface
+	default:
+		return b.Filler
+	}
+}
+
+// solid 地形密度是否为正, 不考虑洞穴.
+// 密度为到高度图地表的距离加上三维噪声扰动, 只在地表上下 overhang 格内计算噪声
+func (wg *WorldGenerator) solid(col column, y int64) bool {
+	d := float64(col.height - y)
+	if col.overhang <= 0 || math.Abs(d) > col.overhang {
+		return d >= 0
+	}
+
+	// 噪声值约为 [-0.5, 0.5], 放大到 [-1, 1]; 高度方向拉伸使噪声沿 y 变化更快, 才能形成悬空
+	n := 2 * wg.noise3(wg.overhang, wg.opts.OverhangScale, col.x, float64(y)*wg.opts.OverhangStretch, col.z)
+	return d+col.overhang*n >= 0
+}
+
+// cave 实心位置是否被洞穴挖空
+func (wg *WorldGenerator) cave(col column, y int64) bool {
+	if !wg.opts.Caves || y < CAVE_MIN_Y {
+		return false
+	}
+
+	// 海底以下保留一层实心, 不让海水所在的位置与洞穴相通
+	depth := col.height - y
+	if col.height < wg.opts.SeaLevel && depth < CAVE_SURFACE_DEPTH {
+		return false
+	}
+
+	if depth >= CAVE_SURFACE_DEPTH {
+		// 高度方向压缩, 空洞更扁平
+		if wg.noise3(wg.cheese, wg.opts.CheeseScale, col.x, float64(y)*2, col.z) > wg.opts.CheeseThreshold {
+			return true
+		}
+	}
+
+	a := wg.noise3(wg.tunnelA, wg.opts.TunnelScale, col.x, float64(y)*1.5, col.z)
+	if math.Abs(a) > wg.opts.TunnelWidth {
+		return false
+	}
+	b := wg.noise3(wg.tunnelB, wg.opts.TunnelScale, col.x, float64(y)*1.5, col.z)
+	return math.Abs(b) <= wg.opts.TunnelWidth
+}
+
+func (wg *WorldGenerator) noise3(p *perlin.Perlin, scale, x, y, z float64) float64 {
+	return p.Noise3D(scale*x, scale*z, scale*y+noiseYOffset)
+}
@@ -0,0 +1,131 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	SUPERFLAT_GENERATOR = "superflat"
+	VOID_GENERATOR      = "void"
+)
+
+// GeneratorFactory 由 LevelData.GeneratorOptions 创建生成器, options 可以为空.
+// 返回的生成器还需要调用 Setup
+type GeneratorFactory func(options json.RawMessage) (IWorldGenerator, error)
+
+var generators = map[string]GeneratorFactory{}
+
+func init() {
+	RegisterGenerator(DEFAULT_GENERATOR, newDefaultGenerator)
+	RegisterGenerator(SUPERFLAT_GENERATOR, newSuperflatGenerator)
+	RegisterGenerator(VOID_GENERATOR, newVoidGenerator)
+}
+
+// RegisterGenerator 注册名称为 name 的生成器, 名称重复时 panic
+func RegisterGenerator(name string, factory GeneratorFactory) {
+	if _, ok := generators[name]; ok {
+		panic(fmt.Sprintf("generator %s already registered", name))
+	}
+	generators[name] = factory
+}
+
+// GeneratorNames 返回所有已注册的生成器名称
+func GeneratorNames() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewGenerator 创建名称为 name 的生成器, 名称为空时使用 DEFAULT_GENERATOR
+func NewGenerator(name string, options json.RawMessage) (IWorldGenerator, error) {
+	if name == "" {
+		name = DEFAULT_GENERATOR
+	}
+
+	factory, ok := generators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown generator %q", name)
+	}
+
+	wg, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("generator %s: %v", name, err)
+	}
+	return wg, nil
+}
+
+func newDefaultGenerator(options json.RawMessage) (IWorldGenerator, error) {
+	opts, err := ParseGeneratorOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return &WorldGenerator{Options: &opts}, nil
+}
+
+// PRESET_DIR 生成器预设目录, 位于数据目录的 config 下
+const PRESET_DIR = "presets"
+
+// GeneratorPreset 创建世界时可选的生成器与参数, 每个预设为预设目录中的一个 json 文件
+type GeneratorPreset struct {
+	Name      string          `json:"name"`
+	Generator string          `json:"generator"`
+	Options   json.RawMessage `json:"options,omitempty"`
+}
+
+// LoadGeneratorPresets 读取目录 dir 中的所有预设, 按名称排序, 名称为 DEFAULT_GENERATOR 的预设排在最前
+func LoadGeneratorPresets(dir string) ([]GeneratorPreset, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	presets := make([]GeneratorPreset, 0, len(files))
+	for _, file := range files {
+		p, err := LoadGeneratorPreset(file)
+		if err != nil {
+			return nil, err
+		}
+		presets = append(presets, p)
+	}
+
+	sort.SliceStable(presets, func(i, j int) bool {
+		a, b := presets[i], presets[j]
+		if (a.Name == DEFAULT_GENERATOR) != (b.Name == DEFAULT_GENERATOR) {
+			return a.Name == DEFAULT_GENERATOR
+		}
+		return a.Name < b.Name
+	})
+	return presets, nil
+}
+
+// LoadGeneratorPreset 读取并检查一个预设, 没有名称时使用文件名
+func LoadGeneratorPreset(path string) (GeneratorPreset, error) {
+	var p GeneratorPreset
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(bs, &p); err != nil {
+		return p, fmt.Errorf("parse %s: %v", path, err)
+	}
+
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if p.Generator == "" {
+		p.Generator = DEFAULT_GENERATOR
+	}
+	if _, err := NewGenerator(p.Generator, p.Options); err != nil {
+		return p, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,63 @@
+package world
+
+import "testing"
+
+func newTestGenerator() *WorldGenerator {
+	wg := new(WorldGenerator)
+	wg.Setup(42)
+	return wg
+}
+
+func TestGenerateColumnMatchesGetBlock(t *testing.T) {
+	wg := newTestGenerator()
+	dim := DefaultDimension
+	blocks := make([]BlockId, dim.Height)
+
+	for _, p := range [][2]int64{{0, 0}, {7, -3}, {-120, 45}, {1000, 999}} {
+		x, z := p[0], p[1]
+		if biome := wg.GenerateColumn(x, z, dim.MinY, blocks); biome != wg.GetBiome(float64(x), float64(z)) {
+			t.Fatalf("column %d,%d biome %v, want %v", x, z, biome, wg.GetBiome(float64(x), float64(z)))
+		}
+		for i, id := range blocks {
+			y := dim.MinY + int64(i)
+			if want := wg.GetBlock(float64(x), float64(y), float64(z)); id != want {
+				t.Fatalf("block %d,%d,%d: column %v, per block %v", x, y, z, id, want)
+			}
+		}
+	}
+}
+
+// BenchmarkGenerateColumn 按列生成一个区块的地形
+func BenchmarkGenerateColumn(b *testing.B) {
+	wg := newTestGenerator()
+	dim := DefaultDimension
+	blocks := make([]BlockId, dim.Height)
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		cx := int64(n) * CHUNK_WIDTH
+		for x := int64(0); x < CHUNK_WIDTH; x++ {
+			for z := int64(0); z < CHUNK_WIDTH; z++ {
+				wg.GenerateColumn(cx+x, z, dim.MinY, blocks)
+			}
+		}
+	}
+}
+
+// BenchmarkGetBlockPerBlock 逐个方块查询生成同样大小的区块
+func BenchmarkGetBlockPerBlock(b *testing.B) {
+	wg := newTestGenerator()
+	dim := DefaultDimension
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		cx := int64(n) * CHUNK_WIDTH
+		for x := int64(0); x < CHUNK_WIDTH; x++ {
+			for z := int64(0); z < CHUNK_WIDTH; z++ {
+				for y := dim.MinY; y < dim.MaxY(); y++ {
+					wg.GetBlock(float64(cx+x), float64(y), float64(z))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package world
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LEVEL_FILE = "level.json"
+
+	DEFAULT_GENERATOR  = "default"
+	DEFAULT_WORLD_NAME = "New World"
+)
+
+// DefaultSpawn 新世界的出生点
+var DefaultSpawn = Vec3{X: 0, Y: 50, Z: 0}
+
+// Vec3 浮点坐标, 用于保存玩家等不在方块格点上的位置
+type Vec3 struct {
+	X, Y, Z float32
+}
+
+// PlayerData 玩家存档数据
+type PlayerData struct {
+	Position Vec3
+	Mode     uint8
+	// Yaw、Pitch 视线方向, 弧度
+	Yaw   float32
+	Pitch float32
+	// Slot 当前选中的快捷栏格子
+	Slot uint8
+	// Inventory 背包中的物品, 为 nil 表示旧存档没有保存背包
+	Inventory []ItemStack
+}
+
+// ItemStack 背包中一个格子的物品
+type ItemStack struct {
+	Slot  int // 0-9 为快捷栏, 之后为背包
+	Id    BlockId
+	Count uint8
+}
+
+// LevelData 世界元数据, 保存在存档根目录的 level.json 中
+type LevelData struct {
+	Version   int // 存档目录版本, 与 WORLD_FORMAT_VERSION 一致
+	Name      string
+	Seed      int64
+	Generator string
+	// GeneratorOptions 生成器参数, 格式由生成器决定
+	GeneratorOptions json.RawMessage `json:",omitempty"`
+	Time             int64           // 当天的时间, 见 World.CurTime
+	Spawn            Vec3
+	Player           *PlayerData `json:",omitempty"` // 没有进入过世界时为空
+
+	CreatedAt  time.Time
+	LastPlayed time.Time
+}
+
+// NewLevelData 创建新世界的元数据
+func NewLevelData(name string, seed int64) *LevelData {
+	now := time.Now()
+	return &LevelData{
+		Version:    WORLD_FORMAT_VERSION,
+		Name:       name,
+		Seed:       seed,
+		Generator:  DEFAULT_GENERATOR,
+		Spawn:      DefaultSpawn,
+		CreatedAt:  now,
+		LastPlayed: now,
+	}
+}
+
+// ReadLevel 读取存档目录 dir 中的世界元数据, 文件不存在时返回的错误满足 os.IsNotExist
+func ReadLevel(dir string) (*LevelData, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, LEVEL_FILE))
+	if err != nil {
+		return nil, err
+	}
+
+	var level LevelData
+	if err := json.Unmarshal(data, &level); err != nil {
+		return nil, err
+	}
+
+	return &level, nil
+}
+
+// WriteLevel 将世界元数据原子地写入存档目录 dir, 不修改其中的字段
+func WriteLevel(dir string, level *LevelData) error {
+	data, err := json.MarshalIndent(level, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(dir, LEVEL_FILE), data, 0666)
+}
+
+// LoadLevel 读取世界元数据, 新存档返回 nil, 数据损坏时隔离后返回 nil
+func (sm *fileSaveManager) LoadLevel() *LevelData {
+	level, err := ReadLevel(sm.baseDir)
+	if os.IsNotExist(err) {
+		sm.log.Debug("level file:%s not exist", sm.levelFileName())
+		return nil
+	}
+	if _, ok := err.(*os.PathError); ok {
+		sm.log.Error("read level file:%s fail: %v", sm.levelFileName(), err)
+		return nil
+	}
+	if err != nil {
+		sm.quarantineFile("level", sm.levelFileName(), err)
+		return nil
+	}
+
+	return level
+}
+
+// SaveLevel 保存世界元数据, Version 和 LastPlayed 由存档填写
+func (sm *fileSaveManager) SaveLevel(level *LevelData) error {
+	level.Version = WORLD_FORMAT_VERSION
+	level.LastPlayed = time.Now()
+
+	return WriteLevel(sm.baseDir, level)
+}
+
+func (sm *fileSaveManager) levelFileName() string {
+	return filepath.Join(sm.baseDir, LEVEL_FILE)
+}
+
+// migrateSeedFile 3 -> 4: 纯文本的 seed 文件转换为 level.json
+func (sm *fileSaveManager) migrateSeedFile() error {
+	if sm.LoadLevel() != nil {
+		return nil
+	}
+
+	seedFile := filepath.Join(sm.baseDir, "seed")
+	data, err := ioutil.ReadFile(seedFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	seed, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		sm.quarantineFile("seed", seedFile, err)
+		return nil
+	}
+
+	level := NewLevelData(DEFAULT_WORLD_NAME, seed)
+	if info, err := os.Stat(seedFile); err == nil {
+		level.CreatedAt = info.ModTime()
+	}
+	if err := sm.SaveLevel(level); err != nil {
+		return err
+	}
+
+	return os.Remove(seedFile)
+}
@@ -0,0 +1,108 @@
+package world
+
+// Luminance 一个方块的光照, 阳光与红绿蓝三个通道的方块光各占 4 位
+type Luminance uint16
+
+const (
+	LUM_SUN   uint16 = 0xf000
+	LUM_RED   uint16 = 0x0f00
+	LUM_GREEN uint16 = 0x00f0
+	LUM_BLUE  uint16 = 0x000f
+	LUM_BLOCK        = LUM_RED | LUM_GREEN | LUM_BLUE
+)
+
+const MAX_LUM uint8 = 15
+
+// LightColor 方块光的红绿蓝通道, 每个通道 0~MAX_LUM
+type LightColor struct {
+	R, G, B uint8
+}
+
+// WhiteLight 三个通道相同的白光
+func WhiteLight(lum uint8) LightColor {
+	return LightColor{R: lum, G: lum, B: lum}
+}
+
+// Max 最亮的通道
+func (c LightColor) Max() uint8 {
+	max := c.R
+	if c.G > max {
+		max = c.G
+	}
+	if c.B > max {
+		max = c.B
+	}
+	return max
+}
+
+// NewLuminance 阳光与白色的方块光
+func NewLuminance(sunLum, blockLum uint8) Luminance {
+	return NewColorLuminance(sunLum, WhiteLight(blockLum))
+}
+
+func NewColorLuminance(sunLum uint8, block LightColor) Luminance {
+	return Luminance(0).SetSunLum(sunLum).SetBlockColor(block)
+}
+
+func (l Luminance) SunLum() uint8 {
+	return uint8(l >> 12)
+}
+
+// BlockLum 方块光最亮的通道
+func (l Luminance) BlockLum() uint8 {
+	return l.BlockColor().Max()
+}
+
+func (l Luminance) BlockColor() LightColor {
+	return LightColor{R: uint8(l>>8) & MAX_LUM, G: uint8(l>>4) & MAX_LUM, B: uint8(l) & MAX_LUM}
+}
+
+func (l Luminance) SetSunLum(sunLum uint8) Luminance {
+	return Luminance(uint16(l)&LUM_BLOCK | uint16(sunLum&MAX_LUM)<<12)
+}
+
+// SetBlockLum 设置为白色的方块光
+func (l Luminance) SetBlockLum(blockLum uint8) Luminance {
+	return l.SetBlockColor(WhiteLight(blockLum))
+}
+
+func (l Luminance) SetBlockColor(c LightColor) Luminance {
+	block := uint16(c.R&MAX_LUM)<<8 | uint16(c.G&MAX_LUM)<<4 | uint16(c.B&MAX_LUM)
+	return Luminance(uint16(l)&LUM_SUN | block)
+}
+
+func (l Luminance) Lum() uint8 {
+	sun := l.SunLum()
+	block := l.BlockLum()
+
+	if sun > block {
+		return sun
+	}
+
+	return block
+}
+
+func (l Luminance) CurLum(night bool) uint8 {
+	if night {
+		return l.BlockLum()
+	}
+
+	return l.Lum()
+}
+
+// MaxLum 每个通道分别取较大值
+func MaxLum(l1, l2 Luminance) Luminance {
+	max := func(a, b uint8) uint8 {
+		if a > b {
+			return a
+		}
+		return b
+	}
+
+	c1, c2 := l1.BlockColor(), l2.BlockColor()
+	return NewColorLuminance(max(l1.SunLum(), l2.SunLum()), LightColor{
+		R: max(c1.R, c2.R),
+		G: max(c1.G, c2.G),
+		B: max(c1.B, c2.B),
+	})
+}
@@ -0,0 +1,16 @@
+package world
+
+// ILogger 世界逻辑使用的日志接口, g3n 的 *logger.Logger 满足该接口, 渲染层可以直接传入自己的日志
+type ILogger interface {
+	Debug(format string, v ...interface{})
+	Info(format string, v ...interface{})
+	Warn(format string, v ...interface{})
+	Error(format string, v ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(format string, v ...interface{}) {}
+func (nopLogger) Info(format string, v ...interface{})  {}
+func (nopLogger) Warn(format string, v ...interface{})  {}
+func (nopLogger) Error(format string, v ...interface{}) {}
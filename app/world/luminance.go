@@ -0,0 +1,502 @@
+package world
+
+// lightChannel 光照通道, 阳光与方块光的红绿蓝通道各自单独传播
+type lightChannel int
+
+const (
+	sunChannel lightChannel = iota
+	redChannel
+	greenChannel
+	blueChannel
+	lightChannelCount
+)
+
+func (ch lightChannel) get(l Luminance) uint8 {
+	switch ch {
+	case sunChannel:
+		return l.SunLum()
+	case redChannel:
+		return l.BlockColor().R
+	case greenChannel:
+		return l.BlockColor().G
+	default:
+		return l.BlockColor().B
+	}
+}
+
+func (ch lightChannel) set(l Luminance, v uint8) Luminance {
+	if ch == sunChannel {
+		return l.SetSunLum(v)
+	}
+
+	c := l.BlockColor()
+	switch ch {
+	case redChannel:
+		c.R = v
+	case greenChannel:
+		c.G = v
+	default:
+		c.B = v
+	}
+	return l.SetBlockColor(c)
+}
+
+// color 方块光源在该通道的亮度
+func (ch lightChannel) color(c LightColor) uint8 {
+	switch ch {
+	case redChannel:
+		return c.R
+	case greenChannel:
+		return c.G
+	case blueChannel:
+		return c.B
+	}
+	return 0
+}
+
+// lightNode 移除队列中的位置与它被清除前的光照
+type lightNode struct {
+	pos Pos
+	lum uint8
+}
+
+// lightEngine 一次光照更新的广度优先队列, World.lightMu 保证同一时间只有一次光照更新.
+// 先处理移除队列, 清除来自被移除光源的光照, 遇到其它光源照亮的位置时放入增加队列,
+// 再由增加队列向外扩散. 光照跨越区块边界传播, 未加载或光照未计算的区块视为不透光
+type lightEngine struct {
+	w      *World
+	chunks map[ChunkPos]*Chunk
+
+	add    [lightChannelCount][]Pos
+	remove [lightChannelCount][]lightNode
+
+	// 光照发生变化的区段
+	dirty map[SectionPos]struct{}
+}
+
+func newLightEngine(w *World) *lightEngine {
+	e := new(lightEngine)
+	e.w = w
+	e.chunks = make(map[ChunkPos]*Chunk)
+	e.dirty = make(map[SectionPos]struct{})
+	return e
+}
+
+// InitChunkLum 重新计算区块光照, 并与已加载的相邻区块交换边界上的光照, 返回需要重建网格的区段
+func (w *World) InitChunkLum(cpos ChunkPos) []SectionPos {
+	w.lightMu.Lock()
+	defer w.lightMu.Unlock()
+
+	return w.initChunkLum(cpos)
+}
+
+func (w *World) initChunkLum(cpos ChunkPos) []SectionPos {
+	c := w.Chunk(cpos)
+	if c == nil {
+		return nil
+	}
+
+	e := newLightEngine(w)
+	e.chunks[cpos] = c
+
+	// 区块内相邻列直射高度不同的地方, 阳光向侧面扩散
+	heights := c.InitSkyLight(w.lightPasses)
+	for x := int64(0); x < CHUNK_WIDTH; x++ {
+		for z := int64(0); z < CHUNK_WIDTH; z++ {
+			h := heights[x*CHUNK_WIDTH+z]
+			max := h
+			for _, d := range [][2]int64{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				nx, nz := x+d[0], z+d[1]
+				if nx < 0 || nx >= CHUNK_WIDTH || nz < 0 || nz >= CHUNK_WIDTH {
+					continue
+				}
+				if nh := heights[nx*CHUNK_WIDTH+nz]; nh > max {
+					max = nh
+				}
+			}
+
+			for y := h; y < max; y++ {
+				e.add[sunChannel] = append(e.add[sunChannel], c.GetWorldPos(x, y, z))
+			}
+		}
+	}
+
+	e.seedBorders(c)
+
+	// 方块光源, 遍历时持有读锁, 之后再写入光照
+	sources := make([]Pos, 0)
+	c.RangeBlocks(func(x, y, z int64, id BlockId) {
+		if w.blockLight(id).Max() > 0 {
+			sources = append(sources, NewPos(x, y, z))
+		}
+	})
+	for _, p := range sources {
+		id := c.GetBlock(p.X, p.Y, p.Z)
+		c.SetLum(p, c.GetLum(p).SetBlockColor(w.blockLight(id)))
+
+		wPos := c.GetWorldPos(p.X, p.Y, p.Z)
+		for ch := redChannel; ch < lightChannelCount; ch++ {
+			e.add[ch] = append(e.add[ch], wPos)
+		}
+	}
+
+	e.propagate()
+
+	// 整个区块的光照都已重置, 区块和相邻区块朝向它的面都需要重建
+	e.markChunk(cpos)
+	e.markChunk(ChunkPos{X: cpos.X - 1, Z: cpos.Z})
+	e.markChunk(ChunkPos{X: cpos.X + 1, Z: cpos.Z})
+	e.markChunk(ChunkPos{X: cpos.X, Z: cpos.Z - 1})
+	e.markChunk(ChunkPos{X: cpos.X, Z: cpos.Z + 1})
+
+	return e.sections()
+}
+
+// TiggerLumUpdate 方块变化后增量更新光照, 返回需要重建网格的区段
+func (w *World) TiggerLumUpdate(pos Pos) []SectionPos {
+	w.lightMu.Lock()
+	defer w.lightMu.Unlock()
+
+	e := newLightEngine(w)
+	c, local, ok := e.chunk(pos)
+	if !ok {
+		return nil
+	}
+
+	id := c.GetBlock(local.X, local.Y, local.Z)
+	lum := c.GetLum(local)
+	for ch := lightChannel(0); ch < lightChannelCount; ch++ {
+		old, src := ch.get(lum), e.source(ch, pos, id)
+		lum = ch.set(lum, src)
+
+		if old > src {
+			e.remove[ch] = append(e.remove[ch], lightNode{pos: pos, lum: old})
+		}
+		if src > 0 {
+			e.add[ch] = append(e.add[ch], pos)
+		}
+		// 透光时周围的光照可以进入该位置
+		if w.lightPasses(id) {
+			pos.RangeAdjoin(func(p Pos, face BlockFace) {
+				e.add[ch] = append(e.add[ch], p)
+			})
+		}
+	}
+	e.setLum(c, local, pos, lum)
+
+	e.propagate()
+	return e.sections()
+}
+
+// LoadChunkLum 从存档加载区块后更新光照. 保存的光照无效时重新计算;
+// 有效时检查与已加载相邻区块的边界, 一侧的光照在另一侧卸载期间失去来源时重新计算那一侧, 否则只交换边界光照
+func (w *World) LoadChunkLum(cpos ChunkPos) []SectionPos {
+	w.lightMu.Lock()
+	defer w.lightMu.Unlock()
+
+	c := w.Chunk(cpos)
+	if c == nil {
+		return nil
+	}
+	if !c.LightValid() {
+		return w.initChunkLum(cpos)
+	}
+
+	e := newLightEngine(w)
+	stale := make(map[ChunkPos]bool)
+	e.rangeBorder(c, func(in, out Pos) {
+		if e.stale(in) {
+			stale[cpos] = true
+		}
+		if e.stale(out) {
+			stale[out.ChunkPos()] = true
+		}
+	})
+
+	if len(stale) == 0 {
+		e.seedBorders(c)
+		e.propagate()
+		return e.sections()
+	}
+
+	sections := make([]SectionPos, 0)
+	for p := range stale {
+		sections = append(sections, w.initChunkLum(p)...)
+	}
+	return sections
+}
+
+// rangeBorder 遍历区块边界上每一对相邻的位置, in 在区块内, out 在已计算光照的相邻区块中
+func (e *lightEngine) rangeBorder(c *Chunk, fn func(in, out Pos)) {
+	for i := int64(0); i < CHUNK_WIDTH; i++ {
+		pairs := [4][2]Pos{
+			{NewPos(0, 0, i), NewPos(-1, 0, i)},
+			{NewPos(CHUNK_WIDTH-1, 0, i), NewPos(CHUNK_WIDTH, 0, i)},
+			{NewPos(i, 0, 0), NewPos(i, 0, -1)},
+			{NewPos(i, 0, CHUNK_WIDTH-1), NewPos(i, 0, CHUNK_WIDTH)},
+		}
+
+		for _, pair := range pairs {
+			in := c.GetWorldPos(pair[0].X, c.MinY(), pair[0].Z)
+			out := c.GetWorldPos(pair[1].X, c.MinY(), pair[1].Z)
+			if _, _, ok := e.chunk(out); !ok {
+				continue
+			}
+
+			for y := c.MinY(); y < c.MaxY(); y++ {
+				fn(NewPos(in.X, y, in.Z), NewPos(out.X, y, out.Z))
+			}
+		}
+	}
+}
+
+// seedBorders 比较区块边界两侧的光照, 较亮的一侧放入增加队列
+func (e *lightEngine) seedBorders(c *Chunk) {
+	e.rangeBorder(c, func(in, out Pos) {
+		inLum, _ := e.lum(in)
+		outLum, _ := e.lum(out)
+
+		for ch := lightChannel(0); ch < lightChannelCount; ch++ {
+			a, b := ch.get(inLum), ch.get(outLum)
+			if a > b+1 {
+				e.add[ch] = append(e.add[ch], in)
+			} else if b > a+1 {
+				e.add[ch] = append(e.add[ch], out)
+			}
+		}
+	})
+}
+
+// stale 位置的光照是否高于自身和周围能提供的光照, 周围有不可用的区块时无法判断, 视为有效
+func (e *lightEngine) stale(pos Pos) bool {
+	c, local, ok := e.chunk(pos)
+	if !ok {
+		return false
+	}
+
+	id := c.GetBlock(local.X, local.Y, local.Z)
+	var max [lightChannelCount]uint8
+	for ch := lightChannel(0); ch < lightChannelCount; ch++ {
+		max[ch] = e.source(ch, pos, id)
+	}
+
+	if e.w.lightPasses(id) {
+		unknown := false
+		pos.RangeAdjoin(func(p Pos, face BlockFace) {
+			if e.w.PosOverRange(p) {
+				return
+			}
+			nLum, ok := e.lum(p)
+			if !ok {
+				unknown = true
+				return
+			}
+
+			for ch := lightChannel(0); ch < lightChannelCount; ch++ {
+				l := ch.get(nLum)
+				if l == 0 {
+					continue
+				}
+				v := l - 1
+				if ch == sunChannel && face == BlockFaceTop && l == MAX_LUM {
+					v = MAX_LUM
+				}
+				if v > max[ch] {
+					max[ch] = v
+				}
+			}
+		})
+		if unknown {
+			return false
+		}
+	}
+
+	lum := c.GetLum(local)
+	for ch := lightChannel(0); ch < lightChannelCount; ch++ {
+		if ch.get(lum) > max[ch] {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *lightEngine) propagate() {
+	for ch := lightChannel(0); ch < lightChannelCount; ch++ {
+		e.propagateRemove(ch)
+		e.propagateAdd(ch)
+	}
+}
+
+// propagateRemove 清除移除队列中位置照亮的邻居, 邻居的光照不低于它时由邻居重新扩散
+func (e *lightEngine) propagateRemove(ch lightChannel) {
+	queue := e.remove[ch]
+	for i := 0; i < len(queue); i++ {
+		n := queue[i]
+		n.pos.RangeAdjoin(func(p Pos, face BlockFace) {
+			c, local, ok := e.chunk(p)
+			if !ok {
+				return
+			}
+
+			lum := c.GetLum(local)
+			l := ch.get(lum)
+			if l == 0 {
+				return
+			}
+
+			lit := l < n.lum || (ch == sunChannel && face == BlockFaceBottom && n.lum == MAX_LUM && l == MAX_LUM)
+			src := e.source(ch, p, c.GetBlock(local.X, local.Y, local.Z))
+			if !lit || l <= src {
+				e.add[ch] = append(e.add[ch], p)
+				return
+			}
+
+			e.setLum(c, local, p, ch.set(lum, src))
+			queue = append(queue, lightNode{pos: p, lum: l})
+			if src > 0 {
+				e.add[ch] = append(e.add[ch], p)
+			}
+		})
+	}
+	e.remove[ch] = queue[:0]
+}
+
+// propagateAdd 由增加队列向透光的邻居扩散, 每格减一, 满级阳光向下不衰减
+func (e *lightEngine) propagateAdd(ch lightChannel) {
+	queue := e.add[ch]
+	for i := 0; i < len(queue); i++ {
+		pos := queue[i]
+		c, local, ok := e.chunk(pos)
+		if !ok {
+			continue
+		}
+
+		l := ch.get(c.GetLum(local))
+		if l <= 1 {
+			continue
+		}
+
+		pos.RangeAdjoin(func(p Pos, face BlockFace) {
+			nc, nLocal, ok := e.chunk(p)
+			if !ok || !e.w.lightPasses(nc.GetBlock(nLocal.X, nLocal.Y, nLocal.Z)) {
+				return
+			}
+
+			v := l - 1
+			if ch == sunChannel && face == BlockFaceBottom && l == MAX_LUM {
+				v = MAX_LUM
+			}
+
+			lum := nc.GetLum(nLocal)
+			if ch.get(lum) < v {
+				e.setLum(nc, nLocal, p, ch.set(lum, v))
+				queue = append(queue, p)
+			}
+		})
+	}
+	e.add[ch] = queue[:0]
+}
+
+// source 位置本身发出的光照: 方块光源, 或世界最高一层的直射阳光
+func (e *lightEngine) source(ch lightChannel, pos Pos, id BlockId) uint8 {
+	if ch == sunChannel {
+		if e.w.lightPasses(id) && pos.Y == e.w.dim.MaxY()-1 {
+			return MAX_LUM
+		}
+		return 0
+	}
+
+	return ch.color(e.w.blockLight(id))
+}
+
+// chunk 返回坐标所在的区块与区块内坐标, 超出高度范围、区块未加载或光照未计算时 ok 为 false
+func (e *lightEngine) chunk(pos Pos) (c *Chunk, local Pos, ok bool) {
+	if e.w.PosOverRange(pos) {
+		return nil, pos, false
+	}
+
+	cpos := pos.ChunkPos()
+	c, cached := e.chunks[cpos]
+	if !cached {
+		c = e.w.Chunk(cpos)
+		if c != nil && !c.LightValid() {
+			c = nil
+		}
+		e.chunks[cpos] = c
+	}
+	if c == nil {
+		return nil, pos, false
+	}
+
+	return c, c.ConvertChunkPos(pos), true
+}
+
+func (e *lightEngine) lum(pos Pos) (Luminance, bool) {
+	c, local, ok := e.chunk(pos)
+	if !ok {
+		return 0, false
+	}
+	return c.GetLum(local), true
+}
+
+// setLum 写入光照并记录受影响的区段, 位于区段边界时相邻区段朝向它的面也受影响
+func (e *lightEngine) setLum(c *Chunk, local, pos Pos, lum Luminance) {
+	c.SetLum(local, lum)
+
+	cpos := c.Pos()
+	idx := c.sectionIdx(pos.Y)
+	e.mark(cpos, idx)
+
+	if local.X == 0 {
+		e.mark(ChunkPos{X: cpos.X - 1, Z: cpos.Z}, idx)
+	} else if local.X == CHUNK_WIDTH-1 {
+		e.mark(ChunkPos{X: cpos.X + 1, Z: cpos.Z}, idx)
+	}
+	if local.Z == 0 {
+		e.mark(ChunkPos{X: cpos.X, Z: cpos.Z - 1}, idx)
+	} else if local.Z == CHUNK_WIDTH-1 {
+		e.mark(ChunkPos{X: cpos.X, Z: cpos.Z + 1}, idx)
+	}
+
+	switch c.sectionY(pos.Y) {
+	case 0:
+		if idx > 0 {
+			e.mark(cpos, idx-1)
+		}
+	case SECTION_SIZE - 1:
+		if idx < c.SectionCount()-1 {
+			e.mark(cpos, idx+1)
+		}
+	}
+}
+
+func (e *lightEngine) mark(cpos ChunkPos, idx int) {
+	e.dirty[SectionPos{Chunk: cpos, Index: idx}] = struct{}{}
+}
+
+func (e *lightEngine) markChunk(cpos ChunkPos) {
+	for i := 0; i < e.w.dim.SectionCount(); i++ {
+		e.mark(cpos, i)
+	}
+}
+
+func (e *lightEngine) sections() []SectionPos {
+	arr := make([]SectionPos, 0, len(e.dirty))
+	for sp := range e.dirty {
+		arr = append(arr, sp)
+	}
+	return arr
+}
+
+// lightPasses 方块是否透光, 由方块属性中的透明决定, 例如树叶、草、花和水
+func (w *World) lightPasses(id BlockId) bool {
+	return w.BlockTransparent(id)
+}
+
+func (w *World) blockLight(id BlockId) LightColor {
+	if w.br == nil {
+		return LightColor{}
+	}
+
+	return w.br.GetBlockLight(id)
+}
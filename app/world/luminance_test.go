@@ -0,0 +1,335 @@
+package world
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// lightArea 光照测试加载的区块范围, 方块坐标 [-16, 32)
+const lightAreaMin, lightAreaMax = -CHUNK_WIDTH, 2 * CHUNK_WIDTH
+
+// lightSurface 测试世界地表之上第一格的高度
+var lightSurface = DefaultDimension.MinY + 22
+
+// refLight 不断松弛直到不再变化, 得到区域内光照的参考值
+func refLight(w *World) map[Pos]Luminance {
+	const width = lightAreaMax - lightAreaMin
+	height := w.dim.Height
+	index := func(x, y, z int64) int64 {
+		return ((x-lightAreaMin)*width+(z-lightAreaMin))*height + (y - w.dim.MinY)
+	}
+
+	// 先读出所有方块的属性, 松弛时只访问数组
+	passes := make([]bool, width*width*height)
+	lums := make([][4]uint8, len(passes)) // 阳光, 红, 绿, 蓝
+	for x := int64(lightAreaMin); x < lightAreaMax; x++ {
+		for z := int64(lightAreaMin); z < lightAreaMax; z++ {
+			for y := w.dim.MinY; y < w.dim.MaxY(); y++ {
+				id, _ := w.GetBlock(NewPos(x, y, z))
+				c := w.blockLight(id)
+				i := index(x, y, z)
+				passes[i] = w.lightPasses(id)
+				lums[i] = [4]uint8{0, c.R, c.G, c.B}
+			}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for x := int64(lightAreaMin); x < lightAreaMax; x++ {
+			for z := int64(lightAreaMin); z < lightAreaMax; z++ {
+				for y := w.dim.MaxY() - 1; y >= w.dim.MinY; y-- {
+					i := index(x, y, z)
+					if !passes[i] {
+						continue
+					}
+
+					l := lums[i]
+					if y == w.dim.MaxY()-1 {
+						l[0] = MAX_LUM
+					}
+					NewPos(x, y, z).RangeAdjoin(func(n Pos, face BlockFace) {
+						if w.PosOverRange(n) || n.X < lightAreaMin || n.X >= lightAreaMax || n.Z < lightAreaMin || n.Z >= lightAreaMax {
+							return
+						}
+						nl := lums[index(n.X, n.Y, n.Z)]
+						// 直射阳光向下不衰减
+						if face == BlockFaceTop && nl[0] == MAX_LUM {
+							l[0] = MAX_LUM
+						}
+						for ch, v := range nl {
+							if v > 0 && v-1 > l[ch] {
+								l[ch] = v - 1
+							}
+						}
+					})
+
+					if l != lums[i] {
+						lums[i] = l
+						changed = true
+					}
+				}
+			}
+		}
+	}
+
+	res := make(map[Pos]Luminance, len(lums))
+	for x := int64(lightAreaMin); x < lightAreaMax; x++ {
+		for z := int64(lightAreaMin); z < lightAreaMax; z++ {
+			for y := w.dim.MinY; y < w.dim.MaxY(); y++ {
+				l := lums[index(x, y, z)]
+				res[NewPos(x, y, z)] = NewColorLuminance(l[0], LightColor{R: l[1], G: l[2], B: l[3]})
+			}
+		}
+	}
+	return res
+}
+
+// checkLight 比较增量计算的光照与参考值
+func checkLight(t *testing.T, w *World, step string) {
+	t.Helper()
+	bad := 0
+	for p, want := range refLight(w) {
+		if got, _ := w.GetLum(p); got != want {
+			if bad < 5 {
+				t.Errorf("%s: light at %v is sun %d block %v, want sun %d block %v",
+					step, p, got.SunLum(), got.BlockColor(), want.SunLum(), want.BlockColor())
+			}
+			bad++
+		}
+	}
+	if bad > 0 {
+		t.Fatalf("%s: %d positions differ", step, bad)
+	}
+}
+
+// newLightWorld 地表平坦的世界, 加载原点周围 3×3 个区块并计算光照
+func newLightWorld(t *testing.T) *World {
+	wg, err := NewGenerator(SUPERFLAT_GENERATOR, []byte(`{"layers":"stone,20*soil,grass"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Setup(1)
+	w := newTestWorld(t, wg)
+	for x := int64(-1); x <= 1; x++ {
+		for z := int64(-1); z <= 1; z++ {
+			c, _ := w.LoadChunk(ChunkPos{X: x, Z: z}, nil)
+			w.AddChunk(c)
+		}
+	}
+	for x := int64(-1); x <= 1; x++ {
+		for z := int64(-1); z <= 1; z++ {
+			w.InitChunkLum(ChunkPos{X: x, Z: z})
+		}
+	}
+	return w
+}
+
+// setBlock 修改方块并更新光照
+func setBlock(w *World, p Pos, id BlockId) {
+	w.SetBlock(p, id)
+	w.TiggerLumUpdate(p)
+}
+
+func sunAt(w *World, p Pos) uint8 {
+	lum, _ := w.GetLum(p)
+	return lum.SunLum()
+}
+
+func TestLightInit(t *testing.T) {
+	w := newLightWorld(t)
+	checkLight(t, w, "flat")
+
+	// 透明方块在计算光照前放置, 阳光直接穿过
+	w.SetBlock(NewPos(3, lightSurface, 3), BlockTallGrass)
+	for y := lightSurface + 4; y < lightSurface+6; y++ {
+		w.SetBlock(NewPos(8, y, 8), BlockLeaves)
+	}
+	w.SetBlock(NewPos(12, lightSurface-1, 12), BlockWater)
+	w.InitChunkLum(ChunkPos{})
+	checkLight(t, w, "transparent")
+
+	for _, p := range []Pos{NewPos(3, lightSurface, 3), NewPos(8, lightSurface, 8), NewPos(12, lightSurface-1, 12)} {
+		if sun := sunAt(w, p); sun != MAX_LUM {
+			t.Errorf("sun at %v is %d under transparent blocks", p, sun)
+		}
+	}
+}
+
+func TestLightPlaceRemoveLamp(t *testing.T) {
+	w := newLightWorld(t)
+
+	// 地下跨越区块边界 x=0 的隧道
+	y := lightSurface - 5
+	for x := int64(-6); x <= 6; x++ {
+		setBlock(w, NewPos(x, y, 3), BlockAir)
+	}
+	checkLight(t, w, "tunnel")
+
+	lamp := NewPos(-2, y, 3)
+	setBlock(w, lamp, BlockLamp)
+	if lum, _ := w.GetLum(NewPos(2, y, 3)); lum.BlockColor() != (LightColor{R: 11, G: 6}) {
+		t.Fatalf("lamp light across the chunk border is %v", lum.BlockColor())
+	}
+	checkLight(t, w, "lamp")
+
+	setBlock(w, lamp, BlockAir)
+	if lum, _ := w.GetLum(NewPos(2, y, 3)); lum.BlockLum() != 0 {
+		t.Fatalf("stale lamp light %v", lum.BlockColor())
+	}
+	checkLight(t, w, "lamp removed")
+}
+
+func TestLightRemoveWithOtherSource(t *testing.T) {
+	w := newLightWorld(t)
+
+	y := lightSurface - 5
+	for x := int64(-6); x <= 10; x++ {
+		setBlock(w, NewPos(x, y, 3), BlockAir)
+	}
+	a, b := NewPos(-4, y, 3), NewPos(6, y, 3)
+	setBlock(w, a, BlockLamp)
+	setBlock(w, b, BlockLamp)
+	checkLight(t, w, "two lamps")
+
+	// 移除一盏灯后, 另一盏灯照到的位置保持它的光照
+	setBlock(w, a, BlockAir)
+	checkLight(t, w, "one lamp removed")
+	if lum, _ := w.GetLum(NewPos(2, y, 3)); lum.BlockColor().R != 11 {
+		t.Fatalf("light from the remaining lamp is %v", lum.BlockColor())
+	}
+	if lum, _ := w.GetLum(NewPos(-6, y, 3)); lum.BlockColor().R != 3 {
+		t.Fatalf("light far from the remaining lamp is %v", lum.BlockColor())
+	}
+}
+
+func TestLightOverhang(t *testing.T) {
+	w := newLightWorld(t)
+
+	// 高处 x、z 从 -5 到 5 的屋顶, 屋顶下的阳光从四周扩散进来
+	roofY := lightSurface + 6
+	for x := int64(-5); x <= 5; x++ {
+		for z := int64(-5); z <= 5; z++ {
+			setBlock(w, NewPos(x, roofY, z), BlockStone)
+		}
+	}
+	checkLight(t, w, "roof")
+	if sun := sunAt(w, NewPos(0, lightSurface, 0)); sun >= MAX_LUM-1 {
+		t.Fatalf("sun under the roof is %d", sun)
+	}
+	if sun := sunAt(w, NewPos(-5, lightSurface, 0)); sun != MAX_LUM-1 {
+		t.Fatalf("sun at the edge of the roof is %d", sun)
+	}
+
+	// 树叶屋顶不遮挡阳光
+	setBlock(w, NewPos(0, roofY, 0), BlockLeaves)
+	checkLight(t, w, "leaves in roof")
+	if sun := sunAt(w, NewPos(0, lightSurface, 0)); sun != MAX_LUM {
+		t.Fatalf("sun under leaves is %d", sun)
+	}
+
+	for x := int64(-5); x <= 5; x++ {
+		for z := int64(-5); z <= 5; z++ {
+			setBlock(w, NewPos(x, roofY, z), BlockAir)
+		}
+	}
+	checkLight(t, w, "roof removed")
+}
+
+func TestLightRandomEdits(t *testing.T) {
+	w := newLightWorld(t)
+	r := rand.New(rand.NewSource(3))
+	ids := []BlockId{BlockAir, BlockAir, BlockStone, BlockLamp, BlockLeaves, BlockWater}
+	for round := 0; round < 4; round++ {
+		for i := 0; i < 60; i++ {
+			p := NewPos(r.Int63n(40)-12, lightSurface-10+r.Int63n(25), r.Int63n(40)-12)
+			setBlock(w, p, ids[r.Intn(len(ids))])
+		}
+		checkLight(t, w, "random")
+	}
+}
+
+// TestLightConcurrentInit 后台协程同时计算多个区块的光照, 需要用 go test -race 运行
+func TestLightConcurrentInit(t *testing.T) {
+	w := newLightWorld(t)
+	y := lightSurface - 5
+	for x := int64(-6); x <= 6; x++ {
+		w.SetBlock(NewPos(x, y, 3), BlockAir)
+	}
+	w.SetBlock(NewPos(-2, y, 3), BlockLamp)
+
+	var g sync.WaitGroup
+	for x := int64(-1); x <= 1; x++ {
+		for z := int64(-1); z <= 1; z++ {
+			g.Add(1)
+			go func(cpos ChunkPos) {
+				defer g.Done()
+				w.InitChunkLum(cpos)
+			}(ChunkPos{X: x, Z: z})
+		}
+	}
+	g.Add(1)
+	go func() {
+		defer g.Done()
+		w.TiggerLumUpdate(NewPos(2, y, 3))
+	}()
+	g.Wait()
+
+	checkLight(t, w, "concurrent init")
+}
+
+// reloadChunk 从保存的数据重新加载区块并更新光照
+func reloadChunk(t *testing.T, w *World, data ChunkData) {
+	t.Helper()
+	c := NewChunk(data.Pos.X, data.Pos.Z, DefaultDimension)
+	if err := c.LoadFromData(data); err != nil {
+		t.Fatal(err)
+	}
+	if !c.LightValid() {
+		t.Fatal("saved light is not valid")
+	}
+	w.AddChunk(c)
+	w.LoadChunkLum(data.Pos)
+}
+
+func TestLightReloadNeighbour(t *testing.T) {
+	w := newLightWorld(t)
+	y := lightSurface - 5
+	for x := int64(-6); x <= 6; x++ {
+		setBlock(w, NewPos(x, y, 3), BlockAir)
+	}
+	west := ChunkPos{X: -1}
+	lamp := NewPos(2, y, 3)
+
+	// 相邻区块卸载期间移除照亮它的灯, 重新加载时它保存的灯光已没有来源
+	setBlock(w, lamp, BlockLamp)
+	data := ConvertChunk(w.Chunk(west))
+	w.RemoveChunk(west)
+	setBlock(w, lamp, BlockAir)
+	reloadChunk(t, w, data)
+	if lum, _ := w.GetLum(NewPos(-2, y, 3)); lum.BlockLum() != 0 {
+		t.Fatalf("stale lamp light %v in the reloaded chunk", lum.BlockColor())
+	}
+	checkLight(t, w, "lamp removed while unloaded")
+
+	// 相邻区块卸载期间放置灯, 重新加载后灯光照进它
+	data = ConvertChunk(w.Chunk(west))
+	w.RemoveChunk(west)
+	setBlock(w, lamp, BlockLamp)
+	reloadChunk(t, w, data)
+	if lum, _ := w.GetLum(NewPos(-2, y, 3)); lum.BlockColor() != (LightColor{R: 11, G: 6}) {
+		t.Fatalf("lamp light in the reloaded chunk is %v", lum.BlockColor())
+	}
+	checkLight(t, w, "lamp placed while unloaded")
+
+	// 卸载期间挖开地表, 阳光从洞口照进隧道并穿过边界
+	data = ConvertChunk(w.Chunk(west))
+	w.RemoveChunk(west)
+	setBlock(w, lamp, BlockAir)
+	for y2 := y + 1; y2 < lightSurface; y2++ {
+		setBlock(w, NewPos(1, y2, 3), BlockAir)
+	}
+	reloadChunk(t, w, data)
+	checkLight(t, w, "shaft dug while unloaded")
+}
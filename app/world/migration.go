@@ -0,0 +1,217 @@
+package world
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 存档版本, 格式变化时加一并注册从上一版本升级的迁移.
+// 区块数据版本升级时世界版本也要升级, 保证旧程序会拒绝打开升级后的存档
+const (
+	// WORLD_FORMAT_VERSION 存档目录结构的版本
+	//	1: 每个区块一个 .chunk 文件
+	//	2: 区域文件
+	//	3: 区块数据带有版本号
+	//	4: seed 文件由 level.json 代替
+	//	5: 区块记录是否已装饰, 新增暂存写入文件
+	//	6: 区块保存光照
+	//	7: 方块光分为红绿蓝通道
+	WORLD_FORMAT_VERSION = 7
+
+	// CHUNK_DATA_VERSION 区块数据的版本
+	//	1: 按方块保存的 Data, 高度从 0 开始
+	//	2: 按区段保存的调色板数据
+	//	3: 记录是否已放置装饰物
+	//	4: 保存区段光照
+	//	5: 方块光分为红绿蓝通道
+	CHUNK_DATA_VERSION = 5
+)
+
+// ErrNewerVersion 存档由更新版本的程序保存, 当前程序无法读取
+type ErrNewerVersion struct {
+	What    string
+	Version int
+	Max     int
+}
+
+func (e *ErrNewerVersion) Error() string {
+	return fmt.Sprintf("%s version %d is newer than supported version %d, please upgrade the game", e.What, e.Version, e.Max)
+}
+
+// ChunkMigration 将区块数据从版本 N 升级到 N+1
+type ChunkMigration func(data *ChunkData) error
+
+// WorldMigration 将存档目录从版本 N 升级到 N+1
+type WorldMigration func(sm *fileSaveManager) error
+
+var (
+	chunkMigrations = map[int]ChunkMigration{}
+	worldMigrations = map[int]WorldMigration{}
+)
+
+// RegisterChunkMigration 注册从 from 版本升级到 from+1 版本的区块数据迁移
+func RegisterChunkMigration(from int, m ChunkMigration) {
+	if _, ok := chunkMigrations[from]; ok {
+		panic(fmt.Sprintf("chunk migration from version %d already registered", from))
+	}
+	chunkMigrations[from] = m
+}
+
+// RegisterWorldMigration 注册从 from 版本升级到 from+1 版本的存档目录迁移
+func RegisterWorldMigration(from int, m WorldMigration) {
+	if _, ok := worldMigrations[from]; ok {
+		panic(fmt.Sprintf("world migration from version %d already registered", from))
+	}
+	worldMigrations[from] = m
+}
+
+func init() {
+	RegisterChunkMigration(1, migrateChunkLegacyBlocks)
+	RegisterChunkMigration(2, migrateChunkDecorated)
+	RegisterChunkMigration(3, migrateChunkLight)
+	RegisterChunkMigration(4, migrateChunkLightColor)
+
+	RegisterWorldMigration(1, (*fileSaveManager).migrateChunkFiles)
+	// 区块数据在读取时逐个升级, 目录结构不变
+	RegisterWorldMigration(2, func(sm *fileSaveManager) error { return nil })
+	RegisterWorldMigration(3, (*fileSaveManager).migrateSeedFile)
+	// 区块数据在读取时逐个升级, 暂存写入文件不存在时为空
+	RegisterWorldMigration(4, func(sm *fileSaveManager) error { return nil })
+	// 区块数据在读取时逐个升级, 旧区块加载后重新计算光照
+	RegisterWorldMigration(5, func(sm *fileSaveManager) error { return nil })
+	// 区块数据在读取时逐个升级, 单色光照转换为白光
+	RegisterWorldMigration(6, func(sm *fileSaveManager) error { return nil })
+}
+
+// chunkDataVersion 区块数据的版本, 没有版本号的旧数据按内容判断
+func chunkDataVersion(data *ChunkData) int {
+	if data.Version != 0 {
+		return data.Version
+	}
+	if len(data.Sections) == 0 && len(data.Data) > 0 {
+		return 1
+	}
+	return 2
+}
+
+// MigrateChunk 将区块数据升级到当前版本, 数据版本比程序新时返回 ErrNewerVersion
+func MigrateChunk(data *ChunkData) error {
+	v := chunkDataVersion(data)
+	if v > CHUNK_DATA_VERSION {
+		return &ErrNewerVersion{What: "chunk " + data.Pos.Id(), Version: v, Max: CHUNK_DATA_VERSION}
+	}
+
+	for ; v < CHUNK_DATA_VERSION; v++ {
+		m, ok := chunkMigrations[v]
+		if !ok {
+			return fmt.Errorf("no chunk migration from version %d", v)
+		}
+		if err := m(data); err != nil {
+			return fmt.Errorf("migrate chunk %s from version %d: %w", data.Pos.Id(), v, err)
+		}
+	}
+
+	data.Version = CHUNK_DATA_VERSION
+	return nil
+}
+
+// migrateChunkLegacyBlocks 1 -> 2: 按方块保存的数据转换为区段
+func migrateChunkLegacyBlocks(data *ChunkData) error {
+	sections := make(map[int64]*PalettedStorage)
+	for key, b := range data.Data {
+		if b.Id == BlockAir {
+			continue
+		}
+
+		y, x, z := int64(key>>8), int64(key>>4&0xf), int64(key&0xf)
+		sy := FloorDiv(y, SECTION_SIZE) * SECTION_SIZE
+		s, ok := sections[sy]
+		if !ok {
+			s = NewPalettedStorage(SECTION_VOLUME)
+			sections[sy] = s
+		}
+		s.Set(sectionIndex(x, y-sy, z), b.Id)
+	}
+
+	ys := make([]int64, 0, len(sections))
+	for y := range sections {
+		ys = append(ys, y)
+	}
+	sort.Slice(ys, func(i, j int) bool { return ys[i] < ys[j] })
+
+	data.Sections = make([]SectionData, 0, len(ys))
+	for _, y := range ys {
+		s := sections[y]
+		s.Compact()
+		palette, bits, blocks := s.Export()
+		data.Sections = append(data.Sections, SectionData{Y: y, Palette: palette, Bits: uint8(bits), Blocks: blocks})
+	}
+	data.Data = nil
+
+	return nil
+}
+
+// migrateChunkDecorated 2 -> 3: 旧区块视为已装饰, 避免在玩家建造过的地方长出树木
+func migrateChunkDecorated(data *ChunkData) error {
+	data.Decorated = true
+	return nil
+}
+
+// migrateChunkLight 3 -> 4: 旧区块没有光照, 加载后重新计算
+func migrateChunkLight(data *ChunkData) error {
+	data.LightValid = false
+	return nil
+}
+
+// migrateChunkLightColor 4 -> 5: 单色的方块光转换为三个通道相同的白光
+func migrateChunkLightColor(data *ChunkData) error {
+	convert := func(v uint8) Luminance {
+		return NewLuminance(v>>4, v&0xf)
+	}
+
+	for i := range data.Sections {
+		sd := &data.Sections[i]
+		if data.LightValid {
+			sd.Light = convert(sd.LegacyLum)
+			if len(sd.LegacyLums) != 0 {
+				sd.Lights = make([]Luminance, len(sd.LegacyLums))
+				for j, v := range sd.LegacyLums {
+					sd.Lights[j] = convert(v)
+				}
+			}
+		}
+		sd.LegacyLums = nil
+		sd.LegacyLum = 0
+	}
+
+	return nil
+}
+
+// migrateWorld 将存档目录升级到当前版本, 存档比程序新时返回 ErrNewerVersion
+func (sm *fileSaveManager) migrateWorld() error {
+	v, err := sm.loadFormatVersion()
+	if err != nil {
+		return err
+	}
+	if v > WORLD_FORMAT_VERSION {
+		return &ErrNewerVersion{What: "world " + sm.baseDir, Version: v, Max: WORLD_FORMAT_VERSION}
+	}
+
+	for ; v < WORLD_FORMAT_VERSION; v++ {
+		m, ok := worldMigrations[v]
+		if !ok {
+			return fmt.Errorf("no world migration from version %d", v)
+		}
+
+		sm.log.Info("migrating world from version %d to %d", v, v+1)
+		if err := m(sm); err != nil {
+			return fmt.Errorf("migrate world from version %d: %w", v, err)
+		}
+		// 每一步完成后立即记录, 中途退出时下次从这里继续
+		if err := sm.saveFormatVersion(v + 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// ORE_FILE 矿物分布配置, 位于数据目录的 config 下
+const ORE_FILE = "ore.json"
+
+// oreSalt 矿物阶段的随机数 salt, 每种矿物再加上自己的序号
+const oreSalt uint64 = 0x6f7265
+
+// oreReplaceable 矿脉只替换这些方块
+var oreReplaceable = map[BlockId]bool{
+	BlockStone:     true,
+	BlockSandstone: true,
+}
+
+// OreConfig 一种矿物的分布
+type OreConfig struct {
+	Name  string  `json:"name"`
+	Block BlockId `json:"block"`
+	// 矿脉起点的高度范围 [MinY, MaxY]
+	MinY int64 `json:"min_y"`
+	MaxY int64 `json:"max_y"`
+	// VeinSize 每条矿脉最多的方块数
+	VeinSize int `json:"vein_size"`
+	// VeinsPerChunk 每个区块尝试生成的矿脉数
+	VeinsPerChunk int `json:"veins_per_chunk"`
+	// Biomes 允许出现的群系名称, 为空时所有群系都可以出现
+	Biomes []string `json:"biomes,omitempty"`
+
+	biomes map[BiomeId]bool
+}
+
+var DefaultOres = []OreConfig{
+	{Name: "coal", Block: BlockCoalOre, MinY: -16, MaxY: 96, VeinSize: 12, VeinsPerChunk: 14},
+	{Name: "iron", Block: BlockIronOre, MinY: -28, MaxY: 48, VeinSize: 8, VeinsPerChunk: 8},
+	{Name: "gold", Block: BlockGoldOre, MinY: -28, MaxY: 8, VeinSize: 6, VeinsPerChunk: 3, Biomes: []string{"desert", "mountains"}},
+}
+
+// LoadOres 读取矿物分布配置
+func LoadOres(path string) ([]OreConfig, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ores []OreConfig
+	if err := json.Unmarshal(bs, &ores); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	for i := range ores {
+		if err := ores[i].init(); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	return ores, nil
+}
+
+// init 检查配置并解析群系名称
+func (o *OreConfig) init() error {
+	if o.Block == BlockAir {
+		return fmt.Errorf("ore %s: missing block", o.Name)
+	}
+	if o.MinY > o.MaxY {
+		return fmt.Errorf("ore %s: min_y %d greater than max_y %d", o.Name, o.MinY, o.MaxY)
+	}
+	if o.VeinSize <= 0 || o.VeinsPerChunk < 0 {
+		return fmt.Errorf("ore %s: invalid vein size %d or veins per chunk %d", o.Name, o.VeinSize, o.VeinsPerChunk)
+	}
+
+	o.biomes = nil
+	if len(o.Biomes) == 0 {
+		return nil
+	}
+
+	o.biomes = make(map[BiomeId]bool, len(o.Biomes))
+	for _, name := range o.Biomes {
+		b := GetBiomeByName(name)
+		if b == nil {
+			return fmt.Errorf("ore %s: unknown biome %q", o.Name, name)
+		}
+		o.biomes[b.Id] = true
+	}
+	return nil
+}
+
+func (o *OreConfig) allowed(biome BiomeId) bool {
+	return o.biomes == nil || o.biomes[biome]
+}
+
+// Populate 在区块中放置矿脉, 实现 IChunkPopulator.
+// 矿脉只写入本区块, 超出区块的部分被截断
+func (wg *WorldGenerator) Populate(c *Chunk) {
+	for i := range wg.ores {
+		wg.placeOre(c, &wg.ores[i], oreSalt+uint64(i))
+	}
+}
+
+func (wg *WorldGenerator) placeOre(c *Chunk, ore *OreConfig, salt uint64) {
+	minY, maxY := ore.MinY, ore.MaxY
+	if minY < c.MinY() {
+		minY = c.MinY()
+	}
+	if maxY >= c.MaxY() {
+		maxY = c.MaxY() - 1
+	}
+	if minY > maxY {
+		return
+	}
+
+	r := ChunkRand(wg.seed, c.Pos(), salt)
+	for v := 0; v < ore.VeinsPerChunk; v++ {
+		// 先取完所有随机数, 保证群系不同时后续矿脉的位置不受影响
+		x := r.Int63n(CHUNK_WIDTH)
+		z := r.Int63n(CHUNK_WIDTH)
+		y := minY + r.Int63n(maxY-minY+1)
+		steps := make([]int, ore.VeinSize)
+		for i := range steps {
+			steps[i] = r.Intn(6)
+		}
+
+		if !ore.allowed(c.Biome(x, z)) {
+			continue
+		}
+
+		// 随机游走形成矿脉
+		pos := NewPos(x, y, z)
+		for _, step := range steps {
+			if oreReplaceable[c.GetBlock(pos.X, pos.Y, pos.Z)] {
+				c.SetBlock(pos.X, pos.Y, pos.Z, ore.Block)
+			}
+			pos = pos.Add(oreSteps[step])
+		}
+	}
+}
+
+var oreSteps = []Pos{
+	NewPos(1, 0, 0), NewPos(-1, 0, 0),
+	NewPos(0, 1, 0), NewPos(0, -1, 0),
+	NewPos(0, 0, 1), NewPos(0, 0, -1),
+}
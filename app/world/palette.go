@@ -0,0 +1,192 @@
+package world
+
+import "fmt"
+
+// PalettedStorage 调色板存储
+// 区块内出现过的方块 id 保存在调色板中, 每个方块只记录其在调色板中的下标,
+// 下标按 bits 位紧密排列在 uint64 数组中. 调色板只有一项时不分配下标数组.
+type PalettedStorage struct {
+	size    int
+	bits    uint
+	palette []BlockId
+	data    []uint64
+}
+
+func NewPalettedStorage(size int) *PalettedStorage {
+	s := new(PalettedStorage)
+	s.size = size
+	s.palette = []BlockId{BlockAir}
+	return s
+}
+
+func (s *PalettedStorage) Size() int {
+	return s.size
+}
+
+// Palette 返回调色板, 调用方不应修改返回值
+func (s *PalettedStorage) Palette() []BlockId {
+	return s.palette
+}
+
+// Clone 复制存储, 副本与原存储不共享任何数据
+func (s *PalettedStorage) Clone() *PalettedStorage {
+	n := *s
+	n.palette = append([]BlockId(nil), s.palette...)
+	n.data = append([]uint64(nil), s.data...)
+	return &n
+}
+
+// IsEmpty 是否全部为空气
+func (s *PalettedStorage) IsEmpty() bool {
+	return len(s.palette) == 1 && s.palette[0] == BlockAir
+}
+
+func (s *PalettedStorage) Get(i int) BlockId {
+	return s.palette[s.index(i)]
+}
+
+func (s *PalettedStorage) Set(i int, id BlockId) {
+	idx, ok := s.paletteIndex(id)
+	if !ok {
+		s.palette = append(s.palette, id)
+		idx = len(s.palette) - 1
+		if need := bitsFor(len(s.palette)); need > s.bits {
+			s.resize(need)
+		}
+	}
+
+	if s.bits == 0 {
+		return
+	}
+	s.setIndex(i, uint64(idx))
+}
+
+// Fill 将所有位置设置为同一个方块
+func (s *PalettedStorage) Fill(id BlockId) {
+	s.palette = []BlockId{id}
+	s.bits = 0
+	s.data = nil
+}
+
+// Compact 移除调色板中不再使用的方块, 并尽量缩小下标位数
+func (s *PalettedStorage) Compact() {
+	if s.bits == 0 {
+		return
+	}
+
+	used := make([]int, len(s.palette))
+	for i := 0; i < s.size; i++ {
+		used[s.index(i)]++
+	}
+
+	remap := make([]uint64, len(s.palette))
+	palette := make([]BlockId, 0, len(s.palette))
+	for idx, count := range used {
+		if count == 0 {
+			continue
+		}
+		remap[idx] = uint64(len(palette))
+		palette = append(palette, s.palette[idx])
+	}
+
+	if len(palette) == 1 {
+		s.Fill(palette[0])
+		return
+	}
+
+	old := *s
+	s.palette = palette
+	s.bits = bitsFor(len(palette))
+	s.data = make([]uint64, dataLen(s.size, s.bits))
+	for i := 0; i < s.size; i++ {
+		s.setIndex(i, remap[old.index(i)])
+	}
+}
+
+func (s *PalettedStorage) paletteIndex(id BlockId) (int, bool) {
+	for i, item := range s.palette {
+		if item == id {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (s *PalettedStorage) resize(bits uint) {
+	old := *s
+	s.bits = bits
+	s.data = make([]uint64, dataLen(s.size, bits))
+	for i := 0; i < s.size; i++ {
+		s.setIndex(i, uint64(old.index(i)))
+	}
+}
+
+func (s *PalettedStorage) index(i int) int {
+	if s.bits == 0 {
+		return 0
+	}
+
+	perWord := 64 / s.bits
+	word := s.data[uint(i)/perWord]
+	shift := (uint(i) % perWord) * s.bits
+	return int((word >> shift) & (1<<s.bits - 1))
+}
+
+func (s *PalettedStorage) setIndex(i int, idx uint64) {
+	perWord := 64 / s.bits
+	w := uint(i) / perWord
+	shift := (uint(i) % perWord) * s.bits
+	mask := uint64(1<<s.bits-1) << shift
+	s.data[w] = s.data[w]&^mask | idx<<shift
+}
+
+// bitsFor 保存 n 个调色板下标需要的位数
+func bitsFor(n int) uint {
+	var bits uint
+	for 1<<bits < n {
+		bits++
+	}
+	return bits
+}
+
+func dataLen(size int, bits uint) int {
+	perWord := int(64 / bits)
+	return (size + perWord - 1) / perWord
+}
+
+// Export 返回调色板, 下标位数与下标数组的副本, 用于存档
+func (s *PalettedStorage) Export() (palette []BlockId, bits uint, data []uint64) {
+	palette = append([]BlockId(nil), s.palette...)
+	data = append([]uint64(nil), s.data...)
+	return palette, s.bits, data
+}
+
+// NewPalettedStorageFrom 由存档数据恢复调色板存储
+func NewPalettedStorageFrom(size int, palette []BlockId, bits uint, data []uint64) (*PalettedStorage, error) {
+	if len(palette) == 0 {
+		return nil, fmt.Errorf("empty palette")
+	}
+	if bits < bitsFor(len(palette)) || bits > 32 {
+		return nil, fmt.Errorf("invalid palette bits %d for %d entries", bits, len(palette))
+	}
+	if bits > 0 && len(data) != dataLen(size, bits) {
+		return nil, fmt.Errorf("invalid palette data length %d, want %d", len(data), dataLen(size, bits))
+	}
+
+	s := new(PalettedStorage)
+	s.size = size
+	s.palette = palette
+	s.bits = bits
+	if bits > 0 {
+		s.data = data
+	}
+
+	for i := 0; i < size && bits > 0; i++ {
+		if s.index(i) >= len(palette) {
+			return nil, fmt.Errorf("palette index out of range at %d", i)
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,129 @@
+package world
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// checkStorage 比较存储中的每个方块与参考值
+func checkStorage(t *testing.T, s *PalettedStorage, want []BlockId, step string) {
+	t.Helper()
+	for i, id := range want {
+		if got := s.Get(i); got != id {
+			t.Fatalf("%s: block %d is %d, want %d", step, i, got, id)
+		}
+	}
+}
+
+func TestPalettedStorageSet(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, size := range []int{1, 7, 64, 100, int(SECTION_SIZE * SECTION_SIZE * SECTION_SIZE)} {
+		// 1 种方块不分配下标, 之后依次需要 1、2、3、5、9 位
+		for _, kinds := range []int{1, 2, 3, 5, 17, 300} {
+			s := NewPalettedStorage(size)
+			want := make([]BlockId, size)
+			// 先保证每种方块都出现, 再随机覆盖
+			for i := 0; i < kinds; i++ {
+				p := i % size
+				s.Set(p, BlockId(i))
+				want[p] = BlockId(i)
+			}
+			for n := 0; n < size*2; n++ {
+				p, id := r.Intn(size), BlockId(r.Intn(kinds))
+				s.Set(p, id)
+				want[p] = id
+			}
+			checkStorage(t, s, want, "set")
+
+			if bits := bitsFor(len(s.Palette())); s.bits != bits {
+				t.Fatalf("size %d kinds %d: %d bits for %d palette entries, want %d", size, kinds, s.bits, len(s.Palette()), bits)
+			}
+
+			palette, bits, data := s.Export()
+			loaded, err := NewPalettedStorageFrom(size, palette, bits, data)
+			if err != nil {
+				t.Fatalf("size %d kinds %d: %v", size, kinds, err)
+			}
+			checkStorage(t, loaded, want, "export")
+
+			c := s.Clone()
+			s.Set(0, BlockId(kinds+1))
+			checkStorage(t, c, want, "clone")
+		}
+	}
+}
+
+func TestPalettedStorageCompact(t *testing.T) {
+	const size = 4096
+	s := NewPalettedStorage(size)
+	for i := 0; i < size; i++ {
+		s.Set(i, BlockId(i%20))
+	}
+	if s.bits != 5 {
+		t.Fatalf("%d bits for 20 blocks", s.bits)
+	}
+
+	// 只剩 3 种方块时缩小到 2 位
+	want := make([]BlockId, size)
+	for i := 0; i < size; i++ {
+		want[i] = BlockId(i%3 + 10)
+		s.Set(i, want[i])
+	}
+	s.Compact()
+	if len(s.Palette()) != 3 || s.bits != 2 {
+		t.Fatalf("compacted to %d entries %d bits", len(s.Palette()), s.bits)
+	}
+	checkStorage(t, s, want, "compact")
+
+	// 只剩一种方块时不再分配下标
+	for i := 0; i < size; i++ {
+		s.Set(i, BlockStone)
+	}
+	s.Compact()
+	if len(s.Palette()) != 1 || s.bits != 0 || s.data != nil || s.Get(size-1) != BlockStone {
+		t.Fatalf("compacted to palette %v bits %d data %d", s.Palette(), s.bits, len(s.data))
+	}
+
+	s.Fill(BlockAir)
+	if !s.IsEmpty() {
+		t.Fatal("storage filled with air is not empty")
+	}
+	s.Set(5, BlockStone)
+	if s.IsEmpty() || s.Get(5) != BlockStone || s.Get(4) != BlockAir {
+		t.Fatal("set after fill")
+	}
+}
+
+func TestPalettedStorageFromCorrupt(t *testing.T) {
+	const size = 64
+	full := make([]uint64, dataLen(size, 2))
+	for i := range full {
+		full[i] = ^uint64(0) // 每个下标都是 3
+	}
+
+	cases := []struct {
+		name    string
+		palette []BlockId
+		bits    uint
+		data    []uint64
+		err     string
+	}{
+		{"empty palette", nil, 0, nil, "empty palette"},
+		{"bits too small", []BlockId{1, 2, 3, 4, 5}, 2, make([]uint64, dataLen(size, 2)), "invalid palette bits"},
+		{"bits too large", []BlockId{1, 2}, 33, nil, "invalid palette bits"},
+		{"short data", []BlockId{1, 2}, 1, make([]uint64, dataLen(size, 1)-1), "invalid palette data length"},
+		{"index out of range", []BlockId{1, 2, 3}, 2, full, "index out of range"},
+	}
+	for _, c := range cases {
+		if _, err := NewPalettedStorageFrom(size, c.palette, c.bits, c.data); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: error %v, want %q", c.name, err, c.err)
+		}
+	}
+
+	// 只有一项的调色板不需要下标数组
+	s, err := NewPalettedStorageFrom(size, []BlockId{BlockStone}, 0, nil)
+	if err != nil || s.Get(size-1) != BlockStone {
+		t.Fatalf("single entry storage: %v", err)
+	}
+}
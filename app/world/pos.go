@@ -0,0 +1,102 @@
+package world
+
+import "fmt"
+
+type BlockFace int
+
+const (
+	BlockFaceNone   BlockFace = iota - 1
+	BlockFaceBack             // 后 0 (0,0,1)
+	BlockFaceFront            // 前 1 (0,0,-1)
+	BlockFaceTop              // 上 2 (0,1,0)
+	BlockFaceBottom           // 下 3 (0,-1,0)
+	BlockFaceRight            // 右 4 (1,0,0)
+	BlockFaceLeft             // 左 5 (-1,0,0)
+)
+
+type Pos struct {
+	X int64
+	Y int64
+	Z int64
+}
+
+func NewPos(x, y, z int64) Pos {
+	return Pos{X: x, Y: y, Z: z}
+}
+
+func (p Pos) GetId() string {
+	return fmt.Sprintf("%d-%d-%d", p.X, p.Y, p.Z)
+}
+
+func (p Pos) Add(pos Pos) Pos {
+	return Pos{
+		X: p.X + pos.X,
+		Y: p.Y + pos.Y,
+		Z: p.Z + pos.Z,
+	}
+}
+
+func (p Pos) AddX(x int64) Pos {
+	return p.Add(Pos{X: x})
+}
+
+func (p Pos) AddY(y int64) Pos {
+	return p.Add(Pos{Y: y})
+}
+
+func (p Pos) AddZ(z int64) Pos {
+	return p.Add(Pos{Z: z})
+}
+
+func (p Pos) SubX(x int64) Pos {
+	return p.Add(Pos{X: -x})
+}
+
+func (p Pos) SubY(y int64) Pos {
+	return p.Add(Pos{Y: -y})
+}
+
+func (p Pos) SubZ(z int64) Pos {
+	return p.Add(Pos{Z: -z})
+}
+
+// ChunkPos 所在区块坐标
+func (p Pos) ChunkPos() ChunkPos {
+	return ChunkPos{X: FloorDiv(p.X, CHUNK_WIDTH), Z: FloorDiv(p.Z, CHUNK_WIDTH)}
+}
+
+// RangeAdjoin 遍历相邻的六个方块, face 为当前方块朝向相邻方块的面
+func (p Pos) RangeAdjoin(fn func(pos Pos, face BlockFace)) {
+	fn(p.AddX(1), BlockFaceRight)
+	fn(p.SubX(1), BlockFaceLeft)
+
+	fn(p.AddY(1), BlockFaceTop)
+	fn(p.SubY(1), BlockFaceBottom)
+
+	fn(p.AddZ(1), BlockFaceBack)
+	fn(p.SubZ(1), BlockFaceFront)
+}
+
+// SectionPos 区段在世界中的位置, Index 为区段在区块中的下标
+type SectionPos struct {
+	Chunk ChunkPos
+	Index int
+}
+
+type ChunkPos struct {
+	X int64
+	Z int64
+}
+
+func (c ChunkPos) Id() string {
+	return fmt.Sprintf("%d-%d", c.X, c.Z)
+}
+
+// FloorDiv 向下取整的整数除法, -1 / 16 => -1
+func FloorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
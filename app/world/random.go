@@ -0,0 +1,20 @@
+package world
+
+import "math/rand"
+
+// ChunkRand 返回区块专用的随机数生成器, 结果只与世界种子、区块坐标和 salt 有关,
+// 同一区块重新生成时得到相同的序列. 不同的生成阶段应使用不同的 salt
+func ChunkRand(seed int64, cpos ChunkPos, salt uint64) *rand.Rand {
+	h := mix64(uint64(seed) ^ salt)
+	h = mix64(h ^ uint64(cpos.X))
+	h = mix64(h ^ uint64(cpos.Z))
+	return rand.New(rand.NewSource(int64(h)))
+}
+
+// mix64 splitmix64 的混合函数
+func mix64(h uint64) uint64 {
+	h += 0x9e3779b97f4a7c15
+	h = (h ^ h>>30) * 0xbf58476d1ce4e5b9
+	h = (h ^ h>>27) * 0x94d049bb133111eb
+	return h ^ h>>31
+}
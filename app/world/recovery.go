@@ -0,0 +1,156 @@
+package world
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	QUARANTINE_DIR = "quarantine"
+	RECOVERY_FILE  = "report.txt"
+
+	tempFileSuffix = ".tmp"
+)
+
+// RecoveryRecord 一条被隔离的存档数据, 对应的内容已丢失并被重新生成
+type RecoveryRecord struct {
+	Time   time.Time
+	Target string // 丢失的内容, 如 chunk 1-2、region r.0.0.region、level
+	File   string // 隔离后的文件, 移动失败时为空
+	Reason string
+}
+
+func (r RecoveryRecord) String() string {
+	file := r.File
+	if file == "" {
+		file = "-"
+	}
+	return fmt.Sprintf("%s\t%s\t%s\t%s", r.Time.Format(time.RFC3339), r.Target, file, r.Reason)
+}
+
+// writeFileAtomic 先写入同目录下的临时文件并落盘, 再重命名覆盖目标文件.
+// 任意时刻崩溃, path 要么是旧内容要么是新内容
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	f, err := ioutil.TempFile(dir, filepath.Base(path)+".*"+tempFileSuffix)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir 目录落盘, 保证重命名不会在崩溃后丢失.
+// 部分系统 (如 windows) 不支持目录落盘, 此时重命名已经完成, 忽略错误
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	d.Sync()
+}
+
+// removeTempFiles 删除上次崩溃时残留的临时文件
+func removeTempFiles(dir string) {
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+tempFileSuffix))
+	for _, file := range files {
+		os.Remove(file)
+	}
+}
+
+func (sm *fileSaveManager) quarantineDir() string {
+	return filepath.Join(sm.baseDir, QUARANTINE_DIR)
+}
+
+// quarantineFile 将损坏的文件移入隔离目录
+func (sm *fileSaveManager) quarantineFile(target, path string, reason error) {
+	dst := filepath.Join(sm.quarantineDir(), fmt.Sprintf("%s.%d", filepath.Base(path), time.Now().UnixNano()))
+	err := os.MkdirAll(sm.quarantineDir(), 0777)
+	if err == nil {
+		err = os.Rename(path, dst)
+	}
+	if err != nil {
+		sm.log.Error("quarantine %s fail: %v", path, err)
+		dst = ""
+	}
+
+	sm.record(target, dst, reason)
+}
+
+// quarantineData 将损坏的数据写入隔离目录
+func (sm *fileSaveManager) quarantineData(target, name string, data []byte, reason error) {
+	dst := filepath.Join(sm.quarantineDir(), fmt.Sprintf("%s.%d", name, time.Now().UnixNano()))
+	err := os.MkdirAll(sm.quarantineDir(), 0777)
+	if err == nil {
+		err = writeFileAtomic(dst, data, 0666)
+	}
+	if err != nil {
+		sm.log.Error("quarantine %s fail: %v", target, err)
+		dst = ""
+	}
+
+	sm.record(target, dst, reason)
+}
+
+// record 记录一条恢复信息, 同时追加到隔离目录下的报告文件
+func (sm *fileSaveManager) record(target, file string, reason error) {
+	rec := RecoveryRecord{Time: time.Now(), Target: target, File: file, Reason: reason.Error()}
+	sm.log.Warn("save data lost, %s", rec)
+
+	sm.recMu.Lock()
+	sm.records = append(sm.records, rec)
+	sm.recMu.Unlock()
+
+	if err := os.MkdirAll(sm.quarantineDir(), 0777); err != nil {
+		sm.log.Error("write recovery report fail: %v", err)
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(sm.quarantineDir(), RECOVERY_FILE), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		sm.log.Error("write recovery report fail: %v", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintln(f, rec); err != nil {
+		sm.log.Error("write recovery report fail: %v", err)
+	}
+}
+
+// RecoveryReport 本次运行中被隔离并重新生成的数据
+func (sm *fileSaveManager) RecoveryReport() []RecoveryRecord {
+	sm.recMu.Lock()
+	defer sm.recMu.Unlock()
+
+	return append([]RecoveryRecord(nil), sm.records...)
+}
@@ -0,0 +1,350 @@
+package world
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// 区域文件格式, 每个文件保存 REGION_SIZE×REGION_SIZE 个区块:
+//
+//	扇区 0: 偏移表, 每个区块 4 字节, 高 24 位为起始扇区, 低 8 位为扇区数, 0 表示区块不存在
+//	扇区 1: 时间戳表, 每个区块 4 字节, 最后一次保存的 unix 时间
+//	之后的扇区: 区块数据, 4 字节长度 (包含压缩类型) + 1 字节压缩类型 + 压缩后的数据
+const (
+	REGION_SIZE int64 = 32
+	SECTOR_SIZE int64 = 4096
+
+	regionChunks     = int(REGION_SIZE * REGION_SIZE)
+	regionHeaderSize = 2 * SECTOR_SIZE
+	chunkHeaderSize  = 5
+	maxChunkSectors  = 255
+)
+
+// Compression 区块数据的压缩方式
+type Compression byte
+
+const (
+	CompressionGzip Compression = 1
+	CompressionZlib Compression = 2
+	CompressionNone Compression = 3
+)
+
+var errChunkTooLarge = errors.New("chunk data exceeds region sector limit")
+
+// RegionPos 区域坐标
+type RegionPos struct {
+	X int64
+	Z int64
+}
+
+// RegionPosOf 区块所在的区域, 以及区块在区域内的序号
+func RegionPosOf(pos ChunkPos) (RegionPos, int) {
+	rp := RegionPos{X: FloorDiv(pos.X, REGION_SIZE), Z: FloorDiv(pos.Z, REGION_SIZE)}
+	lx, lz := pos.X-rp.X*REGION_SIZE, pos.Z-rp.Z*REGION_SIZE
+	return rp, int(lz*REGION_SIZE + lx)
+}
+
+// FileName 区域文件名
+func (p RegionPos) FileName() string {
+	return fmt.Sprintf("r.%d.%d.region", p.X, p.Z)
+}
+
+// regionFile 打开的区域文件, 不是并发安全的, 由调用方加锁
+type regionFile struct {
+	f           *os.File
+	compression Compression
+
+	offsets    [regionChunks]uint32
+	timestamps [regionChunks]uint32
+	used       []bool // 每个扇区是否已被占用
+}
+
+func openRegionFile(path string, compression Compression) (*regionFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &regionFile{f: f, compression: compression}
+	if err := r.init(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open region %s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+func (r *regionFile) init() error {
+	info, err := r.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+	if size == 0 {
+		// 新文件, 写入空的头部
+		if _, err := r.f.WriteAt(make([]byte, regionHeaderSize), 0); err != nil {
+			return err
+		}
+		size = regionHeaderSize
+	}
+	// 头部不完整的文件交给调用方隔离, 不能覆盖其中的数据
+	if size < regionHeaderSize {
+		return fmt.Errorf("region header truncated, file size %d", size)
+	}
+
+	header := make([]byte, regionHeaderSize)
+	if _, err := r.f.ReadAt(header, 0); err != nil {
+		return err
+	}
+
+	// 最后一个扇区不完整时补齐, 检查完头部之后再修改文件
+	sectors := (size + SECTOR_SIZE - 1) / SECTOR_SIZE
+	r.used = make([]bool, sectors)
+	r.used[0], r.used[1] = true, true
+	for i := 0; i < regionChunks; i++ {
+		r.offsets[i] = binary.BigEndian.Uint32(header[i*4:])
+		r.timestamps[i] = binary.BigEndian.Uint32(header[int(SECTOR_SIZE)+i*4:])
+
+		start, count := r.sectors(i)
+		if count == 0 {
+			continue
+		}
+		if start < 2 || start+count > len(r.used) {
+			return fmt.Errorf("chunk %d sectors [%d, %d) out of file range", i, start, start+count)
+		}
+		for s := start; s < start+count; s++ {
+			if r.used[s] {
+				return fmt.Errorf("chunk %d sector %d already used by another chunk", i, s)
+			}
+			r.used[s] = true
+		}
+	}
+
+	if size%SECTOR_SIZE != 0 {
+		return r.f.Truncate(sectors * SECTOR_SIZE)
+	}
+	return nil
+}
+
+func (r *regionFile) sectors(idx int) (start, count int) {
+	return int(r.offsets[idx] >> 8), int(r.offsets[idx] & 0xff)
+}
+
+// has 区块是否已保存
+func (r *regionFile) has(idx int) bool {
+	return r.offsets[idx] != 0
+}
+
+// timestamp 区块最后一次保存的时间
+func (r *regionFile) timestamp(idx int) time.Time {
+	return time.Unix(int64(r.timestamps[idx]), 0)
+}
+
+// read 读取并解压区块数据, 区块不存在时返回 nil
+func (r *regionFile) read(idx int) ([]byte, error) {
+	start, count := r.sectors(idx)
+	if count == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, int64(count)*SECTOR_SIZE)
+	if _, err := r.f.ReadAt(buf, int64(start)*SECTOR_SIZE); err != nil {
+		return nil, err
+	}
+
+	length := int(binary.BigEndian.Uint32(buf))
+	if length < 1 || length > len(buf)-4 {
+		return nil, fmt.Errorf("invalid chunk length %d in %d sectors", length, count)
+	}
+
+	return decompress(Compression(buf[4]), buf[chunkHeaderSize:4+length])
+}
+
+// write 压缩并写入区块数据.
+// 数据总是写入新分配的扇区, 落盘后才更新偏移表, 写入过程中崩溃时偏移表仍指向完整的旧数据
+func (r *regionFile) write(idx int, data []byte, ts time.Time) error {
+	payload, err := compress(r.compression, data)
+	if err != nil {
+		return err
+	}
+
+	total := int64(chunkHeaderSize + len(payload))
+	count := int((total + SECTOR_SIZE - 1) / SECTOR_SIZE)
+	if count > maxChunkSectors {
+		return errChunkTooLarge
+	}
+
+	buf := make([]byte, int64(count)*SECTOR_SIZE)
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)+1))
+	buf[4] = byte(r.compression)
+	copy(buf[chunkHeaderSize:], payload)
+
+	start := r.allocate(count)
+	if _, err := r.f.WriteAt(buf, int64(start)*SECTOR_SIZE); err != nil {
+		r.free(start, count)
+		return err
+	}
+	if err := r.f.Sync(); err != nil {
+		r.free(start, count)
+		return err
+	}
+
+	oldStart, oldCount := r.sectors(idx)
+	r.offsets[idx] = uint32(start)<<8 | uint32(count)
+	r.timestamps[idx] = uint32(ts.Unix())
+	if err := r.commitHeader(idx); err != nil {
+		return err
+	}
+
+	// 新的偏移表落盘后旧扇区才可以被复用
+	r.free(oldStart, oldCount)
+	return nil
+}
+
+// readRaw 读取区块占用的原始扇区, 不做校验, 用于隔离损坏的数据
+func (r *regionFile) readRaw(idx int) ([]byte, error) {
+	start, count := r.sectors(idx)
+	if count == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, int64(count)*SECTOR_SIZE)
+	n, err := r.f.ReadAt(buf, int64(start)*SECTOR_SIZE)
+	if err == io.EOF {
+		err = nil
+	}
+	return buf[:n], err
+}
+
+// remove 删除区块记录并释放其扇区
+func (r *regionFile) remove(idx int) error {
+	start, count := r.sectors(idx)
+	if count == 0 {
+		return nil
+	}
+
+	r.offsets[idx] = 0
+	r.timestamps[idx] = 0
+	if err := r.commitHeader(idx); err != nil {
+		return err
+	}
+
+	r.free(start, count)
+	return nil
+}
+
+// allocate 分配 count 个连续扇区, 返回起始扇区, 区块当前占用的扇区不会被分配
+func (r *regionFile) allocate(count int) int {
+	// 首次适配, 没有足够的空闲扇区时追加到文件末尾
+	start, run := 0, 0
+	for s := 2; s < len(r.used); s++ {
+		if r.used[s] {
+			run = 0
+			continue
+		}
+		if run == 0 {
+			start = s
+		}
+		run++
+		if run == count {
+			break
+		}
+	}
+	if run < count {
+		if run == 0 || start+run != len(r.used) {
+			start = len(r.used)
+		}
+		for len(r.used) < start+count {
+			r.used = append(r.used, false)
+		}
+	}
+
+	for s := start; s < start+count; s++ {
+		r.used[s] = true
+	}
+	return start
+}
+
+func (r *regionFile) free(start, count int) {
+	for s := start; s < start+count && s < len(r.used); s++ {
+		r.used[s] = false
+	}
+}
+
+// commitHeader 写入区块的偏移表与时间戳并落盘
+func (r *regionFile) commitHeader(idx int) error {
+	if err := r.writeHeader(idx); err != nil {
+		return err
+	}
+	return r.f.Sync()
+}
+
+func (r *regionFile) writeHeader(idx int) error {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], r.offsets[idx])
+	if _, err := r.f.WriteAt(b[:], int64(idx*4)); err != nil {
+		return err
+	}
+
+	binary.BigEndian.PutUint32(b[:], r.timestamps[idx])
+	_, err := r.f.WriteAt(b[:], SECTOR_SIZE+int64(idx*4))
+	return err
+}
+
+func (r *regionFile) close() error {
+	return r.f.Close()
+}
+
+func compress(c Compression, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch c {
+	case CompressionGzip:
+		w = gzip.NewWriter(&buf)
+	case CompressionZlib:
+		w = zlib.NewWriter(&buf)
+	case CompressionNone:
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown compression %d", c)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decompress(c Compression, data []byte) ([]byte, error) {
+	var rd io.ReadCloser
+	var err error
+	switch c {
+	case CompressionGzip:
+		rd, err = gzip.NewReader(bytes.NewReader(data))
+	case CompressionZlib:
+		rd, err = zlib.NewReader(bytes.NewReader(data))
+	case CompressionNone:
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown compression %d", c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+
+	return ioutil.ReadAll(rd)
+}
@@ -0,0 +1,114 @@
+package world
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// regionPath 测试存档中区域文件的路径
+func regionPath(dir string, rp RegionPos) string {
+	return filepath.Join(dir, "world", "w0", rp.FileName())
+}
+
+// saveChunks 保存生成的区块并关闭存档
+func saveChunks(t *testing.T, dir string, poses ...ChunkPos) {
+	t.Helper()
+	sm := newTestSaveManager(t, dir)
+	for _, pos := range poses {
+		sm.SaveChunk(generate(1, pos))
+	}
+	if err := sm.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkQuarantined 区块无法读取, 区域文件被移入隔离目录并记录
+func checkQuarantined(t *testing.T, dir string, pos ChunkPos, reason string) {
+	t.Helper()
+	sm := newTestSaveManager(t, dir)
+	defer sm.Close()
+
+	if data := sm.LoadChunk(pos); data != nil {
+		t.Fatalf("chunk %v loaded from a corrupt region", pos)
+	}
+	report := sm.RecoveryReport()
+	if len(report) != 1 || report[0].Target != "region r.0.0.region" || !strings.Contains(report[0].Reason, reason) {
+		t.Fatalf("recovery report %+v, want region r.0.0.region: %s", report, reason)
+	}
+	if _, err := os.Stat(report[0].File); err != nil {
+		t.Fatalf("quarantined region: %v", err)
+	}
+
+	// 重新保存时创建新的区域文件
+	sm.SaveChunk(generate(1, pos))
+	if data := sm.LoadChunk(pos); data == nil {
+		t.Fatal("chunk not saved after quarantine")
+	}
+}
+
+func TestRegionNewFile(t *testing.T) {
+	dir := tempDir(t)
+	path := filepath.Join(dir, RegionPos{}.FileName())
+	if err := ioutil.WriteFile(path, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := openRegionFile(path, CompressionZlib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.close()
+	if info, _ := os.Stat(path); info.Size() != regionHeaderSize {
+		t.Fatalf("new region size %d", info.Size())
+	}
+}
+
+func TestRegionTruncatedHeader(t *testing.T) {
+	dir := tempDir(t)
+	saveChunks(t, dir, ChunkPos{})
+
+	path := regionPath(dir, RegionPos{})
+	if err := os.Truncate(path, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	checkQuarantined(t, dir, ChunkPos{}, "header truncated")
+	if bs, err := ioutil.ReadFile(filepath.Join(dir, QUARANTINE_DIR, RECOVERY_FILE)); err != nil || !strings.Contains(string(bs), "r.0.0.region") {
+		t.Fatalf("report file %q, %v", bs, err)
+	}
+}
+
+func TestRegionOverlappingSectors(t *testing.T) {
+	dir := tempDir(t)
+	a, b := ChunkPos{X: 0, Z: 0}, ChunkPos{X: 1, Z: 0}
+	saveChunks(t, dir, a, b)
+
+	// 让 b 的偏移指向 a 的扇区
+	path := regionPath(dir, RegionPos{})
+	f, err := os.OpenFile(path, os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ia := RegionPosOf(a)
+	_, ib := RegionPosOf(b)
+	offset := make([]byte, 4)
+	if _, err := f.ReadAt(offset, int64(ia*4)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(offset, int64(ib*4)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if binary.BigEndian.Uint32(offset) == 0 {
+		t.Fatal("chunk a not saved")
+	}
+
+	if _, err := openRegionFile(path, CompressionZlib); err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Fatalf("open region with overlapping chunks: %v", err)
+	}
+	checkQuarantined(t, dir, b, "already used")
+}
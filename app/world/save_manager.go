@@ -0,0 +1,361 @@
+package world
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+type ISaveManager interface {
+	// LoadLevel 读取世界元数据, 新存档返回 nil
+	LoadLevel() *LevelData
+	SaveLevel(level *LevelData) error
+	// LoadPendingWrites 读取写入未加载区块而暂存的方块
+	LoadPendingWrites() []BlockWrite
+	SavePendingWrites(writes []BlockWrite) error
+	SaveChunk(c *Chunk) error
+	// LoadChunk 读取区块, 不存在或数据损坏时返回 nil, 损坏的数据会被隔离
+	LoadChunk(pos ChunkPos) *ChunkData
+	// Quarantine 隔离已保存但无法使用的区块数据
+	Quarantine(pos ChunkPos, reason error)
+	// RecoveryReport 本次运行中被隔离的数据
+	RecoveryReport() []RecoveryRecord
+	// Close 等待保存队列写完并关闭文件
+	Close() error
+}
+
+type fileSaveManager struct {
+	log      ILogger
+	ch       chan *ChunkData
+	done     chan struct{}
+	stopOnce sync.Once
+
+	baseDir  string
+	chunkDir string
+
+	// 打开的区域文件, 保存协程与加载区块的主线程共用
+	mu      sync.Mutex
+	regions map[RegionPos]*regionFile
+	// 已进入保存队列但还没有写入文件的区块, 读取时优先使用, 避免读到文件中的旧数据
+	queued map[ChunkPos]*ChunkData
+
+	recMu   sync.Mutex
+	records []RecoveryRecord
+}
+
+// NewFileSaveManager 创建以 baseDir 为根目录的文件存档
+func NewFileSaveManager(log ILogger, baseDir string) (ISaveManager, error) {
+	if log == nil {
+		log = nopLogger{}
+	}
+
+	sm := new(fileSaveManager)
+	sm.log = log
+
+	var err error
+	if sm.baseDir, err = filepath.Abs(baseDir); err != nil {
+		return nil, err
+	}
+	sm.chunkDir = filepath.Join(sm.baseDir, "world", "w0")
+	if err := os.MkdirAll(sm.chunkDir, 0777); err != nil {
+		return nil, err
+	}
+	sm.regions = make(map[RegionPos]*regionFile)
+	sm.queued = make(map[ChunkPos]*ChunkData)
+	removeTempFiles(sm.baseDir)
+
+	if err := sm.migrateWorld(); err != nil {
+		return nil, err
+	}
+
+	sm.ch = make(chan *ChunkData, 20)
+	sm.done = make(chan struct{})
+	sm.Start()
+	return sm, nil
+}
+
+func (sm *fileSaveManager) Start() {
+	go func() {
+		defer close(sm.done)
+
+		for data := range sm.ch {
+			sm.safeSaveChunk(data)
+		}
+	}()
+}
+
+func (sm *fileSaveManager) safeSaveChunk(data *ChunkData) {
+	defer func() {
+		if err := recover(); err != nil {
+			sm.log.Error("save chunk %s panic: %v", data.Pos.Id(), err)
+		}
+	}()
+
+	if err := sm.saveChunk(data); err != nil {
+		sm.log.Error("save chunk %s fail: %v", data.Pos.Id(), err)
+	}
+}
+
+func (sm *fileSaveManager) Stop() {
+	sm.stopOnce.Do(func() { close(sm.ch) })
+}
+
+// Close 等待保存队列中的区块写完, 然后关闭所有区域文件
+func (sm *fileSaveManager) Close() error {
+	sm.Stop()
+	<-sm.done
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	var err error
+	for pos, r := range sm.regions {
+		if e := r.close(); e != nil && err == nil {
+			err = e
+		}
+		delete(sm.regions, pos)
+	}
+	return err
+}
+
+// SaveChunk 保存区块的快照, 之后对区块的修改不影响本次保存
+func (sm *fileSaveManager) SaveChunk(c *Chunk) error {
+	c.Compact()
+	data := c.Snapshot().Data()
+
+	sm.mu.Lock()
+	sm.queued[data.Pos] = &data
+	sm.mu.Unlock()
+
+	sm.ch <- &data
+	return nil
+}
+
+func (sm *fileSaveManager) saveChunk(data *ChunkData) error {
+	bs, err := msgpack.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	// 同一区块之后又进入队列时保留较新的数据
+	if sm.queued[data.Pos] == data {
+		delete(sm.queued, data.Pos)
+	}
+
+	rp, idx := RegionPosOf(data.Pos)
+	r, err := sm.region(rp, true)
+	if err != nil {
+		// 无法打开的区域文件隔离后重新创建
+		sm.quarantineRegion(rp, err)
+		if r, err = sm.region(rp, true); err != nil {
+			return err
+		}
+	}
+
+	return r.write(idx, bs, time.Now())
+}
+
+func (sm *fileSaveManager) LoadChunk(pos ChunkPos) *ChunkData {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if data, ok := sm.queued[pos]; ok {
+		return sm.copyQueued(data)
+	}
+
+	rp, idx := RegionPosOf(pos)
+	r, err := sm.region(rp, false)
+	if err != nil {
+		sm.quarantineRegion(rp, err)
+		return nil
+	}
+	if r == nil || !r.has(idx) {
+		sm.log.Debug("chunk %s not exist", pos.Id())
+		return nil
+	}
+
+	var chunk ChunkData
+	data, err := r.read(idx)
+	if err == nil {
+		err = msgpack.Unmarshal(data, &chunk)
+	}
+	if err == nil {
+		err = MigrateChunk(&chunk)
+	}
+	if err != nil {
+		sm.quarantineChunk(r, pos, idx, err)
+		return nil
+	}
+
+	return &chunk
+}
+
+// copyQueued 复制队列中的区块数据, 保存协程可能同时在编码它, 读取的一方不能与它共用切片
+func (sm *fileSaveManager) copyQueued(data *ChunkData) *ChunkData {
+	bs, err := msgpack.Marshal(data)
+	if err != nil {
+		sm.log.Error("copy queued chunk %s fail: %v", data.Pos.Id(), err)
+		return nil
+	}
+
+	var chunk ChunkData
+	if err := msgpack.Unmarshal(bs, &chunk); err != nil {
+		sm.log.Error("copy queued chunk %s fail: %v", data.Pos.Id(), err)
+		return nil
+	}
+	return &chunk
+}
+
+func (sm *fileSaveManager) Quarantine(pos ChunkPos, reason error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	rp, idx := RegionPosOf(pos)
+	r, err := sm.region(rp, false)
+	if err != nil {
+		sm.quarantineRegion(rp, err)
+		return
+	}
+	if r == nil || !r.has(idx) {
+		return
+	}
+
+	sm.quarantineChunk(r, pos, idx, reason)
+}
+
+// quarantineChunk 将区块的原始数据复制到隔离目录, 并从区域文件中删除, 调用方需持有 mu
+func (sm *fileSaveManager) quarantineChunk(r *regionFile, pos ChunkPos, idx int, reason error) {
+	raw, err := r.readRaw(idx)
+	if err != nil {
+		sm.log.Error("read chunk %s for quarantine fail: %v", pos.Id(), err)
+	}
+	sm.quarantineData("chunk "+pos.Id(), fmt.Sprintf("%d_%d.chunk", pos.X, pos.Z), raw, reason)
+
+	if err := r.remove(idx); err != nil {
+		sm.log.Error("remove chunk %s from region fail: %v", pos.Id(), err)
+	}
+}
+
+// quarantineRegion 将无法打开的区域文件整个移入隔离目录, 调用方需持有 mu
+func (sm *fileSaveManager) quarantineRegion(rp RegionPos, reason error) {
+	if r, ok := sm.regions[rp]; ok {
+		r.close()
+		delete(sm.regions, rp)
+	}
+
+	sm.quarantineFile("region "+rp.FileName(), filepath.Join(sm.chunkDir, rp.FileName()), reason)
+}
+
+// region 返回打开的区域文件, create 为 false 且文件不存在时返回 nil
+func (sm *fileSaveManager) region(rp RegionPos, create bool) (*regionFile, error) {
+	if r, ok := sm.regions[rp]; ok {
+		return r, nil
+	}
+
+	path := filepath.Join(sm.chunkDir, rp.FileName())
+	if !create {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+
+	r, err := openRegionFile(path, CompressionZlib)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.regions[rp] = r
+	return r, nil
+}
+
+// migrateChunkFiles 将旧版每个区块一个文件的存档转换为区域文件, 转换成功的文件会被删除
+func (sm *fileSaveManager) migrateChunkFiles() error {
+	files, err := filepath.Glob(filepath.Join(sm.chunkDir, "*.chunk"))
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	sm.log.Info("migrating %d chunk files to region files", len(files))
+
+	var migrated int
+	for _, file := range files {
+		var pos ChunkPos
+		if _, err := fmt.Sscanf(filepath.Base(file), "%d_%d.chunk", &pos.X, &pos.Z); err != nil {
+			sm.log.Warn("skip chunk file:%s, %v", file, err)
+			continue
+		}
+
+		bs, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		var data ChunkData
+		if err := msgpack.Unmarshal(bs, &data); err != nil {
+			sm.quarantineFile("chunk "+pos.Id(), file, err)
+			continue
+		}
+		data.Pos = pos
+		if err := MigrateChunk(&data); err != nil {
+			sm.quarantineFile("chunk "+pos.Id(), file, err)
+			continue
+		}
+
+		if err := sm.saveChunk(&data); err != nil {
+			return err
+		}
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+		migrated++
+	}
+
+	sm.log.Info("migrated %d chunk files", migrated)
+	return nil
+}
+
+// loadFormatVersion 读取存档目录的版本, 没有版本文件的旧存档按目录内容判断
+func (sm *fileSaveManager) loadFormatVersion() (int, error) {
+	data, err := ioutil.ReadFile(sm.versionFileName())
+	if err == nil {
+		v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || v <= 0 {
+			return 0, fmt.Errorf("invalid world version file %s: %q", sm.versionFileName(), data)
+		}
+		return v, nil
+	}
+	if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	if files, _ := filepath.Glob(filepath.Join(sm.chunkDir, "*.chunk")); len(files) > 0 {
+		return 1, nil
+	}
+	if files, _ := filepath.Glob(filepath.Join(sm.chunkDir, "*.region")); len(files) > 0 {
+		return 2, nil
+	}
+	if _, err := os.Stat(filepath.Join(sm.baseDir, "seed")); err == nil {
+		return 2, nil
+	}
+
+	// 新存档
+	return WORLD_FORMAT_VERSION, sm.saveFormatVersion(WORLD_FORMAT_VERSION)
+}
+
+func (sm *fileSaveManager) saveFormatVersion(v int) error {
+	return writeFileAtomic(sm.versionFileName(), []byte(strconv.Itoa(v)), 0666)
+}
+
+func (sm *fileSaveManager) versionFileName() string {
+	return filepath.Join(sm.baseDir, "version")
+}
@@ -0,0 +1,66 @@
+package world
+
+// Section 区块中 16x16x16 的一段
+// 光照在整个区段相同时不分配光照数组
+type Section struct {
+	blocks *PalettedStorage
+	lums   []Luminance
+	lum    Luminance
+}
+
+func NewSection(lum Luminance) *Section {
+	s := new(Section)
+	s.blocks = NewPalettedStorage(SECTION_VOLUME)
+	s.lum = lum
+	return s
+}
+
+// clone 复制区段, 副本与原区段不共享任何数据
+func (s *Section) clone() *Section {
+	n := &Section{blocks: s.blocks.Clone(), lum: s.lum}
+	if s.lums != nil {
+		n.lums = append([]Luminance(nil), s.lums...)
+	}
+	return n
+}
+
+// Blocks 返回区段的方块存储
+func (s *Section) Blocks() *PalettedStorage {
+	return s.blocks
+}
+
+func (s *Section) GetBlock(x, y, z int64) BlockId {
+	return s.blocks.Get(sectionIndex(x, y, z))
+}
+
+func (s *Section) SetBlock(x, y, z int64, id BlockId) {
+	s.blocks.Set(sectionIndex(x, y, z), id)
+}
+
+func (s *Section) GetLum(x, y, z int64) Luminance {
+	if s.lums == nil {
+		return s.lum
+	}
+
+	return s.lums[sectionIndex(x, y, z)]
+}
+
+func (s *Section) SetLum(x, y, z int64, lum Luminance) {
+	if s.lums == nil {
+		if lum == s.lum {
+			return
+		}
+
+		s.lums = make([]Luminance, SECTION_VOLUME)
+		for i := range s.lums {
+			s.lums[i] = s.lum
+		}
+	}
+
+	s.lums[sectionIndex(x, y, z)] = lum
+}
+
+// sectionIndex 区段内坐标在存储中的下标, y 为区段内高度
+func sectionIndex(x, y, z int64) int {
+	return int((y*SECTION_SIZE+x)*SECTION_SIZE + z)
+}
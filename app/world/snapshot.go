@@ -0,0 +1,162 @@
+package world
+
+// ChunkSnapshot 区块某一时刻的只读副本, 可以在任意协程中读取, 不受之后修改的影响
+type ChunkSnapshot struct {
+	c *Chunk
+}
+
+// Snapshot 复制区块当前的方块与光照
+func (c *Chunk) Snapshot() *ChunkSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := NewChunk(c.pos.X, c.pos.Z, c.dim)
+	n.emptyLum = c.emptyLum
+	copy(n.biomes, c.biomes)
+	n.decorated = c.decorated
+	n.lightValid = c.lightValid
+	for i, s := range c.sections {
+		if s != nil {
+			n.sections[i] = s.clone()
+		}
+	}
+
+	return &ChunkSnapshot{c: n}
+}
+
+func (s *ChunkSnapshot) Pos() ChunkPos {
+	return s.c.pos
+}
+
+func (s *ChunkSnapshot) Dimension() Dimension {
+	return s.c.dim
+}
+
+func (s *ChunkSnapshot) SectionCount() int {
+	return len(s.c.sections)
+}
+
+// Section 返回第 i 个区段, 区段为空时返回 nil, 调用方不应修改返回值
+func (s *ChunkSnapshot) Section(i int) *Section {
+	return s.c.sections[i]
+}
+
+func (s *ChunkSnapshot) SectionMinY(i int) int64 {
+	return s.c.SectionMinY(i)
+}
+
+// GetBlock x z 为区块内坐标, y 为世界高度
+func (s *ChunkSnapshot) GetBlock(x, y, z int64) BlockId {
+	return s.c.getBlock(x, y, z)
+}
+
+// GetLum pos 为区块内坐标
+func (s *ChunkSnapshot) GetLum(pos Pos) Luminance {
+	return s.c.getLum(pos)
+}
+
+// Biome x z 为区块内坐标
+func (s *ChunkSnapshot) Biome(x, z int64) BiomeId {
+	return s.c.biome(x, z)
+}
+
+func (s *ChunkSnapshot) RangeBlocks(fn func(x, y, z int64, id BlockId)) {
+	s.c.rangeBlocks(fn)
+}
+
+// Data 生成存档数据
+func (s *ChunkSnapshot) Data() ChunkData {
+	return ConvertChunk(s.c)
+}
+
+// WorldSnapshot 世界中部分区块及时间的只读副本, 供存档、光照计算和网络同步等其它协程读取
+type WorldSnapshot struct {
+	dim      Dimension
+	curTime  int64
+	sunLevel uint8
+	chunks   map[ChunkPos]*ChunkSnapshot
+}
+
+// Snapshot 复制指定的已加载区块, 不指定时复制所有已加载区块, 未加载的区块被忽略.
+// 每个区块单独复制, 调用方需要跨区块一致时应在世界逻辑所在的协程中调用
+func (w *World) Snapshot(cposes ...ChunkPos) *WorldSnapshot {
+	w.mu.RLock()
+	ws := &WorldSnapshot{
+		dim:      w.dim,
+		curTime:  w.curTime,
+		sunLevel: w.sunLevel,
+		chunks:   make(map[ChunkPos]*ChunkSnapshot),
+	}
+
+	chunks := make([]*Chunk, 0, len(w.chunks))
+	if len(cposes) == 0 {
+		for _, c := range w.chunks {
+			chunks = append(chunks, c)
+		}
+	} else {
+		for _, cpos := range cposes {
+			if c, ok := w.chunks[cpos.Id()]; ok {
+				chunks = append(chunks, c)
+			}
+		}
+	}
+	w.mu.RUnlock()
+
+	for _, c := range chunks {
+		ws.chunks[c.pos] = c.Snapshot()
+	}
+
+	return ws
+}
+
+func (ws *WorldSnapshot) Dimension() Dimension {
+	return ws.dim
+}
+
+func (ws *WorldSnapshot) CurTime() int64 {
+	return ws.curTime
+}
+
+func (ws *WorldSnapshot) SunLevel() uint8 {
+	return ws.sunLevel
+}
+
+// Chunk 返回区块的快照, 不在快照中时返回 nil
+func (ws *WorldSnapshot) Chunk(cpos ChunkPos) *ChunkSnapshot {
+	return ws.chunks[cpos]
+}
+
+func (ws *WorldSnapshot) RangeChunks(fn func(c *ChunkSnapshot)) {
+	for _, c := range ws.chunks {
+		fn(c)
+	}
+}
+
+func (ws *WorldSnapshot) GetBlock(pos Pos) (id BlockId, chunkLoaded bool) {
+	c := ws.chunks[pos.ChunkPos()]
+	if c == nil {
+		return BlockAir, false
+	}
+
+	p := c.c.ConvertChunkPos(pos)
+	return c.c.getBlock(p.X, p.Y, p.Z), true
+}
+
+func (ws *WorldSnapshot) GetBiome(pos Pos) (id BiomeId, chunkLoaded bool) {
+	c := ws.chunks[pos.ChunkPos()]
+	if c == nil {
+		return BiomeNone, false
+	}
+
+	p := c.c.ConvertChunkPos(pos)
+	return c.c.biome(p.X, p.Z), true
+}
+
+func (ws *WorldSnapshot) GetLum(pos Pos) (lum Luminance, chunkLoaded bool) {
+	c := ws.chunks[pos.ChunkPos()]
+	if c == nil {
+		return 0, false
+	}
+
+	return c.c.getLum(c.c.ConvertChunkPos(pos)), true
+}
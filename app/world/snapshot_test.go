@@ -0,0 +1,100 @@
+package world
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestSaveWhileEditing 保存协程与修改方块的主线程同时运行, 需要用 go test -race 运行
+func TestSaveWhileEditing(t *testing.T) {
+	dir := tempDir(t)
+	w := newGeneratedWorld(t, 3)
+	sm := newTestSaveManager(t, dir)
+
+	cposes := []ChunkPos{{X: 0, Z: 0}, {X: 1, Z: 0}}
+	for _, cpos := range cposes {
+		c, _ := w.LoadChunk(cpos, sm)
+		w.AddChunk(c)
+	}
+
+	stop := make(chan struct{})
+	var g sync.WaitGroup
+	g.Add(2)
+	go func() {
+		defer g.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			w.RangeChunks(func(c *Chunk) { sm.SaveChunk(c) })
+		}
+	}()
+	go func() {
+		defer g.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			w.Snapshot().RangeChunks(func(c *ChunkSnapshot) { c.Data() })
+		}
+	}()
+
+	for i := 0; i < 2000; i++ {
+		p := NewPos(int64(i%32), int64(20+i%60), int64(i%7))
+		if i%2 == 0 {
+			w.SetBlock(p, BlockLamp)
+		} else {
+			w.SetBlock(p, BlockBrick)
+		}
+		w.Tick()
+	}
+	close(stop)
+	g.Wait()
+
+	// 最后一次保存的数据与内存中的区块一致
+	w.RangeChunks(func(c *Chunk) { sm.SaveChunk(c) })
+	if err := sm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sm = newTestSaveManager(t, dir)
+	defer sm.Close()
+	for _, cpos := range cposes {
+		data := sm.LoadChunk(cpos)
+		if data == nil {
+			t.Fatalf("chunk %v not saved", cpos)
+		}
+		loaded := NewChunk(cpos.X, cpos.Z, DefaultDimension)
+		if err := loaded.LoadFromData(*data); err != nil {
+			t.Fatal(err)
+		}
+		if d := diffChunk(w.Chunk(cpos), loaded); d != "" {
+			t.Fatalf("chunk %v differs at %s", cpos, d)
+		}
+	}
+}
+
+func TestSnapshotUnchanged(t *testing.T) {
+	w := newGeneratedWorld(t, 3)
+	c, _ := w.LoadChunk(ChunkPos{}, nil)
+	w.AddChunk(c)
+
+	s := w.Snapshot(ChunkPos{}, ChunkPos{X: 9, Z: 9})
+	if s.Chunk(ChunkPos{X: 9, Z: 9}) != nil || s.Chunk(ChunkPos{}) == nil {
+		t.Fatal("snapshot should only contain loaded chunks")
+	}
+
+	pos := NewPos(1, c.MaxY()-1, 1)
+	before, _ := s.GetBlock(pos)
+	w.SetBlock(pos, BlockBrick)
+	if after, _ := s.GetBlock(pos); after != before {
+		t.Fatal("snapshot changed after editing the chunk")
+	}
+	if data := s.Chunk(ChunkPos{}).Data(); len(data.Sections) == 0 {
+		t.Fatal("snapshot data has no sections")
+	}
+}
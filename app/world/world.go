@@ -0,0 +1,262 @@
+// Package world 游戏的数据部分: 方块 id、光照、区块管理、地形生成与存档.
+// 不依赖 g3n, 世界逻辑可以在测试和没有窗口的服务端进程中运行, app 包在它之上负责渲染.
+package world
+
+import "sync"
+
+const DAY_TOTAL_TIME int64 = 12000          // 每日时长
+const DAY_NIGHT_TRANSITION_TIME int64 = 600 // 昼夜交替过渡时长
+const MIN_SUN_LEVEL = 0                     // 最小阳光等级
+
+type World struct {
+	log ILogger
+	br  IBlockRegistry
+	wg  IWorldGenerator
+	dim Dimension
+
+	// mu 保护已加载区块表与时间, 区块自身的数据由区块的锁保护
+	mu       sync.RWMutex
+	sunLevel uint8
+	curTime  int64
+
+	chunks map[string]*Chunk
+	// lightMu 光照更新可以在后台协程中执行, 同一时间只允许一次
+	lightMu sync.Mutex
+	// 装饰物写入尚未加载的区块时暂存在这里
+	pending map[ChunkPos][]BlockWrite
+}
+
+func NewWorld(log ILogger, br IBlockRegistry, wg IWorldGenerator, dim Dimension) (*World, error) {
+	if err := dim.Validate(); err != nil {
+		return nil, err
+	}
+	if log == nil {
+		log = nopLogger{}
+	}
+
+	w := new(World)
+	w.log = log
+	w.br = br
+	w.wg = wg
+	w.dim = dim
+	w.sunLevel = MIN_SUN_LEVEL
+	w.chunks = make(map[string]*Chunk)
+	w.pending = make(map[ChunkPos][]BlockWrite)
+
+	return w, nil
+}
+
+func (w *World) WorldGenerator() IWorldGenerator {
+	return w.wg
+}
+
+func (w *World) Dimension() Dimension {
+	return w.dim
+}
+
+// LoadChunk 从存档读取区块, 存档中不存在时由生成器生成, generated 表示区块是新生成的. sm 可以为空
+func (w *World) LoadChunk(cpos ChunkPos, sm ISaveManager) (c *Chunk, generated bool) {
+	c = NewChunk(cpos.X, cpos.Z, w.dim)
+
+	if sm != nil {
+		if data := sm.LoadChunk(cpos); data != nil {
+			err := c.LoadFromData(*data)
+			if err == nil {
+				c.FillMissingBiomes(w.wg)
+				return c, false
+			}
+			w.log.Error("load chunk %v fail, regenerate: %v", cpos, err)
+			sm.Quarantine(cpos, err)
+			c = NewChunk(cpos.X, cpos.Z, w.dim)
+		}
+	}
+
+	c.Generate(w.wg)
+	return c, true
+}
+
+// AddChunk 将区块标记为已加载, 之后可通过世界坐标访问.
+// 随后写入暂存的方块, 并装饰周围已经满足条件的区块, 返回方块发生变化的区块, 这些区块的光照需要重新计算
+func (w *World) AddChunk(c *Chunk) []ChunkPos {
+	w.mu.Lock()
+	w.chunks[c.pos.Id()] = c
+	w.mu.Unlock()
+
+	changed := make(map[ChunkPos]bool)
+	if w.applyPendingWrites(c) {
+		changed[c.pos] = true
+	}
+	w.decorateAround(c.pos, changed)
+
+	// 写入的方块没有经过光照计算
+	for cpos := range changed {
+		if cc := w.Chunk(cpos); cc != nil {
+			cc.InvalidateLight()
+		}
+	}
+
+	return chunkPosList(changed)
+}
+
+func (w *World) RemoveChunk(cpos ChunkPos) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.chunks, cpos.Id())
+}
+
+func (w *World) Chunk(cpos ChunkPos) *Chunk {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.chunks[cpos.Id()]
+}
+
+func (w *World) ChunkAt(pos Pos) *Chunk {
+	return w.Chunk(pos.ChunkPos())
+}
+
+// RangeChunks 遍历调用时已加载的区块, fn 中可以加载或移除区块
+func (w *World) RangeChunks(fn func(c *Chunk)) {
+	w.mu.RLock()
+	chunks := make([]*Chunk, 0, len(w.chunks))
+	for _, c := range w.chunks {
+		chunks = append(chunks, c)
+	}
+	w.mu.RUnlock()
+
+	for _, c := range chunks {
+		fn(c)
+	}
+}
+
+func (w *World) GetBlock(pos Pos) (id BlockId, chunkLoaded bool) {
+	c := w.ChunkAt(pos)
+	if c == nil {
+		return BlockAir, false
+	}
+
+	p := c.ConvertChunkPos(pos)
+	return c.GetBlock(p.X, p.Y, p.Z), true
+}
+
+func (w *World) SetBlock(pos Pos, id BlockId) bool {
+	c := w.ChunkAt(pos)
+	if c == nil {
+		return false
+	}
+
+	p := c.ConvertChunkPos(pos)
+	return c.SetBlock(p.X, p.Y, p.Z, id)
+}
+
+// GetBiome 返回坐标所在列的群系
+func (w *World) GetBiome(pos Pos) (id BiomeId, chunkLoaded bool) {
+	c := w.ChunkAt(pos)
+	if c == nil {
+		return BiomeNone, false
+	}
+
+	p := c.ConvertChunkPos(pos)
+	return c.Biome(p.X, p.Z), true
+}
+
+func (w *World) GetLum(pos Pos) (lum Luminance, chunkLoaded bool) {
+	c := w.ChunkAt(pos)
+	if c == nil {
+		return 0, false
+	}
+
+	return c.GetLum(c.ConvertChunkPos(pos)), true
+}
+
+func (w *World) SetLum(pos Pos, lum Luminance) {
+	c := w.ChunkAt(pos)
+	if c == nil {
+		return
+	}
+
+	c.SetLum(c.ConvertChunkPos(pos), lum)
+}
+
+func (w *World) CurTime() int64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.curTime
+}
+
+// SetCurTime 设置当天的时间, 用于恢复存档, 阳光等级随之更新
+func (w *World) SetCurTime(t int64) {
+	if t < 0 || t > DAY_TOTAL_TIME {
+		t = 0
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.curTime = t
+	w.sunLevel = w.calSunLevel()
+}
+
+func (w *World) SunLevel() uint8 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.sunLevel
+}
+
+// Tick 推进一个时间单位, 返回阳光等级是否发生变化
+func (w *World) Tick() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.curTime += 1
+	if w.curTime > DAY_TOTAL_TIME {
+		w.curTime = 0
+	}
+
+	newSunLevel := w.calSunLevel()
+	if w.sunLevel == newSunLevel {
+		return false
+	}
+
+	w.log.Debug("sun level update: t:%v %v -> %v", w.curTime, w.sunLevel, newSunLevel)
+	w.sunLevel = newSunLevel
+	return true
+}
+
+// calSunLevel 当前时间对应的阳光等级, 调用方需要持有 mu
+func (w *World) calSunLevel() uint8 {
+	tHalf := DAY_NIGHT_TRANSITION_TIME / 2
+	dawnStart := DAY_TOTAL_TIME/2 - DAY_NIGHT_TRANSITION_TIME
+	dawnEnd := DAY_TOTAL_TIME/2 - tHalf
+	duskStart := DAY_TOTAL_TIME - DAY_NIGHT_TRANSITION_TIME
+	duskEnd := DAY_TOTAL_TIME
+
+	speed := 2
+	stepTime := DAY_NIGHT_TRANSITION_TIME / (int64(MAX_LUM - MIN_SUN_LEVEL)) * int64(speed)
+
+	if w.curTime >= 0 && w.curTime < dawnStart {
+		return MIN_SUN_LEVEL
+	} else if w.curTime >= dawnStart && w.curTime < dawnEnd {
+		addSun := (w.curTime - dawnStart) / stepTime
+		return MIN_SUN_LEVEL + uint8(addSun)*uint8(speed)
+	} else if w.curTime >= dawnEnd && w.curTime < duskStart {
+		return MAX_LUM
+	} else if w.curTime >= duskStart && w.curTime <= duskEnd {
+		addSun := (w.curTime - duskStart) / stepTime
+		return MAX_LUM - uint8(addSun)*uint8(speed)
+	}
+
+	return MAX_LUM
+}
+
+func (w *World) SunLumRate() float32 {
+	return float32(w.SunLevel()) / float32(MAX_LUM)
+}
+
+// PosOverRange 坐标是否超出世界高度范围
+func (w *World) PosOverRange(pos Pos) bool {
+	return !w.dim.InRange(pos.Y)
+}
@@ -0,0 +1,215 @@
+package world
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// testRegistry 测试用的方块属性, 只有灯发光, 树叶、草和水透明, 草是可以穿过的交叉平面
+type testRegistry struct{}
+
+func (testRegistry) GetBlockLight(id BlockId) LightColor {
+	if id == BlockLamp {
+		return LightColor{R: 15, G: 10, B: 4}
+	}
+	return LightColor{}
+}
+
+func (testRegistry) IsTransparent(id BlockId) bool {
+	return id == BlockLeaves || id == BlockTallGrass || id == BlockWater
+}
+
+func (testRegistry) IsSolid(id BlockId) bool { return id != BlockTallGrass }
+
+func (testRegistry) GetRenderType(id BlockId) RenderType {
+	if id == BlockTallGrass {
+		return RenderCross
+	}
+	return RenderCube
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "mcworld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func newTestWorld(t *testing.T, wg IWorldGenerator) *World {
+	w, err := NewWorld(nil, testRegistry{}, wg, DefaultDimension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// newGeneratedWorld 使用默认地形生成器的测试世界
+func newGeneratedWorld(t *testing.T, seed int64) *World {
+	wg := new(WorldGenerator)
+	wg.Setup(seed)
+	return newTestWorld(t, wg)
+}
+
+func newTestSaveManager(t *testing.T, dir string) ISaveManager {
+	sm, err := NewFileSaveManager(nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sm
+}
+
+func generate(seed int64, cpos ChunkPos) *Chunk {
+	wg := new(WorldGenerator)
+	wg.Setup(seed)
+	c := NewChunk(cpos.X, cpos.Z, DefaultDimension)
+	c.Generate(wg)
+	return c
+}
+
+// diffChunk 返回两个区块第一个不同的方块或群系, 相同时返回空串
+func diffChunk(a, b *Chunk) string {
+	for x := int64(0); x < CHUNK_WIDTH; x++ {
+		for z := int64(0); z < CHUNK_WIDTH; z++ {
+			if a.Biome(x, z) != b.Biome(x, z) {
+				return fmt.Sprintf("biome %d,%d", x, z)
+			}
+			for y := a.MinY(); y < a.MaxY(); y++ {
+				if a.GetBlock(x, y, z) != b.GetBlock(x, y, z) {
+					return fmt.Sprintf("block %d,%d,%d", x, y, z)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func TestGeneratorDeterministic(t *testing.T) {
+	for _, cpos := range []ChunkPos{{X: 0, Z: 0}, {X: -3, Z: 7}, {X: 120, Z: -45}} {
+		if d := diffChunk(generate(42, cpos), generate(42, cpos)); d != "" {
+			t.Errorf("chunk %v differs with the same seed at %s", cpos, d)
+		}
+	}
+
+	if diffChunk(generate(42, ChunkPos{}), generate(43, ChunkPos{})) == "" {
+		t.Error("different seeds generated the same chunk")
+	}
+}
+
+func TestChunkSaveLoad(t *testing.T) {
+	dir := tempDir(t)
+	cpos := ChunkPos{X: 2, Z: -3}
+
+	c := generate(7, cpos)
+	c.SetBlock(1, c.MinY()+2, 1, BlockLamp)
+	c.SetBlock(1, c.MaxY()-1, 1, BlockBrick)
+
+	sm := newTestSaveManager(t, dir)
+	if err := sm.SaveChunk(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sm = newTestSaveManager(t, dir)
+	defer sm.Close()
+
+	data := sm.LoadChunk(cpos)
+	if data == nil {
+		t.Fatal("saved chunk not found")
+	}
+	loaded := NewChunk(cpos.X, cpos.Z, DefaultDimension)
+	if err := loaded.LoadFromData(*data); err != nil {
+		t.Fatal(err)
+	}
+	if d := diffChunk(c, loaded); d != "" {
+		t.Fatalf("loaded chunk differs at %s", d)
+	}
+	if sm.LoadChunk(ChunkPos{X: 40, Z: 40}) != nil {
+		t.Fatal("unsaved chunk should be nil")
+	}
+}
+
+func TestChunkSaveLoadLight(t *testing.T) {
+	wg, err := NewGenerator(SUPERFLAT_GENERATOR, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newTestWorld(t, wg)
+	c, _ := w.LoadChunk(ChunkPos{}, nil)
+	w.AddChunk(c)
+	w.InitChunkLum(c.Pos())
+
+	data := ConvertChunk(c)
+	loaded := NewChunk(0, 0, DefaultDimension)
+	if err := loaded.LoadFromData(data); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.LightValid() {
+		t.Fatal("saved light should be valid")
+	}
+	for y := c.MinY(); y < c.MaxY(); y++ {
+		p := NewPos(5, y, 9)
+		if c.GetLum(p) != loaded.GetLum(p) {
+			t.Fatalf("light at %v: %v, want %v", p, loaded.GetLum(p), c.GetLum(p))
+		}
+	}
+}
+
+func TestMigrateChunk(t *testing.T) {
+	// 版本 1: 没有版本号, 按方块保存, 高度从 0 开始
+	data := ChunkData{
+		Pos: ChunkPos{X: 3, Z: -2},
+		Data: map[cPos]BlockData{
+			cPos(70<<8 | 1<<4 | 2): {Id: BlockLamp},
+			cPos(17<<8 | 5<<4 | 5): {Id: BlockGrass},
+		},
+	}
+	if err := MigrateChunk(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Version != CHUNK_DATA_VERSION || data.Data != nil {
+		t.Fatalf("version %d, legacy data %v", data.Version, data.Data)
+	}
+	if !data.Decorated || data.LightValid {
+		t.Fatalf("decorated %v light valid %v", data.Decorated, data.LightValid)
+	}
+
+	c := NewChunk(3, -2, DefaultDimension)
+	if err := c.LoadFromData(data); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetBlock(1, 70, 2) != BlockLamp || c.GetBlock(5, 17, 5) != BlockGrass || c.GetBlock(5, 16, 5) != BlockAir {
+		t.Fatal("blocks lost in migration")
+	}
+
+	newer := ChunkData{Version: CHUNK_DATA_VERSION + 1}
+	var nv *ErrNewerVersion
+	if err := MigrateChunk(&newer); !errors.As(err, &nv) {
+		t.Fatalf("newer chunk: %v", err)
+	}
+}
+
+func TestWorldFormatVersion(t *testing.T) {
+	dir := tempDir(t)
+	newTestSaveManager(t, dir).Close()
+
+	v, err := ioutil.ReadFile(filepath.Join(dir, "version"))
+	if err != nil || string(v) != strconv.Itoa(WORLD_FORMAT_VERSION) {
+		t.Fatalf("version %q, %v", v, err)
+	}
+
+	// 更新的程序保存的存档被拒绝
+	ioutil.WriteFile(filepath.Join(dir, "version"), []byte(strconv.Itoa(WORLD_FORMAT_VERSION+1)), 0666)
+	var nv *ErrNewerVersion
+	if _, err := NewFileSaveManager(nil, dir); !errors.As(err, &nv) {
+		t.Fatalf("newer world: %v", err)
+	}
+}
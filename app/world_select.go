@@ -0,0 +1,303 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+	"github.com/weiWang95/mcworld/app/saves"
+	"github.com/weiWang95/mcworld/app/world"
+)
+
+var screenColor = math32.Color4{0.1, 0.1, 0.1, 0.9}
+var errorTextColor = math32.Color4{1, 0.4, 0.4, 1}
+
+// WorldSelectScreen 进入游戏前选择、创建和管理世界的界面
+type WorldSelectScreen struct {
+	*gui.Panel
+
+	lib     *saves.Library
+	presets []world.GeneratorPreset
+	onPlay  func(id string) error
+
+	list   *gui.List
+	items  []saves.Summary
+	name   *gui.Edit
+	seed   *gui.Edit
+	preset *gui.Button
+	status *gui.Label
+
+	presetIdx int // 创建世界时使用的预设, 没有预设时使用默认生成器
+
+	pendingDelete string // 再次点击删除时确认删除的世界
+}
+
+func NewWorldSelectScreen(lib *saves.Library, presets []world.GeneratorPreset, onPlay func(id string) error) *WorldSelectScreen {
+	s := new(WorldSelectScreen)
+	s.lib = lib
+	s.presets = presets
+	s.onPlay = onPlay
+
+	s.init()
+	s.refresh("")
+
+	return s
+}
+
+func (s *WorldSelectScreen) GetPanel() gui.IPanel {
+	return s.Panel
+}
+
+func (s *WorldSelectScreen) init() {
+	s.Panel = gui.NewPanel(800, 600)
+	s.SetColor4(&screenColor)
+	s.SetPaddings(20, 20, 20, 20)
+	layout := gui.NewVBoxLayout()
+	layout.SetSpacing(8)
+	s.SetLayout(layout)
+
+	title := newDefaultLabel("Select World")
+	title.SetFontSize(20)
+	s.Add(title)
+
+	s.list = gui.NewVList(500, 300)
+	s.list.SetSingle(true)
+	s.list.Subscribe(gui.OnChange, func(evname string, ev interface{}) {
+		s.pendingDelete = ""
+		if sel, ok := s.selected(); ok {
+			s.name.SetText(sel.Name)
+		}
+	})
+	s.Add(s.list)
+
+	row := newSelectRow()
+	row.Add(newDefaultLabel("Name:"))
+	s.name = gui.NewEdit(200, "world name")
+	row.Add(s.name)
+	row.Add(newDefaultLabel("Seed:"))
+	s.seed = gui.NewEdit(160, "random")
+	row.Add(s.seed)
+	s.preset = s.newButton("", s.nextPreset)
+	s.updatePresetText()
+	row.Add(s.preset)
+	s.Add(row)
+
+	buttons := newSelectRow()
+	buttons.Add(s.newButton("Play", s.play))
+	buttons.Add(s.newButton("Create", s.create))
+	buttons.Add(s.newButton("Rename", s.rename))
+	buttons.Add(s.newButton("Duplicate", s.duplicate))
+	buttons.Add(s.newButton("Delete", s.delete))
+	s.Add(buttons)
+
+	s.status = newDefaultLabel(" ")
+	s.Add(s.status)
+}
+
+func newSelectRow() *gui.Panel {
+	row := gui.NewPanel(500, 28)
+	layout := gui.NewHBoxLayout()
+	layout.SetSpacing(6)
+	row.SetLayout(layout)
+	return row
+}
+
+func (s *WorldSelectScreen) newButton(text string, action func()) *gui.Button {
+	b := gui.NewButton(text)
+	b.Subscribe(gui.OnClick, func(evname string, ev interface{}) {
+		action()
+	})
+	return b
+}
+
+// refresh 重新读取世界列表, 并选中 id 对应的世界, id 为空时选中第一个
+func (s *WorldSelectScreen) refresh(id string) {
+	s.pendingDelete = ""
+	s.list.Clear()
+
+	items, err := s.lib.List()
+	if err != nil {
+		s.setError(err)
+		return
+	}
+	s.items = items
+
+	sel := 0
+	for i, item := range items {
+		s.list.Add(newDefaultLabel(formatWorldSummary(item)))
+		if item.Id == id {
+			sel = i
+		}
+	}
+	if len(items) > 0 {
+		s.list.SelectPos(sel, true)
+	}
+}
+
+func formatWorldSummary(item saves.Summary) string {
+	if item.Err != nil {
+		return fmt.Sprintf("%s  [unreadable: %v]", item.Name, item.Err)
+	}
+	text := fmt.Sprintf("%s  (%s)  last played %s", item.Name, item.Id, item.LastPlayed.Format("2006-01-02 15:04"))
+	if item.Generator != "" && item.Generator != world.DEFAULT_GENERATOR {
+		text += "  " + item.Generator
+	}
+	return text
+}
+
+func (s *WorldSelectScreen) selected() (saves.Summary, bool) {
+	sel := s.list.Selected()
+	if len(sel) == 0 {
+		return saves.Summary{}, false
+	}
+
+	pos := s.list.ItemPosition(sel[0])
+	if pos < 0 || pos >= len(s.items) {
+		return saves.Summary{}, false
+	}
+	return s.items[pos], true
+}
+
+func (s *WorldSelectScreen) setStatus(text string) {
+	s.status.SetColor4(&lightTextColor)
+	s.status.SetText(text)
+}
+
+func (s *WorldSelectScreen) setError(err error) {
+	s.status.SetColor4(&errorTextColor)
+	s.status.SetText(err.Error())
+}
+
+func (s *WorldSelectScreen) play() {
+	sel, ok := s.selected()
+	if !ok {
+		s.setStatus("select a world first")
+		return
+	}
+
+	if err := s.onPlay(sel.Id); err != nil {
+		s.setError(err)
+	}
+}
+
+func (s *WorldSelectScreen) create() {
+	seed := time.Now().Unix()
+	if text := strings.TrimSpace(s.seed.Text()); text != "" {
+		v, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			// 非数字的种子取字符串哈希
+			v = stringSeed(text)
+		}
+		seed = v
+	}
+
+	name := strings.TrimSpace(s.name.Text())
+	if name == "" {
+		name = world.DEFAULT_WORLD_NAME
+	}
+
+	opts := saves.CreateOptions{Name: name, Seed: seed}
+	if s.presetIdx < len(s.presets) {
+		opts.Generator = s.presets[s.presetIdx].Generator
+		opts.GeneratorOptions = s.presets[s.presetIdx].Options
+	}
+
+	item, err := s.lib.Create(opts)
+	if err != nil {
+		s.setError(err)
+		return
+	}
+
+	s.refresh(item.Id)
+	s.setStatus(fmt.Sprintf("created %s, seed %d, generator %s", item.Name, item.Seed, item.Generator))
+}
+
+// nextPreset 切换创建世界时使用的预设
+func (s *WorldSelectScreen) nextPreset() {
+	if len(s.presets) == 0 {
+		return
+	}
+	s.presetIdx = (s.presetIdx + 1) % len(s.presets)
+	s.updatePresetText()
+}
+
+func (s *WorldSelectScreen) updatePresetText() {
+	name := world.DEFAULT_GENERATOR
+	if s.presetIdx < len(s.presets) {
+		name = s.presets[s.presetIdx].Name
+	}
+	s.preset.Label.SetText("Type: " + name)
+}
+
+func (s *WorldSelectScreen) rename() {
+	sel, ok := s.selected()
+	if !ok {
+		s.setStatus("select a world first")
+		return
+	}
+
+	if err := s.lib.Rename(sel.Id, s.name.Text()); err != nil {
+		s.setError(err)
+		return
+	}
+
+	s.refresh(sel.Id)
+	s.setStatus("renamed")
+}
+
+func (s *WorldSelectScreen) duplicate() {
+	sel, ok := s.selected()
+	if !ok {
+		s.setStatus("select a world first")
+		return
+	}
+
+	name := strings.TrimSpace(s.name.Text())
+	if name == "" || name == sel.Name {
+		name = sel.Name + " copy"
+	}
+
+	item, err := s.lib.Duplicate(sel.Id, name)
+	if err != nil {
+		s.setError(err)
+		return
+	}
+
+	s.refresh(item.Id)
+	s.setStatus(fmt.Sprintf("duplicated as %s", item.Name))
+}
+
+func (s *WorldSelectScreen) delete() {
+	sel, ok := s.selected()
+	if !ok {
+		s.setStatus("select a world first")
+		return
+	}
+
+	if s.pendingDelete != sel.Id {
+		s.pendingDelete = sel.Id
+		s.setStatus(fmt.Sprintf("click Delete again to delete %s permanently", sel.Name))
+		return
+	}
+
+	if err := s.lib.Delete(sel.Id); err != nil {
+		s.setError(err)
+		return
+	}
+
+	s.refresh("")
+	s.setStatus(fmt.Sprintf("deleted %s", sel.Name))
+}
+
+// stringSeed 字符串种子的哈希值 (FNV-1a)
+func stringSeed(text string) int64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(text); i++ {
+		h ^= uint64(text[i])
+		h *= 1099511628211
+	}
+	return int64(h)
+}
@@ -0,0 +1,140 @@
+package perlin
+
+import "math"
+
+// CellularReturn selects what Cellular.Noise2D and Noise3D return
+type CellularReturn uint8
+
+const (
+	// CellularF1 distance to the closest feature point
+	CellularF1 CellularReturn = iota
+	// CellularF2 distance to the second closest feature point
+	CellularF2
+	// CellularF2MinusF1 is 0 on the cell borders, giving a network of ridges
+	CellularF2MinusF1
+	// CellularCellValue a random value in [-1, 1] that is constant over each cell
+	CellularCellValue
+)
+
+// CellularDistance is the metric used to measure feature point distances
+type CellularDistance uint8
+
+const (
+	DistanceEuclidean CellularDistance = iota
+	DistanceManhattan
+	DistanceChebyshev
+)
+
+// Cellular (Worley) noise scatters one feature point in every lattice cell
+// and measures the distance to the closest ones. Distances are returned
+// unscaled, F1 is at most about 1 with the default jitter.
+// Safe for concurrent use, the fields must not change while sampling.
+type Cellular struct {
+	seed int64
+
+	// Jitter how far feature points move from the cell center, 0 gives a regular grid, at most 1
+	Jitter   float64
+	Distance CellularDistance
+	Return   CellularReturn
+}
+
+// NewCellular creates a cellular noise generator returning F1 euclidean distances
+func NewCellular(seed int64) *Cellular {
+	return &Cellular{seed: seed, Jitter: 1}
+}
+
+// Cell2D returns the closest and second closest feature point distances and
+// a hash identifying the cell of the closest point
+func (c *Cellular) Cell2D(x, y float64) (f1, f2 float64, id uint64) {
+	xb, yb := fastFloor(x), fastFloor(y)
+	jitter := clamp(c.Jitter, 0, 1) * 0.5
+
+	f1, f2 = math.Inf(1), math.Inf(1)
+	for cx := xb - 1; cx <= xb+1; cx++ {
+		for cy := yb - 1; cy <= yb+1; cy++ {
+			h := hash2(c.seed, uint64(cx)*primeX, uint64(cy)*primeY)
+			h2 := rehash(h)
+			dx := float64(cx) + 0.5 + unit(h)*jitter - x
+			dy := float64(cy) + 0.5 + unit(h2)*jitter - y
+
+			d := c.distance(dx, dy, 0)
+			if d < f1 {
+				f1, f2, id = d, f1, h
+			} else if d < f2 {
+				f2 = d
+			}
+		}
+	}
+
+	return f1, f2, id
+}
+
+// Cell3D returns the closest and second closest feature point distances and
+// a hash identifying the cell of the closest point
+func (c *Cellular) Cell3D(x, y, z float64) (f1, f2 float64, id uint64) {
+	xb, yb, zb := fastFloor(x), fastFloor(y), fastFloor(z)
+	jitter := clamp(c.Jitter, 0, 1) * 0.5
+
+	f1, f2 = math.Inf(1), math.Inf(1)
+	for cx := xb - 1; cx <= xb+1; cx++ {
+		for cy := yb - 1; cy <= yb+1; cy++ {
+			for cz := zb - 1; cz <= zb+1; cz++ {
+				h := hash3(c.seed, uint64(cx)*primeX, uint64(cy)*primeY, uint64(cz)*primeZ)
+				h2 := rehash(h)
+				h3 := rehash(h2)
+				dx := float64(cx) + 0.5 + unit(h)*jitter - x
+				dy := float64(cy) + 0.5 + unit(h2)*jitter - y
+				dz := float64(cz) + 0.5 + unit(h3)*jitter - z
+
+				d := c.distance(dx, dy, dz)
+				if d < f1 {
+					f1, f2, id = d, f1, h
+				} else if d < f2 {
+					f2 = d
+				}
+			}
+		}
+	}
+
+	return f1, f2, id
+}
+
+// Noise2D generates 2-dimensional cellular noise, see Return
+func (c *Cellular) Noise2D(x, y float64) float64 {
+	return c.result(c.Cell2D(x, y))
+}
+
+// Noise3D generates 3-dimensional cellular noise, see Return
+func (c *Cellular) Noise3D(x, y, z float64) float64 {
+	return c.result(c.Cell3D(x, y, z))
+}
+
+func (c *Cellular) result(f1, f2 float64, id uint64) float64 {
+	switch c.Return {
+	case CellularF2:
+		return f2
+	case CellularF2MinusF1:
+		return f2 - f1
+	case CellularCellValue:
+		return unit(rehash(id))
+	default:
+		return f1
+	}
+}
+
+func (c *Cellular) distance(dx, dy, dz float64) float64 {
+	switch c.Distance {
+	case DistanceManhattan:
+		return math.Abs(dx) + math.Abs(dy) + math.Abs(dz)
+	case DistanceChebyshev:
+		return math.Max(math.Abs(dx), math.Max(math.Abs(dy), math.Abs(dz)))
+	default:
+		return math.Sqrt(dx*dx + dy*dy + dz*dz)
+	}
+}
+
+// rehash derives another independent hash from h
+func rehash(h uint64) uint64 {
+	h *= hashMultiplier
+	return h ^ h>>29
+}
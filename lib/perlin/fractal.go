@@ -0,0 +1,140 @@
+package perlin
+
+import "math"
+
+// octaveOffset shifts every octave so they do not all share the lattice
+// origin, where gradient noise is always 0
+const octaveOffset = 71.37
+
+// FBM fractional Brownian motion, sums Octaves layers of Source with
+// increasing frequency and decreasing amplitude. The sum is divided by the
+// total amplitude, so the output keeps the range of Source.
+type FBM struct {
+	Source  INoise
+	Octaves int
+	// Lacunarity frequency multiplier between octaves, typically 2
+	Lacunarity float64
+	// Gain amplitude multiplier between octaves, typically 0.5
+	Gain float64
+}
+
+// NewFBM creates a fBm over source with lacunarity 2 and gain 0.5
+func NewFBM(source INoise, octaves int) *FBM {
+	return &FBM{Source: source, Octaves: octaves, Lacunarity: 2, Gain: 0.5}
+}
+
+// Noise2D generates 2-dimensional fBm
+func (f *FBM) Noise2D(x, y float64) float64 {
+	var sum, total float64
+	amp, freq := 1.0, 1.0
+	for i := 0; i < f.Octaves; i++ {
+		o := float64(i) * octaveOffset
+		sum += amp * f.Source.Noise2D(x*freq+o, y*freq+o)
+		total += amp
+		amp *= f.Gain
+		freq *= f.Lacunarity
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return sum / total
+}
+
+// Noise3D generates 3-dimensional fBm
+func (f *FBM) Noise3D(x, y, z float64) float64 {
+	var sum, total float64
+	amp, freq := 1.0, 1.0
+	for i := 0; i < f.Octaves; i++ {
+		o := float64(i) * octaveOffset
+		sum += amp * f.Source.Noise3D(x*freq+o, y*freq+o, z*freq+o)
+		total += amp
+		amp *= f.Gain
+		freq *= f.Lacunarity
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return sum / total
+}
+
+// Ridged multifractal noise (F. K. Musgrave). Every octave folds Source
+// around 0 into sharp ridges, and is weighted by the previous octave so
+// detail gathers on the ridges, as in eroded mountain ranges.
+// Output is roughly in [-1, 1.4] with the default parameters.
+type Ridged struct {
+	Source  INoise
+	Octaves int
+	// Lacunarity frequency multiplier between octaves, typically 2
+	Lacunarity float64
+	// Gain how strongly an octave weights the next one, typically 2
+	Gain float64
+	// Offset raises the folded signal, typically 1
+	Offset float64
+}
+
+// NewRidged creates a ridged multifractal over source with lacunarity 2, gain 2 and offset 1
+func NewRidged(source INoise, octaves int) *Ridged {
+	return &Ridged{Source: source, Octaves: octaves, Lacunarity: 2, Gain: 2, Offset: 1}
+}
+
+// Noise2D generates 2-dimensional ridged multifractal noise
+func (r *Ridged) Noise2D(x, y float64) float64 {
+	return r.sum(func(freq, o float64) float64 {
+		return r.Source.Noise2D(x*freq+o, y*freq+o)
+	})
+}
+
+// Noise3D generates 3-dimensional ridged multifractal noise
+func (r *Ridged) Noise3D(x, y, z float64) float64 {
+	return r.sum(func(freq, o float64) float64 {
+		return r.Source.Noise3D(x*freq+o, y*freq+o, z*freq+o)
+	})
+}
+
+func (r *Ridged) sum(sample func(freq, offset float64) float64) float64 {
+	var sum float64
+	weight, freq, spectral := 1.0, 1.0, 1.0
+	for i := 0; i < r.Octaves; i++ {
+		signal := r.Offset - math.Abs(sample(freq, float64(i)*octaveOffset))
+		signal *= signal * weight
+		weight = clamp(signal*r.Gain, 0, 1)
+
+		sum += signal * spectral
+		spectral /= r.Lacunarity
+		freq *= r.Lacunarity
+	}
+
+	return sum*1.25 - 1
+}
+
+// Warp domain warping, samples Source at coordinates displaced by Displace,
+// turning regular noise into swirling, folded shapes
+type Warp struct {
+	Source   INoise
+	Displace INoise
+	// Amplitude how far the coordinates move, in input units
+	Amplitude float64
+}
+
+// NewWarp creates a domain warp of source displaced by displace
+func NewWarp(source, displace INoise, amplitude float64) *Warp {
+	return &Warp{Source: source, Displace: displace, Amplitude: amplitude}
+}
+
+// Noise2D generates 2-dimensional warped noise
+func (w *Warp) Noise2D(x, y float64) float64 {
+	// Each axis samples the displacement at an unrelated offset
+	dx := w.Displace.Noise2D(x, y)
+	dy := w.Displace.Noise2D(x+5.2, y+1.3)
+	return w.Source.Noise2D(x+w.Amplitude*dx, y+w.Amplitude*dy)
+}
+
+// Noise3D generates 3-dimensional warped noise
+func (w *Warp) Noise3D(x, y, z float64) float64 {
+	dx := w.Displace.Noise3D(x, y, z)
+	dy := w.Displace.Noise3D(x+5.2, y+1.3, z+2.8)
+	dz := w.Displace.Noise3D(x+1.7, y+9.2, z+8.3)
+	return w.Source.Noise3D(x+w.Amplitude*dx, y+w.Amplitude*dy, z+w.Amplitude*dz)
+}
@@ -0,0 +1,58 @@
+package perlin
+
+import "math"
+
+// INoise is a coherent noise source over 2 or 3 dimensions.
+// Perlin and every generator in this package implement it, so the fractal
+// and warp helpers can be stacked on top of any of them.
+type INoise interface {
+	Noise2D(x, y float64) float64
+	Noise3D(x, y, z float64) float64
+}
+
+// Lattice hashing primes, taken from OpenSimplex2
+const (
+	primeX uint64 = 0x5205402B9270C86F
+	primeY uint64 = 0x598CD327003817B5
+	primeZ uint64 = 0x5BCC226E9FA0BACB
+
+	hashMultiplier uint64 = 0x53A3F72DEEC546F5
+)
+
+// hash2 hashes a 2D lattice point premultiplied by the primes
+func hash2(seed int64, xp, yp uint64) uint64 {
+	h := (uint64(seed) ^ xp ^ yp) * hashMultiplier
+	return h ^ h>>32
+}
+
+// hash3 hashes a 3D lattice point premultiplied by the primes
+func hash3(seed int64, xp, yp, zp uint64) uint64 {
+	h := (uint64(seed) ^ xp ^ yp ^ zp) * hashMultiplier
+	return h ^ h>>32
+}
+
+// unit maps a hash to [-1, 1]
+func unit(h uint64) float64 {
+	return float64(h>>11)/float64(1<<52) - 1
+}
+
+func fastFloor(x float64) int64 {
+	i := int64(x)
+	if x < float64(i) {
+		return i - 1
+	}
+	return i
+}
+
+func fastRound(x float64) int64 {
+	return fastFloor(x + 0.5)
+}
+
+// quintic is the C2 continuous interpolation curve 6t^5 - 15t^4 + 10t^3
+func quintic(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
@@ -0,0 +1,129 @@
+package perlin
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// noiseCase is a generator under test and the range its output must stay in
+type noiseCase struct {
+	name     string
+	new      func(seed int64) INoise
+	min, max float64
+}
+
+var noiseCases = []noiseCase{
+	{"simplex", func(seed int64) INoise { return NewOpenSimplex(seed) }, -1.05, 1.05},
+	{"value", func(seed int64) INoise { return NewValue(seed) }, -1, 1},
+	{"cellular", func(seed int64) INoise { return NewCellular(seed) }, 0, 1.5},
+	{"cellvalue", func(seed int64) INoise {
+		c := NewCellular(seed)
+		c.Return = CellularCellValue
+		return c
+	}, -1, 1},
+	{"fbm", func(seed int64) INoise { return NewFBM(NewOpenSimplex(seed), 5) }, -1.05, 1.05},
+	{"ridged", func(seed int64) INoise { return NewRidged(NewOpenSimplex(seed), 5) }, -1.05, 1.45},
+	{"warp", func(seed int64) INoise {
+		return NewWarp(NewOpenSimplex(seed), NewFBM(NewOpenSimplex(seed+1), 3), 4)
+	}, -1.05, 1.05},
+}
+
+// samplePoints returns n pseudo random points spread over [-1000, 1000]
+func samplePoints(n int) [][3]float64 {
+	r := rand.New(rand.NewSource(1))
+	points := make([][3]float64, n)
+	for i := range points {
+		points[i] = [3]float64{r.Float64()*2000 - 1000, r.Float64()*2000 - 1000, r.Float64()*2000 - 1000}
+	}
+	return points
+}
+
+func TestNoiseDeterministic(t *testing.T) {
+	points := samplePoints(200)
+	for _, nc := range noiseCases {
+		a, b, other := nc.new(1), nc.new(1), nc.new(2)
+		differs := 0
+		for _, p := range points {
+			if a.Noise2D(p[0], p[1]) != b.Noise2D(p[0], p[1]) || a.Noise3D(p[0], p[1], p[2]) != b.Noise3D(p[0], p[1], p[2]) {
+				t.Fatalf("%s: same seed gave different values at %v", nc.name, p)
+			}
+			if a.Noise3D(p[0], p[1], p[2]) != other.Noise3D(p[0], p[1], p[2]) {
+				differs++
+			}
+		}
+		if differs < len(points)*9/10 {
+			t.Errorf("%s: seeds 1 and 2 differ at only %d of %d points", nc.name, differs, len(points))
+		}
+	}
+}
+
+func TestNoiseRange(t *testing.T) {
+	points := samplePoints(50000)
+	for _, nc := range noiseCases {
+		n := nc.new(7)
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, p := range points {
+			for _, v := range []float64{n.Noise2D(p[0], p[1]), n.Noise3D(p[0], p[1], p[2])} {
+				min, max = math.Min(min, v), math.Max(max, v)
+			}
+		}
+		if min < nc.min || max > nc.max {
+			t.Errorf("%s: output in [%.4f, %.4f], want within [%v, %v]", nc.name, min, max, nc.min, nc.max)
+		}
+		// the range should also be used, not collapsed around one value
+		if max-min < (nc.max-nc.min)/3 {
+			t.Errorf("%s: output only spans [%.4f, %.4f]", nc.name, min, max)
+		}
+	}
+}
+
+func TestNoiseContinuous(t *testing.T) {
+	const eps = 1e-6
+	points := samplePoints(5000)
+	for _, nc := range noiseCases {
+		if nc.name == "cellvalue" {
+			// constant per cell, jumps on the borders
+			continue
+		}
+		n := nc.new(3)
+		for _, p := range points {
+			if d := math.Abs(n.Noise3D(p[0], p[1], p[2]+eps) - n.Noise3D(p[0], p[1], p[2])); d > 1e-3 {
+				t.Fatalf("%s: jumps by %v over %v at %v", nc.name, d, eps, p)
+			}
+		}
+	}
+}
+
+var benchSink float64
+
+func benchmarkNoise2D(b *testing.B, n INoise) {
+	for i := 0; i < b.N; i++ {
+		benchSink += n.Noise2D(float64(i)*0.013, float64(i)*0.007)
+	}
+}
+
+func benchmarkNoise3D(b *testing.B, n INoise) {
+	for i := 0; i < b.N; i++ {
+		benchSink += n.Noise3D(float64(i)*0.013, float64(i)*0.007, float64(i)*0.011)
+	}
+}
+
+func BenchmarkPerlin2D(b *testing.B)   { benchmarkNoise2D(b, NewPerlin(2, 2, 1, 1)) }
+func BenchmarkPerlin3D(b *testing.B)   { benchmarkNoise3D(b, NewPerlin(2, 2, 1, 1)) }
+func BenchmarkSimplex2D(b *testing.B)  { benchmarkNoise2D(b, NewOpenSimplex(1)) }
+func BenchmarkSimplex3D(b *testing.B)  { benchmarkNoise3D(b, NewOpenSimplex(1)) }
+func BenchmarkValue2D(b *testing.B)    { benchmarkNoise2D(b, NewValue(1)) }
+func BenchmarkValue3D(b *testing.B)    { benchmarkNoise3D(b, NewValue(1)) }
+func BenchmarkCellular2D(b *testing.B) { benchmarkNoise2D(b, NewCellular(1)) }
+func BenchmarkCellular3D(b *testing.B) { benchmarkNoise3D(b, NewCellular(1)) }
+func BenchmarkFBM2D(b *testing.B)      { benchmarkNoise2D(b, NewFBM(NewOpenSimplex(1), 5)) }
+func BenchmarkFBM3D(b *testing.B)      { benchmarkNoise3D(b, NewFBM(NewOpenSimplex(1), 5)) }
+func BenchmarkRidged2D(b *testing.B)   { benchmarkNoise2D(b, NewRidged(NewOpenSimplex(1), 5)) }
+func BenchmarkRidged3D(b *testing.B)   { benchmarkNoise3D(b, NewRidged(NewOpenSimplex(1), 5)) }
+func BenchmarkWarp2D(b *testing.B) {
+	benchmarkNoise2D(b, NewWarp(NewOpenSimplex(1), NewOpenSimplex(2), 4))
+}
+func BenchmarkWarp3D(b *testing.B) {
+	benchmarkNoise3D(b, NewWarp(NewOpenSimplex(1), NewOpenSimplex(2), 4))
+}
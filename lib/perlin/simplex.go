@@ -0,0 +1,166 @@
+package perlin
+
+import "math"
+
+// OpenSimplex 2D/3D noise following the OpenSimplex2 construction
+// (https://github.com/KdotJPG/OpenSimplex2): 2D samples a simplex lattice,
+// 3D sums two offset cubic lattices forming a body-centered cubic lattice,
+// which avoids the axis aligned artifacts of Perlin noise.
+// Output is roughly in [-1, 1]. Safe for concurrent use.
+type OpenSimplex struct {
+	seed int64
+}
+
+const (
+	skew2D     = 0.366025403784439    // (sqrt(3) - 1) / 2
+	unskew2D   = -0.21132486540518713 // (1/sqrt(3) - 1) / 2
+	rSquared2D = 0.5
+	rSquared3D = 0.6
+	// rotate3D rotates the input so the cubic lattice is not aligned with the axes
+	rotate3D = 2.0 / 3.0
+
+	// seedFlip3D gives the second lattice copy of 3D noise its own hashes
+	seedFlip3D uint64 = 0xAD2AB84D16912A9D
+
+	// Normalize the kernel sums to about [-1, 1]
+	norm2D = 99.2
+	norm3D = 32.69
+)
+
+var gradients2D = func() [24][2]float64 {
+	var g [24][2]float64
+	for i := range g {
+		a := float64(i) * math.Pi / 12
+		g[i] = [2]float64{math.Cos(a), math.Sin(a)}
+	}
+	return g
+}()
+
+var gradients3D = [12][3]float64{
+	{1, 1, 0}, {-1, 1, 0}, {1, -1, 0}, {-1, -1, 0},
+	{1, 0, 1}, {-1, 0, 1}, {1, 0, -1}, {-1, 0, -1},
+	{0, 1, 1}, {0, -1, 1}, {0, 1, -1}, {0, -1, -1},
+}
+
+// NewOpenSimplex creates an OpenSimplex noise generator
+func NewOpenSimplex(seed int64) *OpenSimplex {
+	return &OpenSimplex{seed: seed}
+}
+
+// Noise2D generates 2-dimensional OpenSimplex noise
+func (s *OpenSimplex) Noise2D(x, y float64) float64 {
+	// Skew to the square lattice, each square holds two triangles
+	k := skew2D * (x + y)
+	xs, ys := x+k, y+k
+	xsb, ysb := fastFloor(xs), fastFloor(ys)
+	xi, yi := xs-float64(xsb), ys-float64(ysb)
+	xp, yp := uint64(xsb)*primeX, uint64(ysb)*primeY
+
+	t := (xi + yi) * unskew2D
+	dx0, dy0 := xi+t, yi+t
+
+	v := s.contrib2(xp, yp, dx0, dy0)
+	v += s.contrib2(xp+primeX, yp+primeY, dx0-1-2*unskew2D, dy0-1-2*unskew2D)
+	if dy0 > dx0 {
+		v += s.contrib2(xp, yp+primeY, dx0-unskew2D, dy0-1-unskew2D)
+	} else {
+		v += s.contrib2(xp+primeX, yp, dx0-1-unskew2D, dy0-unskew2D)
+	}
+
+	return v * norm2D
+}
+
+func (s *OpenSimplex) contrib2(xp, yp uint64, dx, dy float64) float64 {
+	a := rSquared2D - dx*dx - dy*dy
+	if a <= 0 {
+		return 0
+	}
+
+	g := &gradients2D[(hash2(s.seed, xp, yp)>>40)%uint64(len(gradients2D))]
+	a *= a
+	return a * a * (g[0]*dx + g[1]*dy)
+}
+
+// Noise3D generates 3-dimensional OpenSimplex noise
+func (s *OpenSimplex) Noise3D(x, y, z float64) float64 {
+	r := rotate3D * (x + y + z)
+	xr, yr, zr := r-x, r-y, r-z
+
+	xrb, yrb, zrb := fastRound(xr), fastRound(yr), fastRound(zr)
+	xri, yri, zri := xr-float64(xrb), yr-float64(yrb), zr-float64(zrb)
+
+	// Direction from the closest vertex towards the point
+	xs, ys, zs := sign(xri), sign(yri), sign(zri)
+	ax, ay, az := math.Abs(xri), math.Abs(yri), math.Abs(zri)
+
+	xp, yp, zp := uint64(xrb)*primeX, uint64(yrb)*primeY, uint64(zrb)*primeZ
+	seed := uint64(s.seed)
+
+	var v float64
+	a := rSquared3D - xri*xri - yri*yri - zri*zri
+	for l := 0; ; l++ {
+		// Closest vertex of this lattice copy
+		if a > 0 {
+			v += s.contrib3(seed, xp, yp, zp, xri, yri, zri, a)
+		}
+
+		// Second closest vertex, one step along the largest axis
+		if ax >= ay && ax >= az {
+			if b := a + 2*ax - 1; b > 0 {
+				v += s.contrib3(seed, xp+step(xs, primeX), yp, zp, xri-xs, yri, zri, b)
+			}
+		} else if ay > ax && ay >= az {
+			if b := a + 2*ay - 1; b > 0 {
+				v += s.contrib3(seed, xp, yp+step(ys, primeY), zp, xri, yri-ys, zri, b)
+			}
+		} else {
+			if b := a + 2*az - 1; b > 0 {
+				v += s.contrib3(seed, xp, yp, zp+step(zs, primeZ), xri, yri, zri-zs, b)
+			}
+		}
+
+		if l == 1 {
+			break
+		}
+
+		// Move to the other lattice copy, offset by half a cell
+		a += (ax + ay + az) - 0.75
+		ax, ay, az = 0.5-ax, 0.5-ay, 0.5-az
+		xri, yri, zri = -xs*ax, -ys*ay, -zs*az
+		if xs > 0 {
+			xp += primeX
+		}
+		if ys > 0 {
+			yp += primeY
+		}
+		if zs > 0 {
+			zp += primeZ
+		}
+		xs, ys, zs = -xs, -ys, -zs
+		seed ^= seedFlip3D
+	}
+
+	return v * norm3D
+}
+
+func (s *OpenSimplex) contrib3(seed, xp, yp, zp uint64, dx, dy, dz, a float64) float64 {
+	g := &gradients3D[(hash3(int64(seed), xp, yp, zp)>>40)%uint64(len(gradients3D))]
+	a *= a
+	return a * a * (g[0]*dx + g[1]*dy + g[2]*dz)
+}
+
+// sign returns 1 for non-negative values and -1 otherwise
+func sign(v float64) float64 {
+	if v >= 0 {
+		return 1
+	}
+	return -1
+}
+
+// step returns the hash offset of one lattice step in direction s
+func step(s float64, prime uint64) uint64 {
+	if s > 0 {
+		return prime
+	}
+	return -prime
+}
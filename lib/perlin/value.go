@@ -0,0 +1,41 @@
+package perlin
+
+// Value noise interpolates random values assigned to the integer lattice
+// points with a quintic curve. It is cheaper than gradient noise but shows
+// more of the grid. Output is in [-1, 1]. Safe for concurrent use.
+type Value struct {
+	seed int64
+}
+
+// NewValue creates a value noise generator
+func NewValue(seed int64) *Value {
+	return &Value{seed: seed}
+}
+
+// Noise2D generates 2-dimensional value noise
+func (v *Value) Noise2D(x, y float64) float64 {
+	x0, y0 := fastFloor(x), fastFloor(y)
+	sx, sy := quintic(x-float64(x0)), quintic(y-float64(y0))
+
+	xp0, yp0 := uint64(x0)*primeX, uint64(y0)*primeY
+	xp1, yp1 := xp0+primeX, yp0+primeY
+
+	a := lerp(sx, unit(hash2(v.seed, xp0, yp0)), unit(hash2(v.seed, xp1, yp0)))
+	b := lerp(sx, unit(hash2(v.seed, xp0, yp1)), unit(hash2(v.seed, xp1, yp1)))
+	return lerp(sy, a, b)
+}
+
+// Noise3D generates 3-dimensional value noise
+func (v *Value) Noise3D(x, y, z float64) float64 {
+	x0, y0, z0 := fastFloor(x), fastFloor(y), fastFloor(z)
+	sx, sy, sz := quintic(x-float64(x0)), quintic(y-float64(y0)), quintic(z-float64(z0))
+
+	xp0, yp0, zp0 := uint64(x0)*primeX, uint64(y0)*primeY, uint64(z0)*primeZ
+	xp1, yp1, zp1 := xp0+primeX, yp0+primeY, zp0+primeZ
+
+	a := lerp(sx, unit(hash3(v.seed, xp0, yp0, zp0)), unit(hash3(v.seed, xp1, yp0, zp0)))
+	b := lerp(sx, unit(hash3(v.seed, xp0, yp1, zp0)), unit(hash3(v.seed, xp1, yp1, zp0)))
+	c := lerp(sx, unit(hash3(v.seed, xp0, yp0, zp1)), unit(hash3(v.seed, xp1, yp0, zp1)))
+	d := lerp(sx, unit(hash3(v.seed, xp0, yp1, zp1)), unit(hash3(v.seed, xp1, yp1, zp1)))
+	return lerp(sz, lerp(sy, a, b), lerp(sy, c, d))
+}